@@ -0,0 +1,52 @@
+package memsize
+
+import "reflect"
+
+// Accountant attributes resources outside of the Go heap to the object graph.
+// Examples of such resources are memory-mapped regions, file descriptors or
+// GPU buffers.
+//
+// Accountants are configured using Options.Accountants. During the scan,
+// Account is called once for every object counted by the scanner. The accountant
+// should return the name of the resource held by the object and the amount
+// held. An amount of zero means the object doesn't hold the resource.
+//
+// Account is called while the world is stopped. It must not allocate excessively,
+// block or acquire locks.
+//
+// When ScanRoots uses AttributeSplit, the amounts of objects reachable from
+// several roots are divided among the roots like their memory.
+type Accountant interface {
+	Account(v reflect.Value) (resource string, amount uint64)
+}
+
+// AccountantFunc adapts a function to the Accountant interface.
+type AccountantFunc func(v reflect.Value) (resource string, amount uint64)
+
+// Account calls fn(v).
+func (fn AccountantFunc) Account(v reflect.Value) (string, uint64) {
+	return fn(v)
+}
+
+// addExternal records an external resource held by a value of type typ.
+func (s *Sizes) addExternal(typ reflect.Type, resource string, amount uint64) {
+	if s.External == nil {
+		s.External = make(map[string]uint64)
+	}
+	s.External[resource] += amount
+	ts := s.ByType[typ]
+	if ts.External == nil {
+		ts.External = make(map[string]uint64)
+	}
+	ts.External[resource] += amount
+}
+
+// account runs all accountants on v. The amounts are divided by split, the
+// attribution divisor of v.
+func (c *scanState) account(v reflect.Value, split uintptr) {
+	for _, a := range c.accountants {
+		if resource, amount := a.Account(v); amount != 0 {
+			c.s.addExternal(v.Type(), resource, amount/uint64(split))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package memsize
+
+import "github.com/fjl/memsize/internal/runtimefunc"
+
+// AddressClass is the kind of memory holding a value.
+type AddressClass uint8
+
+const (
+	// ClassUnknown is used for memory whose class can't be determined. This
+	// includes memory without known address (e.g. map storage, values stored in
+	// interfaces and extrapolated memory), the stacks of goroutines other than the
+	// one calling Scan, global variables without pointers and memory not managed
+	// by Go.
+	ClassUnknown AddressClass = iota
+	// ClassHeap is memory allocated on the Go heap.
+	ClassHeap
+	// ClassStack is memory on the stack of the goroutine calling Scan.
+	ClassStack
+	// ClassGlobal is memory of global variables which contain pointers, i.e. the
+	// data and bss segments. Pointer-free variables are placed in other segments
+	// by the linker and are not recognized.
+	ClassGlobal
+
+	numAddressClasses
+)
+
+func (c AddressClass) String() string {
+	switch c {
+	case ClassHeap:
+		return "heap"
+	case ClassStack:
+		return "stack"
+	case ClassGlobal:
+		return "global"
+	default:
+		return "unknown"
+	}
+}
+
+// classifyAddress determines the address class of addr using the metadata of the
+// runtime. The runtime's checkptrBase reports the current goroutine's stack as
+// base 1, heap objects as their base address and globals as the start of their
+// segment. Heap objects are told apart from globals by findObject.
+func classifyAddress(addr address) AddressClass {
+	if !addr.valid() {
+		return ClassUnknown
+	}
+	switch base := runtimefunc.CheckptrBase(uintptr(addr)); {
+	case base == 0:
+		return ClassUnknown
+	case base == 1:
+		return ClassStack
+	case runtimefunc.FindObjectBase(uintptr(addr)) != 0:
+		return ClassHeap
+	default:
+		return ClassGlobal
+	}
+}
+
+// ByAddressClass returns the total memory per address class. It is only populated
+// when Options.AddressClasses is enabled. The totals of all classes add up to Total.
+//
+// The world is stopped during the scan, so goroutine stacks can't move while they
+// are scanned. Note however that values on the stack of the goroutine calling Scan
+// are only valid until the calling function returns, and the compiler may keep some
+// of them in registers instead. To get results which don't depend on the compiler's
+// escape analysis, scan pointers to heap-allocated values, e.g. by storing the root
+// in a package-level variable or a RootSet. Sizes.RootClass tells the class of the
+// scanned value.
+func (s Sizes) ByAddressClass() map[AddressClass]uintptr {
+	m := make(map[AddressClass]uintptr)
+	for class, size := range s.classes {
+		if size > 0 {
+			m[AddressClass(class)] = size
+		}
+	}
+	return m
+}
@@ -0,0 +1,78 @@
+package memsize
+
+import (
+	"reflect"
+	"sort"
+)
+
+// addrIndex maps the address ranges found by a scan to their types, see
+// Sizes.TypeAt.
+type addrIndex struct {
+	ranges []addrRange
+}
+
+type addrRange struct {
+	start, end uintptr
+	maxEnd     uintptr // largest end of all ranges up to this one, once sorted
+	typ        reflect.Type
+}
+
+func (ix *addrIndex) add(start, size uintptr, typ reflect.Type) {
+	ix.ranges = append(ix.ranges, addrRange{start: start, end: start + size, typ: typ})
+}
+
+// finish sorts the ranges for lookup.
+func (ix *addrIndex) finish() {
+	sort.Slice(ix.ranges, func(i, j int) bool {
+		if ix.ranges[i].start != ix.ranges[j].start {
+			return ix.ranges[i].start < ix.ranges[j].start
+		}
+		return ix.ranges[i].end < ix.ranges[j].end
+	})
+	var maxEnd uintptr
+	for i := range ix.ranges {
+		if ix.ranges[i].end > maxEnd {
+			maxEnd = ix.ranges[i].end
+		}
+		ix.ranges[i].maxEnd = maxEnd
+	}
+}
+
+// lookup returns the smallest range containing addr.
+func (ix *addrIndex) lookup(addr uintptr) (reflect.Type, bool) {
+	i := sort.Search(len(ix.ranges), func(i int) bool { return ix.ranges[i].start > addr })
+	var best *addrRange
+	// Ranges are sorted by start, so only the ranges before i can contain addr.
+	// The walk stops when no earlier range reaches addr.
+	for i--; i >= 0 && ix.ranges[i].maxEnd > addr; i-- {
+		r := &ix.ranges[i]
+		if addr < r.end && (best == nil || r.end-r.start < best.end-best.start) {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return best.typ, true
+}
+
+// recordAddr adds a range to the address index, if enabled.
+func (c *scanState) recordAddr(start, size uintptr, typ reflect.Type) {
+	if c.addrs != nil && size > 0 {
+		c.addrs.add(start, size, typ)
+	}
+}
+
+// TypeAt returns the type of the memory at addr, which must be an address found
+// by the scan (see Options.AddressIndex). The type of objects is the type of the
+// value, the type of slice backing arrays is the slice type and the type of string
+// data is string. When addr is inside several ranges, e.g. because a pointer to a
+// struct field was followed before the struct itself, the smallest one is used.
+//
+// Memory of map entries and channel buffers is not indexed.
+func (s Sizes) TypeAt(addr uintptr) (reflect.Type, bool) {
+	if s.addrs == nil {
+		return nil, false
+	}
+	return s.addrs.lookup(addr)
+}
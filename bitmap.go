@@ -9,15 +9,33 @@ const (
 	uintptrBytes = uintptrBits / 8
 	bmBlockRange = 1 * 1024 * 1024 // bytes covered by bmBlock
 	bmBlockWords = bmBlockRange / uintptrBits
+	bmMaxFree    = 32 // blocks kept for reuse by reset, i.e. 4 MB
 )
 
 // bitmap is a sparse bitmap.
 type bitmap struct {
 	blocks map[uintptr]*bmBlock
+	free   []*bmBlock // cleared blocks for reuse
 }
 
 func newBitmap() *bitmap {
-	return &bitmap{make(map[uintptr]*bmBlock)}
+	return &bitmap{blocks: make(map[uintptr]*bmBlock)}
+}
+
+// reset clears the bitmap. Up to bmMaxFree blocks are kept for reuse, the others
+// are released.
+func (b *bitmap) reset() {
+	for index, block := range b.blocks {
+		if len(b.free) < bmMaxFree {
+			*block = bmBlock{}
+			b.free = append(b.free, block)
+		}
+		delete(b.blocks, index)
+	}
+	if len(b.blocks) == 0 {
+		// Deleting doesn't shrink the map.
+		b.blocks = make(map[uintptr]*bmBlock)
+	}
 }
 
 // markRange sets n consecutive bits starting at addr.
@@ -59,7 +77,11 @@ func (b *bitmap) block(addr uintptr) (*bmBlock, uintptr) {
 	index := addr / bmBlockRange
 	block := b.blocks[index]
 	if block == nil {
-		block = new(bmBlock)
+		if n := len(b.free); n > 0 {
+			block, b.free = b.free[n-1], b.free[:n-1]
+		} else {
+			block = new(bmBlock)
+		}
 		b.blocks[index] = block
 	}
 	return block, addr % bmBlockRange
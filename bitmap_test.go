@@ -128,3 +128,22 @@ func BenchmarkBitmapMarkRange(b *testing.B) {
 		b.Run(fmt.Sprintf("%d", rlen), func(b *testing.B) { doit(b, rlen) })
 	}
 }
+
+func TestBitmapReset(t *testing.T) {
+	bm := newBitmap()
+	for i := uintptr(0); i < 2*bmMaxFree; i++ {
+		bm.markRange(i*bmBlockRange, 1)
+	}
+	bm.reset()
+	if len(bm.blocks) != 0 || len(bm.free) != bmMaxFree {
+		t.Fatalf("after reset: %d blocks, %d free, want 0, %d", len(bm.blocks), len(bm.free), bmMaxFree)
+	}
+	// Free blocks are reused and must be clear.
+	bm.markRange(5, 1)
+	if len(bm.free) != bmMaxFree-1 {
+		t.Errorf("free block not reused")
+	}
+	if c := bm.countRange(0, bmBlockRange); c != 1 {
+		t.Errorf("countRange returned %d after reuse, want 1", c)
+	}
+}
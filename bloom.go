@@ -0,0 +1,152 @@
+package memsize
+
+// seenSet tracks the memory visited during a scan.
+type seenSet interface {
+	// markRange marks n consecutive bytes starting at addr.
+	markRange(addr, n uintptr)
+	// countRange returns the number of marked bytes in the range [addr, addr+n].
+	countRange(addr, n uintptr) uintptr
+	// size returns the memory used by the set.
+	size() uintptr
+	// utilization returns the fraction of set bits.
+	utilization() float32
+	// reset clears the set, retaining allocated memory.
+	reset()
+}
+
+var (
+	_ seenSet = (*bitmap)(nil)
+	_ seenSet = (*bloomFilter)(nil)
+)
+
+// bloomHashes is the number of hash functions used by bloomFilter.
+const bloomHashes = 3
+
+var bloomSeeds = [bloomHashes]uint64{0x9e3779b97f4a7c15, 0xc2b2ae3d27d4eb4f, 0x165667b19e3779f9}
+
+// bloomBlock is the granularity of bloomFilter. A marked range is stored as one
+// entry for its start address and one for every multiple of bloomBlock inside it,
+// so an object smaller than the block takes a single entry.
+const bloomBlock = 256
+
+// bloomEntryBits is the number of filter bits per expected entry. With
+// bloomHashes hash functions, this gives a false positive rate of about 0.5%.
+const bloomEntryBits = 16
+
+// bloomFilter is a fixed-size approximate set of addresses.
+// Unlike bitmap, its size doesn't depend on the number of marked addresses.
+//
+// Entries don't record the length of the marked ranges. An entry counts as
+// covering the memory up to the next multiple of bloomBlock. A range which starts
+// inside a marked range, e.g. a subslice, is counted again up to there, and a
+// range enclosing the entry of a shorter one counts that memory as marked.
+type bloomFilter struct {
+	bits []uintptr
+	mask uint64 // number of bits - 1
+	nset uintptr
+}
+
+// bloomFilterBytes returns the filter size for the given number of entries.
+func bloomFilterBytes(entries uintptr) uintptr {
+	return entries * bloomEntryBits / 8
+}
+
+// newBloomFilter creates a filter that uses at most size bytes of memory.
+func newBloomFilter(size uintptr) *bloomFilter {
+	words := uintptr(1)
+	for words*2*uintptrBytes <= size {
+		words *= 2
+	}
+	return &bloomFilter{
+		bits: make([]uintptr, words),
+		mask: uint64(words*uintptrBits) - 1,
+	}
+}
+
+// markRange adds the entries of n consecutive addresses starting at addr.
+func (f *bloomFilter) markRange(addr, n uintptr) {
+	for end := addr + n; addr < end; {
+		for i := range bloomSeeds {
+			f.set(f.hash(addr, i))
+		}
+		next := nextBloomBlock(addr)
+		if next < addr {
+			break // overflow
+		}
+		addr = next
+	}
+}
+
+// nextBloomBlock returns the first multiple of bloomBlock after addr. It returns a
+// smaller address when this overflows.
+func nextBloomBlock(addr uintptr) uintptr {
+	return addr - addr%bloomBlock + bloomBlock
+}
+
+// isMarked reports whether addr is (probably) in the set.
+func (f *bloomFilter) isMarked(addr uintptr) bool {
+	for i := range bloomSeeds {
+		if !f.get(f.hash(addr, i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// countRange returns the number of (probably) marked addresses in the range
+// [addr, addr+n], with the granularity of the entries.
+func (f *bloomFilter) countRange(addr, n uintptr) uintptr {
+	c := uintptr(0)
+	for end := addr + n; addr < end; {
+		next := nextBloomBlock(addr)
+		if next > end || next < addr {
+			next = end
+		}
+		if f.isMarked(addr) {
+			c += next - addr
+		}
+		addr = next
+	}
+	return c
+}
+
+// size returns the byte size of the filter.
+func (f *bloomFilter) size() uintptr {
+	return uintptr(len(f.bits)) * uintptrBytes
+}
+
+// utilization returns the fraction of bits set.
+func (f *bloomFilter) utilization() float32 {
+	return float32(f.nset) / float32(f.mask+1)
+}
+
+// reset clears all bits.
+func (f *bloomFilter) reset() {
+	for i := range f.bits {
+		f.bits[i] = 0
+	}
+	f.nset = 0
+}
+
+func (f *bloomFilter) hash(addr uintptr, i int) uint64 {
+	// This is the finalizer of MurmurHash3.
+	h := uint64(addr) ^ bloomSeeds[i]
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h & f.mask
+}
+
+func (f *bloomFilter) set(bit uint64) {
+	w, m := bit/uintptrBits, uintptr(1)<<(bit%uintptrBits)
+	if f.bits[w]&m == 0 {
+		f.bits[w] |= m
+		f.nset++
+	}
+}
+
+func (f *bloomFilter) get(bit uint64) bool {
+	return f.bits[bit/uintptrBits]&(uintptr(1)<<(bit%uintptrBits)) != 0
+}
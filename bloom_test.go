@@ -0,0 +1,86 @@
+package memsize
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBloomFilterSize(t *testing.T) {
+	tests := []struct{ size, want uintptr }{
+		{size: 0, want: uintptrBytes},
+		{size: 1000, want: 512},
+		{size: 1024, want: 1024},
+		{size: 16 * 1024 * 1024, want: 16 * 1024 * 1024},
+	}
+	for _, test := range tests {
+		if size := newBloomFilter(test.size).size(); size != test.want {
+			t.Errorf("newBloomFilter(%d).size() = %d, want %d", test.size, size, test.want)
+		}
+	}
+}
+
+func TestBloomFilterMarkRange(t *testing.T) {
+	var (
+		r      = rand.New(rand.NewSource(9182731))
+		bf     = newBloomFilter(1024 * 1024)
+		ranges = make(map[uintptr]uintptr)
+		total  uintptr
+	)
+	for i := 0; i < 1000; i++ {
+		addr := uintptr(r.Uint64())
+		len := uintptr(r.Intn(40))
+		ranges[addr] = len
+		total += len
+		bf.markRange(addr, len)
+	}
+
+	// There can't be any false negatives.
+	var counted uintptr
+	for start, len := range ranges {
+		if len > 0 && !bf.isMarked(start) {
+			t.Fatalf("not marked at %d", start)
+		}
+		counted += bf.countRange(start, len)
+	}
+	if counted != total {
+		t.Errorf("countRange sum is %d, want %d", counted, total)
+	}
+
+	// False positives should be rare with this fill level.
+	var falsePositives int
+	for i := 0; i < 10000; i++ {
+		if bf.isMarked(uintptr(r.Uint64())) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 10 {
+		t.Errorf("too many false positives: %d", falsePositives)
+	}
+	if u := bf.utilization(); u <= 0 || u > 0.1 {
+		t.Errorf("wrong utilization %f", u)
+	}
+}
+
+func TestBloomFilterBlocks(t *testing.T) {
+	bf := newBloomFilter(1024 * 1024)
+	base := uintptr(1 << 20)
+	bf.markRange(base+8, 1000)
+	if n := bf.nset; n != 4*bloomHashes {
+		t.Errorf("range of 1000 bytes has %d bits set, want %d", n, 4*bloomHashes)
+	}
+	tests := []struct{ addr, n, want uintptr }{
+		{base + 8, 1000, 1000},
+		{base + 8, 2000, 1000 + (bloomBlock - 1008%bloomBlock)},
+		{base + bloomBlock, 100, 100},
+		{base, 8, 0},
+		// Ranges starting inside the marked range are counted from the next block.
+		{base + 16, 1000, 1000 - (bloomBlock - 16)},
+		// No entries for adjacent objects in the same block.
+		{base + 1008, 8, 0},
+	}
+	for _, test := range tests {
+		if c := bf.countRange(test.addr, test.n); c != test.want {
+			t.Errorf("countRange(base+%d, %d) = %d, want %d", test.addr-base, test.n, c, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package memsize
+
+import "reflect"
+
+// scanBudget tracks the number of traversed values for Options.MaxPerType.
+type scanBudget struct {
+	limits map[reflect.Type]int
+	types  map[reflect.Type]*typeBudget
+}
+
+type typeBudget struct {
+	taken     int               // values admitted for traversal
+	done      int               // values whose traversal has finished
+	kinds     [numKinds]uintptr // referenced memory of finished values, see ByKind
+	estimated uintptr           // values counted without traversal
+}
+
+func newScanBudget(limits map[reflect.Type]int) *scanBudget {
+	return &scanBudget{limits: limits, types: make(map[reflect.Type]*typeBudget)}
+}
+
+// limited reports whether values of typ are subject to a budget.
+func (b *scanBudget) limited(typ reflect.Type) bool {
+	_, ok := b.limits[typ]
+	return ok
+}
+
+// take reports whether a value of type typ may be traversed. When it returns
+// false, the value is recorded as estimated.
+func (b *scanBudget) take(typ reflect.Type) bool {
+	limit, ok := b.limits[typ]
+	if !ok {
+		return true
+	}
+	tb := b.types[typ]
+	if tb == nil {
+		tb = new(typeBudget)
+		b.types[typ] = tb
+	}
+	if tb.taken < limit {
+		tb.taken++
+		return true
+	}
+	tb.estimated++
+	return false
+}
+
+// kinds returns the accumulator of the referenced memory of traversed values of
+// typ, or nil if typ has no budget.
+func (b *scanBudget) kinds(typ reflect.Type) *[numKinds]uintptr {
+	if tb := b.types[typ]; tb != nil {
+		return &tb.kinds
+	}
+	return nil
+}
+
+// record marks the traversal of a value as finished.
+func (b *scanBudget) record(typ reflect.Type) {
+	if tb := b.types[typ]; tb != nil {
+		tb.done++
+	}
+}
+
+// apply adds the extrapolated referenced memory of estimated values to s. This is
+// done at the end of the scan because values of recursive types are reached before
+// the traversal of any value of the type has finished. The memory is accounted to
+// the kinds holding it in the traversed values.
+func (b *scanBudget) apply(s *Sizes) {
+	for typ, tb := range b.types {
+		if tb.estimated > 0 && tb.done > 0 && s.ByType[typ] != nil {
+			var extra uintptr
+			for k, n := range tb.kinds {
+				e := n / uintptr(tb.done) * tb.estimated
+				s.kinds[k] += e
+				extra += e
+			}
+			ts := s.ByType[typ]
+			ts.Referenced += extra
+			ts.Total += extra
+			s.Total += extra
+			s.classes[ClassUnknown] += extra
+		}
+		tb.estimated = 0
+	}
+}
+
+// scanBudgetEdges follows the references of an estimated value to values of types
+// with a budget: pointers in the fields of v and the pointers held by slices and
+// arrays in these fields. This finds all values of linked structures such as lists
+// and trees without traversing their content.
+func (c *scanState) scanBudgetEdges(v reflect.Value) {
+	if v.Kind() != reflect.Struct || c.interrupted() {
+		return
+	}
+	for i := 0; i < v.NumField() && !c.stopped; i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Ptr:
+			c.followBudgetEdge(f)
+		case reflect.Slice, reflect.Array:
+			if et := f.Type().Elem(); et.Kind() == reflect.Ptr && c.budget.limited(et.Elem()) {
+				for j := 0; j < f.Len() && !c.stopped; j++ {
+					c.followBudgetEdge(f.Index(j))
+				}
+			}
+		}
+	}
+}
+
+// followBudgetEdge scans the target of pointer p if it has a budget. The edge isn't
+// counted in TypeSize.Edges because v isn't traversed.
+func (c *scanState) followBudgetEdge(p reflect.Value) {
+	if p.IsNil() || !c.budget.limited(p.Type().Elem()) {
+		return
+	}
+	c.scan(address(p.Pointer()), p.Elem(), true)
+}
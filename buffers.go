@@ -0,0 +1,47 @@
+package memsize
+
+import "reflect"
+
+// bufferTypes are the standard library types whose buffers are attributed to the
+// type itself by Options.AttributeBuffers. All of them keep their buffer in a slice
+// behind an unexported field.
+var bufferTypes = map[string][]string{
+	"bufio":    {"Reader", "Writer"},
+	"bytes":    {"Buffer"},
+	"math/big": {"Int", "Float", "Rat"},
+	"strings":  {"Builder"},
+}
+
+// isBufferType reports whether typ is one of bufferTypes.
+func isBufferType(typ reflect.Type) bool {
+	for _, name := range bufferTypes[typ.PkgPath()] {
+		if typ.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scanBuffer scans a value of a buffer type. With Options.AttributeBuffers, the
+// memory referenced by the value is recorded for the buffer type instead of being
+// returned as extra memory of the enclosing value. The value itself remains part
+// of the enclosing value. Values which are objects of their own, e.g. referenced by
+// pointer, are recorded by scan as usual.
+func (c *scanState) scanBuffer(addr address, v reflect.Value) uintptr {
+	if !c.buffers || c.owner == v.Type() || c.unfocused(v.Type()) {
+		return c.scanStruct(addr, v)
+	}
+	outer := c.obj
+	c.obj = objStats{}
+	extra := c.scanStruct(addr, v)
+	obj := c.obj
+	c.obj = outer
+	if c.split > 1 {
+		extra /= c.split
+	}
+	c.s.addValue(v, 0, extra, obj)
+	if c.owner != nil && c.ownership {
+		c.s.addOwnership(c.owner, v.Type(), extra)
+	}
+	return 0
+}
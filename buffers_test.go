@@ -0,0 +1,124 @@
+package memsize
+
+import (
+	"bufio"
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// TestBufferTypes checks that the buffer types keep their buffers in slices, which
+// are found by a regular scan.
+func TestBufferTypes(t *testing.T) {
+	types := []reflect.Type{
+		reflect.TypeOf(bufio.Reader{}),
+		reflect.TypeOf(bufio.Writer{}),
+		reflect.TypeOf(bytes.Buffer{}),
+		reflect.TypeOf(big.Int{}),
+		reflect.TypeOf(big.Float{}),
+		reflect.TypeOf(big.Rat{}),
+		reflect.TypeOf(strings.Builder{}),
+	}
+	var hasSlice func(reflect.Type) bool
+	hasSlice = func(typ reflect.Type) bool {
+		switch typ.Kind() {
+		case reflect.Slice:
+			return true
+		case reflect.Struct:
+			for i := 0; i < typ.NumField(); i++ {
+				if hasSlice(typ.Field(i).Type) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, typ := range types {
+		if !isBufferType(typ) {
+			t.Errorf("%v is not a buffer type", typ)
+		}
+		if !hasSlice(typ) {
+			t.Errorf("%v doesn't contain a slice", typ)
+		}
+	}
+}
+
+func TestAttributeBuffers(t *testing.T) {
+	type conn struct {
+		id  int
+		in  bytes.Buffer
+		out strings.Builder
+		n   big.Int
+		rd  *bufio.Reader
+	}
+	v := &conn{rd: bufio.NewReaderSize(strings.NewReader(""), 4096)}
+	v.in.Grow(1000)
+	v.out.Grow(500)
+	v.n.Lsh(big.NewInt(1), 1000)
+
+	plain := Scan(v)
+	sizes := ScanWithOptions(v, Options{AttributeBuffers: true, Ownership: true})
+	if sizes.Total != plain.Total {
+		t.Fatalf("total %d differs from total without attribution %d", sizes.Total, plain.Total)
+	}
+	tConn := reflect.TypeOf(*v)
+	if got, want := sizes.ByType[tConn].Shallow, plain.ByType[tConn].Shallow; got != want {
+		t.Errorf("shallow size of enclosing type %d, want %d", got, want)
+	}
+	var buffers uintptr
+	for _, typ := range []reflect.Type{reflect.TypeOf(bytes.Buffer{}), reflect.TypeOf(strings.Builder{}), reflect.TypeOf(big.Int{})} {
+		ts := sizes.ByType[typ]
+		if ts == nil || ts.Count != 1 || ts.Shallow != 0 || ts.Referenced == 0 {
+			t.Errorf("wrong stats for %v: %+v", typ, ts)
+			continue
+		}
+		if own := sizes.Ownership[tConn][typ]; own != ts.Total {
+			t.Errorf("ownership of %v %d, want %d", typ, own, ts.Total)
+		}
+		buffers += ts.Total
+	}
+	if got, want := sizes.ByType[tConn].Referenced, plain.ByType[tConn].Referenced-buffers; got != want {
+		t.Errorf("referenced memory of enclosing type %d, want %d", got, want)
+	}
+	// The bufio.Reader is referenced by pointer, so it is listed in both cases.
+	tReader := reflect.TypeOf(bufio.Reader{})
+	if !reflect.DeepEqual(sizes.ByType[tReader], plain.ByType[tReader]) {
+		t.Errorf("bufio.Reader stats differ: %+v, want %+v", sizes.ByType[tReader], plain.ByType[tReader])
+	}
+}
+
+func TestArrayOfStructsDedup(t *testing.T) {
+	type elem struct {
+		b   []byte
+		buf bytes.Buffer
+	}
+	shared := make([]byte, 100)
+	var arr [4]elem
+	for i := range arr {
+		arr[i].b = shared[i*10 : i*10+10] // all share one backing array
+		arr[i].buf.Grow(64)
+	}
+	sizes := Scan(&arr)
+	bufs := uintptr(0)
+	for i := range arr {
+		bufs += uintptr(arr[i].buf.Cap())
+	}
+	if want := reflect.TypeOf(arr).Size() + 100 + bufs; sizes.Total != want {
+		t.Errorf("array total %d, want %d", sizes.Total, want)
+	}
+	// The same in a slice, which also references the backing array of shared.
+	s := &struct {
+		elems  []elem
+		shared []byte
+	}{arr[:], shared}
+	sizes = ScanWithOptions(s, Options{AttributeBuffers: true})
+	if want := unsafe.Sizeof(*s) + reflect.TypeOf(arr).Size() + 100 + bufs; sizes.Total != want {
+		t.Errorf("slice total %d, want %d", sizes.Total, want)
+	}
+	if ts := sizes.ByType[reflect.TypeOf(bytes.Buffer{})]; ts == nil || ts.Count != 4 || ts.Total != bufs {
+		t.Errorf("wrong buffer stats %+v", ts)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build go1.18
+// +build go1.18
+
+package memsize
+
+import "runtime/debug"
+
+// readBuildInfo reads the build information embedded in the binary.
+func readBuildInfo() *BuildInfo {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	b := &BuildInfo{
+		Path:    bi.Path,
+		Module:  bi.Main.Path,
+		Version: bi.Main.Version,
+		Sum:     bi.Main.Sum,
+	}
+	for _, s := range bi.Settings {
+		if b.Settings == nil {
+			b.Settings = make(map[string]string, len(bi.Settings))
+		}
+		b.Settings[s.Key] = s.Value
+	}
+	return b
+}
@@ -0,0 +1,10 @@
+//go:build !go1.18
+// +build !go1.18
+
+package memsize
+
+// readBuildInfo returns nil because the build settings are only available in Go
+// 1.18 and later.
+func readBuildInfo() *BuildInfo {
+	return nil
+}
@@ -0,0 +1,55 @@
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// chanRecvxOffset is the offset of the receive index in the runtime's channel
+// struct, or zero if it couldn't be determined. The struct is defined as
+//
+//	type hchan struct {
+//		qcount   uint
+//		dataqsiz uint
+//		buf      unsafe.Pointer
+//		elemsize uint16
+//		closed   uint32
+//		timer    *timer // Go 1.23+
+//		elemtype *_type
+//		sendx    uint
+//		recvx    uint
+//		...
+//	}
+//
+// Since the layout depends on the Go version, the offset is found by probing a
+// channel with known indexes.
+var chanRecvxOffset = findChanRecvx()
+
+func findChanRecvx() uintptr {
+	if !haveInternals {
+		return 0
+	}
+	ch := make(chan int, 7)
+	for i := 0; i < 6; i++ {
+		ch <- i
+	}
+	for i := 0; i < 4; i++ {
+		<-ch
+	}
+	const wantSendx, wantRecvx = 6, 4
+	hchan := unsafe.Pointer(reflect.ValueOf(ch).Pointer())
+	w := unsafe.Sizeof(uint(0))
+	for off := 3 * w; off < 10*w; off += w {
+		sendx := *(*uint)(unsafe.Pointer(uintptr(hchan) + off))
+		recvx := *(*uint)(unsafe.Pointer(uintptr(hchan) + off + w))
+		if sendx == wantSendx && recvx == wantRecvx {
+			return off + w
+		}
+	}
+	return 0
+}
+
+// chanRecvx returns the buffer index of the next value received from the channel.
+func chanRecvx(hchan unsafe.Pointer) uint {
+	return *(*uint)(unsafe.Pointer(uintptr(hchan) + chanRecvxOffset))
+}
@@ -0,0 +1,67 @@
+package memsize
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChanStats summarizes the buffers of all channels of one type.
+type ChanStats struct {
+	Count uintptr // number of non-nil channels
+	Cap   uintptr // total capacity of all channels
+	Len   uintptr // number of buffered elements in all channels
+	// BufferBytes is the memory of the channel buffers, i.e. the capacity times the
+	// element size. ElemBytes is the memory referenced by the buffered elements
+	// which wasn't counted before, e.g. the objects sent as pointers.
+	BufferBytes uintptr
+	ElemBytes   uintptr
+}
+
+func (c *scanState) addChanStats(v reflect.Value, elemBytes uintptr) {
+	cs := c.s.Chans[v.Type()]
+	if cs == nil {
+		cs = new(ChanStats)
+		c.s.Chans[v.Type()] = cs
+	}
+	cs.Count++
+	cs.Cap += uintptr(v.Cap())
+	cs.Len += uintptr(v.Len())
+	cs.BufferBytes += uintptr(v.Cap()) * v.Type().Elem().Size()
+	cs.ElemBytes += elemBytes
+}
+
+// ChanReport returns the statistics recorded by Options.ChanStats, ordered by
+// decreasing memory of buffers and elements. Channel types whose buffers are all
+// full are marked with "full", which usually means the receiving side can't keep
+// up.
+func (s Sizes) ChanReport() string {
+	types := make([]reflect.Type, 0, len(s.Chans))
+	for typ := range s.Chans {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		ci, cj := s.Chans[types[i]], s.Chans[types[j]]
+		if si, sj := ci.BufferBytes+ci.ElemBytes, cj.BufferBytes+cj.ElemBytes; si != sj {
+			return si > sj
+		}
+		return types[i].String() < types[j].String()
+	})
+	maxname := 0
+	for _, typ := range types {
+		if n := len(typ.String()); n > maxname {
+			maxname = n
+		}
+	}
+	var sb strings.Builder
+	for _, typ := range types {
+		cs := s.Chans[typ]
+		fmt.Fprintf(&sb, "%-*s  chans %d  len %d  cap %d  buffers %s  elements %s", maxname, typ, cs.Count, cs.Len, cs.Cap, HumanSize(cs.BufferBytes), HumanSize(cs.ElemBytes))
+		if cs.Cap > 0 && cs.Len == cs.Cap {
+			sb.WriteString("  full")
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
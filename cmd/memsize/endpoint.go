@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fjl/memsize/memsizerpc"
+)
+
+// endpoint is the interface to the target process.
+type endpoint interface {
+	Roots() ([]string, error)
+	Scan(root string) (*memsizerpc.ScanResult, error)
+}
+
+func dialEndpoint() (endpoint, error) {
+	switch {
+	case *httpFlag != "" && *rpcFlag != "":
+		return nil, errors.New("-http and -rpc can't be used together")
+	case *httpFlag != "":
+		base := *httpFlag
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		return &httpEndpoint{base: base}, nil
+	case *rpcFlag != "":
+		return memsizerpc.Dial("tcp", *rpcFlag)
+	default:
+		return noEndpoint{}, nil
+	}
+}
+
+// noEndpoint is used when no endpoint is configured.
+// Commands working on snapshot files don't need one.
+type noEndpoint struct{}
+
+var errNoEndpoint = errors.New("need -http or -rpc")
+
+func (noEndpoint) Roots() ([]string, error) {
+	return nil, errNoEndpoint
+}
+
+func (noEndpoint) Scan(root string) (*memsizerpc.ScanResult, error) {
+	return nil, errNoEndpoint
+}
+
+// httpEndpoint talks to the JSON API of memsizeui.Handler.
+type httpEndpoint struct {
+	base string
+}
+
+func (ep *httpEndpoint) Roots() ([]string, error) {
+	var roots []string
+	err := ep.call(http.MethodGet, "api/roots", &roots)
+	return roots, err
+}
+
+func (ep *httpEndpoint) Scan(root string) (*memsizerpc.ScanResult, error) {
+	res := new(memsizerpc.ScanResult)
+	if err := ep.call(http.MethodPost, "api/scan?root="+url.QueryEscape(root), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ep *httpEndpoint) call(method, path string, result interface{}) error {
+	req, err := http.NewRequest(method, ep.base+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
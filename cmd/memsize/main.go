@@ -0,0 +1,210 @@
+// Command memsize queries the memsize endpoint of a running process.
+//
+// The target process must serve either the memsizeui HTTP handler or the
+// memsizerpc gRPC scan service. Usage:
+//
+//	memsize -http http://127.0.0.1:8080/memsize/ roots
+//	memsize -rpc 127.0.0.1:6061 scan <root>
+//	memsize -rpc 127.0.0.1:6061 save <root> <file>
+//	memsize -rpc 127.0.0.1:6061 diff <old> <new>
+//	memsize -rpc 127.0.0.1:6061 watch [-interval 10s] <root> <type>
+//	memsize check [-total-bytes N] [-total-percent P] [-bytes N] [-percent P] <baseline> <current>
+//
+// The arguments of diff and check can be names of snapshot files created by save,
+// or root names, which are scanned. The check command compares against a
+// baseline snapshot and exits with status 1 when memory grew beyond the given
+// thresholds. When used with snapshot files only, it doesn't need an endpoint.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fjl/memsize"
+	"github.com/fjl/memsize/memsizerpc"
+)
+
+var (
+	httpFlag = flag.String("http", "", "URL of memsizeui handler")
+	rpcFlag  = flag.String("rpc", "", "TCP address of memsizerpc gRPC service")
+)
+
+var commands = map[string]func(endpoint, []string) error{
+	"roots": cmdRoots,
+	"scan":  cmdScan,
+	"save":  cmdSave,
+	"diff":  cmdDiff,
+	"watch": cmdWatch,
+	"check": cmdCheck,
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: memsize (-http URL | -rpc ADDR) roots|scan|save|diff|watch|check [args...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fatalf("unknown command %q", flag.Arg(0))
+	}
+	ep, err := dialEndpoint()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := cmd(ep, flag.Args()[1:]); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "memsize: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+func cmdRoots(ep endpoint, args []string) error {
+	roots, err := ep.Roots()
+	if err != nil {
+		return err
+	}
+	for _, name := range roots {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func cmdScan(ep endpoint, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: scan <root>")
+	}
+	res, err := ep.Scan(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Root: %s\nDate: %v\nDuration: %v\n\n%s", res.Root, res.Date, res.Duration, res.Sizes.Report())
+	return nil
+}
+
+func cmdSave(ep endpoint, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: save <root> <file>")
+	}
+	res, err := ep.Scan(args[0])
+	if err != nil {
+		return err
+	}
+	enc, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(args[1], enc, 0644)
+}
+
+func cmdDiff(ep endpoint, args []string) error {
+	var opts memsize.DeltaOptions
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.BoolVar(&opts.Color, "color", false, "color growing and shrinking types")
+	fs.Float64Var(&opts.Threshold.Percent, "percent", 0, "growth in percent above which types are colored red")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: diff [-color] [-percent P] <old> <new>")
+	}
+	old, err := loadOrScan(ep, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	new, err := loadOrScan(ep, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Print(new.Sizes.DeltaReport(old.Sizes, opts))
+	return nil
+}
+
+func cmdWatch(ep endpoint, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", 10*time.Second, "time between scans")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: watch [-interval D] <root> <type>")
+	}
+	root, typ := fs.Arg(0), fs.Arg(1)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		res, err := ep.Scan(root)
+		if err != nil {
+			return err
+		}
+		ts := res.Sizes.ByType[typ]
+		fmt.Printf("%s  count %d  total %s\n", res.Date.Format(time.RFC3339), ts.Count, memsize.HumanSize(ts.Total))
+		<-ticker.C
+	}
+}
+
+func cmdCheck(ep endpoint, args []string) error {
+	var th memsize.Thresholds
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Var(sizeFlag{&th.Total.Bytes}, "total-bytes", "maximum growth of total size in bytes")
+	fs.Float64Var(&th.Total.Percent, "total-percent", 0, "maximum growth of total size in percent")
+	fs.Var(sizeFlag{&th.Default.Bytes}, "bytes", "maximum growth of any type in bytes")
+	fs.Float64Var(&th.Default.Percent, "percent", 0, "maximum growth of any type in percent")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: check [flags] <baseline> <current>")
+	}
+	baseline, err := loadOrScan(ep, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	current, err := loadOrScan(ep, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := memsize.CheckBaseline(baseline.Sizes, current.Sizes, th); err != nil {
+		return err
+	}
+	fmt.Println("OK:", current.Sizes)
+	return nil
+}
+
+// sizeFlag is a flag.Value for byte counts.
+type sizeFlag struct{ v *uintptr }
+
+func (f sizeFlag) String() string {
+	if f.v == nil {
+		return "0"
+	}
+	return strconv.FormatUint(uint64(*f.v), 10)
+}
+
+func (f sizeFlag) Set(s string) error {
+	n, err := strconv.ParseUint(s, 10, 64)
+	*f.v = uintptr(n)
+	return err
+}
+
+// loadOrScan reads a snapshot file. If no file of the given name exists,
+// arg is treated as a root name and scanned.
+func loadOrScan(ep endpoint, arg string) (*memsizerpc.ScanResult, error) {
+	data, err := ioutil.ReadFile(arg)
+	if os.IsNotExist(err) {
+		return ep.Scan(arg)
+	} else if err != nil {
+		return nil, err
+	}
+	res := new(memsizerpc.ScanResult)
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, fmt.Errorf("invalid snapshot file %s: %v", arg, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+)
+
+// CyclePath is a reference cycle found during the scan. It lists the types of the
+// objects on the cycle, starting with the object which is referenced again by the
+// last one.
+//
+// A cycle is found when a pointer to the start of an object refers back to an
+// object which is still being scanned. Each such pointer counts as one cycle, and
+// the cycle is counted for the type of the object it refers to. The number of
+// cycles found thus depends on the order of traversal.
+type CyclePath []reflect.Type
+
+// String returns the cycle in the form "A -> B -> A".
+func (p CyclePath) String() string {
+	names := make([]string, 0, len(p)+1)
+	for _, typ := range p {
+		names = append(names, typ.String())
+	}
+	if len(p) > 0 {
+		names = append(names, p[0].String())
+	}
+	return strings.Join(names, " -> ")
+}
+
+// cycleTracker tracks the objects on the current traversal path.
+type cycleTracker struct {
+	active   map[address]int // stack index of objects being scanned
+	stack    []reflect.Type
+	maxPaths int
+}
+
+func (t *cycleTracker) push(addr address, typ reflect.Type) {
+	t.active[addr] = len(t.stack)
+	t.stack = append(t.stack, typ)
+}
+
+func (t *cycleTracker) pop(addr address) {
+	delete(t.active, addr)
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// checkCycle is called when an object is reached again. It records a cycle if the
+// object is still being scanned.
+func (c *scanState) checkCycle(addr address, typ reflect.Type) {
+	i, ok := c.cycles.active[addr]
+	if !ok || c.cycles.stack[i] != typ {
+		return
+	}
+	c.s.Cycles[typ]++
+	if len(c.s.CyclePaths) < c.cycles.maxPaths {
+		path := make(CyclePath, len(c.cycles.stack)-i)
+		copy(path, c.cycles.stack[i:])
+		c.s.CyclePaths = append(c.s.CyclePaths, path)
+	}
+}
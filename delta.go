@@ -0,0 +1,125 @@
+package memsize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DeltaOptions configures DeltaReport.
+type DeltaOptions struct {
+	// Color enables ANSI coloring of the report. Lines of shrinking types are green
+	// and lines of new types and types growing beyond Threshold are red. The zero
+	// Threshold colors all growth.
+	Color     bool
+	Threshold Tolerance
+
+	// MaxTypes limits the number of types in the report. Only the largest
+	// types are included. Zero means no limit.
+	MaxTypes int
+}
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// DeltaReport returns a human-readable report like Report, with additional columns
+// showing the change of count and size since prev.
+func (s Sizes) DeltaReport(prev Sizes, opts DeltaOptions) string {
+	return s.Snapshot().DeltaReport(prev.Snapshot(), opts)
+}
+
+// DeltaReport returns a report of the changes since prev, see Sizes.DeltaReport.
+// Types which are only present in prev are listed after all others. When the
+// metadata of the snapshots shows that they were produced by different builds, the
+// report starts with a note identifying the builds.
+func (s Snapshot) DeltaReport(prev Snapshot, opts DeltaOptions) string {
+	names := s.TypeNames()
+	if opts.MaxTypes > 0 && opts.MaxTypes < len(names) {
+		names = names[:opts.MaxTypes]
+	}
+	for _, name := range prev.TypeNames() {
+		if _, ok := s.ByType[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	type line struct {
+		name                  string
+		count, total          string
+		countDelta, sizeDelta string
+		color                 string
+	}
+	mkline := func(name string, oldCount, count, old, total uintptr) line {
+		l := line{
+			name:       name,
+			count:      strconv.FormatUint(uint64(count), 10),
+			total:      HumanSize(total),
+			countDelta: deltaCount(oldCount, count),
+			sizeDelta:  deltaSize(old, total),
+		}
+		if opts.Color {
+			switch {
+			case total < old:
+				l.color = ansiGreen
+			case total > old && (old == 0 || opts.Threshold == Tolerance{} || opts.Threshold.exceeded(old, total)):
+				l.color = ansiRed
+			}
+		}
+		return l
+	}
+	var oldCount, count uintptr
+	for _, ts := range prev.ByType {
+		oldCount += ts.Count
+	}
+	for _, ts := range s.ByType {
+		count += ts.Count
+	}
+	lines := []line{mkline("ALL", oldCount, count, prev.Total, s.Total)}
+	for _, name := range names {
+		o, n := prev.ByType[name], s.ByType[name]
+		l := mkline(name, o.Count, n.Count, o.Total, n.Total)
+		if !n.Exact() {
+			l.total = estimatedMark + l.total
+		}
+		lines = append(lines, l)
+	}
+
+	// Compute column widths and render.
+	var w [5]int
+	for _, l := range lines {
+		for i, col := range []string{l.name, l.count, l.total, l.countDelta, l.sizeDelta} {
+			if len(col) > w[i] {
+				w[i] = len(col)
+			}
+		}
+	}
+	var sb strings.Builder
+	if prev.Metadata != nil && s.Metadata != nil && !prev.Metadata.SameBuild(s.Metadata) {
+		fmt.Fprintf(&sb, "builds differ: %v -> %v\n", prev.Metadata, s.Metadata)
+	}
+	for _, l := range lines {
+		sb.WriteString(l.color)
+		fmt.Fprintf(&sb, "%-*s  %*s  %*s  %*s  %*s", w[0], l.name, w[1], l.count, w[2], l.total, w[3], l.countDelta, w[4], l.sizeDelta)
+		if l.color != "" {
+			sb.WriteString(ansiReset)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func deltaCount(old, new uintptr) string {
+	if new >= old {
+		return "+" + strconv.FormatUint(uint64(new-old), 10)
+	}
+	return "-" + strconv.FormatUint(uint64(old-new), 10)
+}
+
+func deltaSize(old, new uintptr) string {
+	if new >= old {
+		return "+" + HumanSize(new-old)
+	}
+	return "-" + HumanSize(old-new)
+}
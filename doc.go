@@ -11,6 +11,29 @@ This traverses the graph, counting sizes per type.
     fmt.Println(sizes.Total)
 
 memsize can handle cycles just fine and tracks both private and public struct fields.
-Unfortunately function closures cannot be inspected in any way.
+Unfortunately the variables captured by function closures cannot be traversed.
+The closures found by the scan are listed in Sizes.Unscannable, along with the
+memory directly referenced by their variables where the runtime can tell.
+
+memsize accesses Go runtime internals to stop the world during a scan.
+Where this is not possible, build with the 'purego' tag to get a portable
+version which uses only safe APIs. The portable version is always used on js and
+wasip1. It does not stop the world and does not scan the contents of channel
+buffers, so results are slightly less accurate. On Go releases whose runtime
+internals are unknown to memsize, the world isn't stopped either. Use TryScan to
+detect this case.
+
+Since Go 1.23, the linker rejects the reference to the runtime function which
+stops the world. To stop the world on these releases, build with the 'memsize_stw'
+tag and -ldflags=-checklinkname=0:
+
+    go build -tags memsize_stw -ldflags=-checklinkname=0
+
+Otherwise, the world isn't stopped and TryScan reports ErrUnsupportedGoVersion.
+
+Large scans can keep the world stopped for a long time. Set
+Options.MaxPausePerSlice to let other goroutines run periodically during the scan,
+at the cost of consistency. This is meant for data which is modified rarely while
+it is scanned.
 */
 package memsize
@@ -0,0 +1,34 @@
+package memsize
+
+import "unsafe"
+
+// AddressRange is a region of memory [Start, End).
+type AddressRange struct {
+	Start, End uintptr
+}
+
+// RangeOf returns the address range of the backing array of b, e.g. a memory-mapped
+// file.
+func RangeOf(b []byte) AddressRange {
+	if cap(b) == 0 {
+		return AddressRange{}
+	}
+	b = b[:cap(b)]
+	start := uintptr(unsafe.Pointer(&b[0]))
+	return AddressRange{start, start + uintptr(len(b))}
+}
+
+// Contains reports whether addr is in the range.
+func (r AddressRange) Contains(addr uintptr) bool {
+	return addr >= r.Start && addr < r.End
+}
+
+// excluded reports whether addr is in one of the ranges of Options.ExcludeRanges.
+func (c *scanState) excluded(addr uintptr) bool {
+	for _, r := range c.exclude {
+		if r.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package memsize
+
+import "reflect"
+
+// typeSet is a set of types, see Options.FocusTypes.
+type typeSet map[reflect.Type]struct{}
+
+func newTypeSet(types []reflect.Type) typeSet {
+	if len(types) == 0 {
+		return nil
+	}
+	set := make(typeSet, len(types))
+	for _, typ := range types {
+		set[typ] = struct{}{}
+	}
+	return set
+}
+
+// unfocused reports whether values of typ are left out of the result because
+// they aren't listed in Options.FocusTypes.
+func (c *scanState) unfocused(typ reflect.Type) bool {
+	if c.focus == nil {
+		return false
+	}
+	_, ok := c.focus[typ]
+	return !ok
+}
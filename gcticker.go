@@ -0,0 +1,60 @@
+package memsize
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// GCTicker delivers the time after garbage collection cycles, in the manner of
+// time.Ticker. Scanning right after a collection gives more comparable results
+// because little floating garbage is left in the heap.
+//
+// Cycles are detected using a finalizer, which runs on the finalizer goroutine
+// some time after the collection. Ticks are dropped while the previous tick has not
+// been received, and when less than the minimum interval has passed since the
+// previous tick.
+type GCTicker struct {
+	C <-chan time.Time
+
+	c           chan time.Time
+	minInterval time.Duration
+	last        time.Time // accessed by the finalizer only
+	stopped     uint32
+}
+
+// gcSentinel is the object whose finalizer detects collections. It contains a
+// pointer because finalizers of tiny pointer-free objects may never run.
+type gcSentinel struct {
+	t *GCTicker
+}
+
+// NewGCTicker creates a ticker which delivers ticks after garbage collection
+// cycles, at most once per minInterval.
+func NewGCTicker(minInterval time.Duration) *GCTicker {
+	c := make(chan time.Time, 1)
+	t := &GCTicker{C: c, c: c, minInterval: minInterval}
+	runtime.SetFinalizer(&gcSentinel{t}, gcTick)
+	return t
+}
+
+// Stop turns off the ticker. Like time.Ticker, it doesn't close the channel.
+func (t *GCTicker) Stop() {
+	atomic.StoreUint32(&t.stopped, 1)
+}
+
+func gcTick(s *gcSentinel) {
+	t := s.t
+	if atomic.LoadUint32(&t.stopped) != 0 {
+		return
+	}
+	if now := time.Now(); now.Sub(t.last) >= t.minInterval {
+		select {
+		case t.c <- now:
+			t.last = now
+		default:
+		}
+	}
+	// Resurrect the sentinel to be notified of the next cycle.
+	runtime.SetFinalizer(s, gcTick)
+}
@@ -0,0 +1,58 @@
+package memsize
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGCTicker(t *testing.T) {
+	ticker := NewGCTicker(0)
+	deadline := time.After(5 * time.Second)
+	for received := 0; received < 2; {
+		runtime.GC()
+		select {
+		case <-ticker.C:
+			received++
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("received %d ticks, want 2", received)
+		}
+	}
+	ticker.Stop()
+	// Wait for a finalizer which may have been running concurrently with Stop.
+	runtime.GC()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-ticker.C:
+	default:
+	}
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+	}
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-ticker.C:
+		t.Fatal("tick after Stop")
+	default:
+	}
+}
+
+func TestGCTickerMinInterval(t *testing.T) {
+	ticker := NewGCTicker(time.Hour)
+	defer ticker.Stop()
+	// The first cycle is delivered, later ones are within the interval.
+	var received int
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		time.Sleep(5 * time.Millisecond)
+		select {
+		case <-ticker.C:
+			received++
+		default:
+		}
+	}
+	if received != 1 {
+		t.Fatalf("received %d ticks, want 1", received)
+	}
+}
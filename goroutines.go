@@ -0,0 +1,63 @@
+package memsize
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+)
+
+// GoroutineStats summarizes the goroutines at the time of a scan.
+type GoroutineStats struct {
+	Count      int
+	StackBytes uint64 // memory used by goroutine stacks
+	// ByFunction is set when Options.GoroutineStacks is enabled. It holds the
+	// number of goroutines per start function.
+	ByFunction map[string]int `json:",omitempty"`
+}
+
+// captureGoroutines reads the goroutine statistics. It must be called while the
+// world is running.
+func captureGoroutines(stacks bool) GoroutineStats {
+	g := readGoroutineMetrics()
+	if stacks {
+		g.ByFunction = goroutineFunctions(allStacks())
+	}
+	return g
+}
+
+// allStacks returns the stack traces of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineFunctions counts goroutines by their start function, which is the
+// last function in each trace.
+func goroutineFunctions(dump []byte) map[string]int {
+	funcs := make(map[string]int)
+	for _, trace := range bytes.Split(dump, []byte("\n\n")) {
+		var last string
+		for _, line := range strings.Split(string(trace), "\n") {
+			if line == "" || line[0] == '\t' || strings.HasPrefix(line, "goroutine ") ||
+				strings.HasPrefix(line, "created by ") || strings.HasPrefix(line, "...") {
+				continue
+			}
+			last = line
+		}
+		if last == "" {
+			continue
+		}
+		// Strip the argument list.
+		if i := strings.LastIndexByte(last, '('); i > 0 {
+			last = last[:i]
+		}
+		funcs[last]++
+	}
+	return funcs
+}
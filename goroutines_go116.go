@@ -0,0 +1,22 @@
+//go:build go1.16
+// +build go1.16
+
+package memsize
+
+import "runtime/metrics"
+
+func readGoroutineMetrics() GoroutineStats {
+	samples := []metrics.Sample{
+		{Name: "/sched/goroutines:goroutines"},
+		{Name: "/memory/classes/heap/stacks:bytes"},
+	}
+	metrics.Read(samples)
+	var g GoroutineStats
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		g.Count = int(samples[0].Value.Uint64())
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		g.StackBytes = samples[1].Value.Uint64()
+	}
+	return g
+}
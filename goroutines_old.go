@@ -0,0 +1,12 @@
+//go:build !go1.16
+// +build !go1.16
+
+package memsize
+
+import "runtime"
+
+func readGoroutineMetrics() GoroutineStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return GoroutineStats{Count: runtime.NumGoroutine(), StackBytes: ms.StackInuse}
+}
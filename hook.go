@@ -0,0 +1,89 @@
+package memsize
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Action is the result of Options.OnValue.
+type Action int
+
+const (
+	// Continue scans the value and everything it references.
+	Continue Action = iota
+	// Skip prevents the scan from descending into the value. The value itself is
+	// still counted as part of its parent, but nothing it references is accessed.
+	Skip
+)
+
+// visit calls Options.OnValue and reports whether v should be scanned.
+func (c *scanState) visit(v reflect.Value) bool {
+	return c.onValue == nil || c.onValue(string(c.path), v) != Skip
+}
+
+// enterField appends a struct field to the path of the current value. It returns
+// the length of the path to be restored by leavePath.
+func (c *scanState) enterField(name string) int {
+	n := len(c.path)
+	if c.onValue != nil {
+		c.path = append(append(c.path, '.'), name...)
+	}
+	return n
+}
+
+// enterIndex appends an array, slice or channel buffer index to the path.
+func (c *scanState) enterIndex(i int) int {
+	n := len(c.path)
+	if c.onValue != nil {
+		c.path = append(strconv.AppendInt(append(c.path, '['), int64(i), 10), ']')
+	}
+	return n
+}
+
+// enterKey appends a map key to the path.
+func (c *scanState) enterKey(k reflect.Value) int {
+	n := len(c.path)
+	if c.onValue != nil {
+		c.path = append(appendKey(append(c.path, '['), k), ']')
+	}
+	return n
+}
+
+// appendKey formats a map key without calling any of its methods, which may block
+// while the world is stopped. Keys of basic kinds are formatted by their value,
+// pointers by their address and other keys by their type.
+func appendKey(b []byte, k reflect.Value) []byte {
+	switch k.Kind() {
+	case reflect.String:
+		return append(b, k.String()...)
+	case reflect.Bool:
+		return strconv.AppendBool(b, k.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(b, k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(b, k.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(b, k.Float(), 'g', -1, k.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		c, bits := k.Complex(), k.Type().Bits()/2
+		b = strconv.AppendFloat(append(b, '('), real(c), 'g', -1, bits)
+		if !math.Signbit(imag(c)) {
+			b = append(b, '+')
+		}
+		return append(strconv.AppendFloat(b, imag(c), 'g', -1, bits), "i)"...)
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return strconv.AppendUint(append(b, "0x"...), uint64(k.Pointer()), 16)
+	case reflect.Interface:
+		if k.IsNil() {
+			return append(b, "<nil>"...)
+		}
+		return appendKey(b, k.Elem())
+	default:
+		return append(b, k.Type().String()...)
+	}
+}
+
+func (c *scanState) leavePath(n int) {
+	c.path = c.path[:n]
+}
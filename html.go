@@ -0,0 +1,170 @@
+package memsize
+
+import (
+	"html/template"
+	"io"
+	"sort"
+)
+
+// WriteHTML writes a standalone HTML page showing the result. The page contains a
+// sortable, filterable table of all types. When the ownership matrix was recorded
+// (see Options.Ownership), the rows can be expanded to show the types referenced by
+// each type. The table also shows the average fan-out of each type, see
+// TypeSize.FanOut.
+func (s Sizes) WriteHTML(w io.Writer) error {
+	return s.Snapshot().WriteHTML(w)
+}
+
+// WriteHTML writes a standalone HTML page showing the snapshot, see Sizes.WriteHTML.
+func (s Snapshot) WriteHTML(w io.Writer) error {
+	type child struct {
+		Name string
+		Size uintptr
+	}
+	type row struct {
+		Name     string
+		TypeSize TypeSize
+		Percent  float64
+		FanOut   float64
+		Children []child
+	}
+	data := struct {
+		Snapshot Snapshot
+		Rows     []row
+	}{Snapshot: s}
+	for _, name := range s.TypeNames() {
+		r := row{Name: name, TypeSize: s.ByType[name]}
+		r.FanOut = r.TypeSize.FanOut()
+		if s.Total > 0 {
+			r.Percent = float64(r.TypeSize.Total) * 100 / float64(s.Total)
+		}
+		for cname, size := range s.Ownership[name] {
+			r.Children = append(r.Children, child{cname, size})
+		}
+		sort.Slice(r.Children, func(i, j int) bool {
+			if r.Children[i].Size != r.Children[j].Size {
+				return r.Children[i].Size > r.Children[j].Size
+			}
+			return r.Children[i].Name < r.Children[j].Name
+		})
+		data.Rows = append(data.Rows, r)
+	}
+	return htmlReport.Execute(w, data)
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"humansize": HumanSize,
+}).Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>memsize report</title>
+		<style>
+		body {
+			font-family: sans-serif;
+		}
+		table {
+			border-collapse: collapse;
+		}
+		th {
+			cursor: pointer;
+			text-align: left;
+			border-bottom: 1px solid #999;
+		}
+		td, th {
+			padding: 2pt 6pt;
+		}
+		td.num {
+			text-align: right;
+			font-family: monospace;
+		}
+		tr.children td {
+			padding-left: 24pt;
+			color: #555;
+		}
+		tr.expandable td:first-child {
+			cursor: pointer;
+		}
+		</style>
+	</head>
+	<body>
+		<p>
+			Total: <b>{{humansize .Snapshot.Total}}</b> in {{len .Rows}} types.
+			{{- if .Snapshot.Partial}} <b>The scan was interrupted, results are incomplete.</b>{{end}}
+		</p>
+		<p><input id="filter" type="search" placeholder="Filter types" size="40"></p>
+		<table id="report">
+			<thead>
+				<tr>
+					<th data-col="0">Type</th>
+					<th data-col="1">Count</th>
+					<th data-col="2">Shallow</th>
+					<th data-col="3">Referenced</th>
+					<th data-col="4">Total</th>
+					<th data-col="5">%</th>
+					<th data-col="6" title="Average number of references followed per value">Fan-out</th>
+				</tr>
+			</thead>
+			{{- range .Rows}}
+			<tbody data-name="{{.Name}}">
+				<tr{{if .Children}} class="expandable"{{end}}>
+					<td data-value="{{.Name}}">{{if .Children}}&#9656; {{end}}{{.Name}}</td>
+					<td class="num" data-value="{{.TypeSize.Count}}">{{.TypeSize.Count}}</td>
+					<td class="num" data-value="{{.TypeSize.Shallow}}">{{humansize .TypeSize.Shallow}}</td>
+					<td class="num" data-value="{{.TypeSize.Referenced}}">{{humansize .TypeSize.Referenced}}</td>
+					<td class="num" data-value="{{.TypeSize.Total}}">{{humansize .TypeSize.Total}}</td>
+					<td class="num" data-value="{{.Percent}}">{{printf "%.2f" .Percent}}</td>
+					<td class="num" data-value="{{.FanOut}}">{{printf "%.2f" .FanOut}}</td>
+				</tr>
+				{{- range .Children}}
+				<tr class="children" hidden>
+					<td>{{.Name}}</td>
+					<td></td><td></td><td></td>
+					<td class="num">{{humansize .Size}}</td>
+					<td></td><td></td>
+				</tr>
+				{{- end}}
+			</tbody>
+			{{- end}}
+		</table>
+		<script>
+		(function() {
+			var table = document.getElementById("report");
+			var groups = Array.prototype.slice.call(table.tBodies);
+			document.getElementById("filter").addEventListener("input", function() {
+				var q = this.value.toLowerCase();
+				groups.forEach(function(g) {
+					g.hidden = g.dataset.name.toLowerCase().indexOf(q) < 0;
+				});
+			});
+			var order = {};
+			table.tHead.addEventListener("click", function(ev) {
+				var col = ev.target.dataset.col;
+				if (col === undefined) {
+					return;
+				}
+				var dir = order[col] = -(order[col] || 1);
+				groups.sort(function(a, b) {
+					var x = a.rows[0].cells[col].dataset.value, y = b.rows[0].cells[col].dataset.value;
+					if (col !== "0") {
+						x = parseFloat(x);
+						y = parseFloat(y);
+					}
+					return x < y ? -dir : x > y ? dir : 0;
+				});
+				groups.forEach(function(g) { table.appendChild(g); });
+			});
+			table.addEventListener("click", function(ev) {
+				var tr = ev.target.closest("tr.expandable");
+				if (!tr) {
+					return;
+				}
+				for (var r = tr.nextElementSibling; r; r = r.nextElementSibling) {
+					r.hidden = !r.hidden;
+				}
+			});
+		})();
+		</script>
+	</body>
+</html>
+`))
@@ -0,0 +1,26 @@
+package memsize
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	v := &structmultiptr{s1: &structptr{cld: &structptr{}}, u1: &structuint32ptr{x: new(uint32)}}
+	var buf bytes.Buffer
+	if err := ScanWithOptions(v, Options{Ownership: true}).WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		`<tbody data-name="memsize.structmultiptr">`,
+		`<tr class="expandable">`,
+		`<td>memsize.structuint32ptr</td>`,
+		`<td class="num" data-value="2">2.00</td>`, // fan-out of structmultiptr
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package memsize
+
+import "sort"
+
+// NameTable assigns indexes to type names. A table can be shared by several
+// interned snapshots, so that equal names have equal indexes in all of them.
+//
+// The zero value is an empty table ready to use.
+type NameTable struct {
+	Names []string
+	index map[string]int
+}
+
+// Index returns the index of name, adding it to the table if necessary.
+func (t *NameTable) Index(name string) int {
+	if t.index == nil {
+		t.index = make(map[string]int, len(t.Names))
+		for i, n := range t.Names {
+			t.index[n] = i
+		}
+	}
+	i, ok := t.index[name]
+	if !ok {
+		i = len(t.Names)
+		t.Names = append(t.Names, name)
+		t.index[name] = i
+	}
+	return i
+}
+
+// InternedSnapshot is a compact serializable form of Snapshot, in which the type
+// names of ByType, Ownership and Cycles are stored once in a table and referenced by
+// their index. All other fields are those of the embedded Snapshot.
+type InternedSnapshot struct {
+	Names []string `json:"names"`
+	Snapshot
+	ByType    []InternedTypeSize  `json:"byType"`
+	Ownership []InternedOwnership `json:"ownership,omitempty"`
+	Cycles    []InternedCount     `json:"cycles,omitempty"`
+}
+
+// InternedTypeSize is an entry of InternedSnapshot.ByType.
+type InternedTypeSize struct {
+	Type int `json:"type"`
+	TypeSize
+}
+
+// InternedOwnership is an entry of InternedSnapshot.Ownership. It holds the memory
+// of type Child referenced by type Parent.
+type InternedOwnership struct {
+	Parent int     `json:"parent"`
+	Child  int     `json:"child"`
+	Size   uintptr `json:"size"`
+}
+
+// InternedCount is an entry of InternedSnapshot.Cycles.
+type InternedCount struct {
+	Type int     `json:"type"`
+	N    uintptr `json:"n"`
+}
+
+// Intern converts s to its interned form. Names are added to table, which may be
+// shared with other snapshots. If table is nil, a new table is used.
+func (s Snapshot) Intern(table *NameTable) InternedSnapshot {
+	if table == nil {
+		table = new(NameTable)
+	}
+	is := InternedSnapshot{Snapshot: s}
+	is.Snapshot.ByType, is.Snapshot.Ownership, is.Snapshot.Cycles = nil, nil, nil
+	// Add names in report order, so the largest types get the smallest indexes.
+	for _, name := range s.TypeNames() {
+		is.ByType = append(is.ByType, InternedTypeSize{table.Index(name), s.ByType[name]})
+	}
+	parents := make([]string, 0, len(s.Ownership))
+	for parent := range s.Ownership {
+		parents = append(parents, parent)
+	}
+	sort.Strings(parents)
+	for _, parent := range parents {
+		pi := table.Index(parent)
+		children := s.Ownership[parent]
+		for _, child := range sortedKeys(children) {
+			is.Ownership = append(is.Ownership, InternedOwnership{pi, table.Index(child), children[child]})
+		}
+	}
+	for _, name := range sortedKeys(s.Cycles) {
+		is.Cycles = append(is.Cycles, InternedCount{table.Index(name), s.Cycles[name]})
+	}
+	is.Names = table.Names
+	return is
+}
+
+// Expand converts is back to a Snapshot.
+func (is InternedSnapshot) Expand() Snapshot {
+	s := is.Snapshot
+	s.ByType = make(map[string]TypeSize, len(is.ByType))
+	for _, e := range is.ByType {
+		s.ByType[is.Names[e.Type]] = e.TypeSize
+	}
+	if len(is.Ownership) > 0 {
+		s.Ownership = make(map[string]map[string]uintptr)
+		for _, e := range is.Ownership {
+			parent := is.Names[e.Parent]
+			if s.Ownership[parent] == nil {
+				s.Ownership[parent] = make(map[string]uintptr)
+			}
+			s.Ownership[parent][is.Names[e.Child]] = e.Size
+		}
+	}
+	if len(is.Cycles) > 0 {
+		s.Cycles = make(map[string]uintptr, len(is.Cycles))
+		for _, e := range is.Cycles {
+			s.Cycles[is.Names[e.Type]] = e.N
+		}
+	}
+	return s
+}
+
+func sortedKeys(m map[string]uintptr) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
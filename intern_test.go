@@ -0,0 +1,59 @@
+package memsize
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestInternedSnapshot(t *testing.T) {
+	v := &struct {
+		a *structptr
+		b *struct16
+	}{&structptr{cld: &structptr{}}, &struct16{}}
+	snap := ScanWithOptions(v, Options{Ownership: true, Cycles: true}).Snapshot()
+
+	var table NameTable
+	is := snap.Intern(&table)
+	if len(is.Names) != len(snap.ByType) {
+		t.Fatalf("wrong number of names: %v", is.Names)
+	}
+	if is.Names[0] != snap.TypeNames()[0] {
+		t.Errorf("largest type %q not first in table %v", snap.TypeNames()[0], is.Names)
+	}
+
+	// Check that encoding round-trips.
+	enc, err := json.Marshal(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec InternedSnapshot
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if got := dec.Expand(); !reflect.DeepEqual(got, snap) {
+		t.Errorf("JSON round trip mismatch:\ngot  %+v\nwant %+v", got, snap)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(is); err != nil {
+		t.Fatal(err)
+	}
+	var gdec InternedSnapshot
+	if err := gob.NewDecoder(&buf).Decode(&gdec); err != nil {
+		t.Fatal(err)
+	}
+	if got := gdec.Expand(); !reflect.DeepEqual(got, snap) {
+		t.Errorf("gob round trip mismatch:\ngot  %+v\nwant %+v", got, snap)
+	}
+
+	// A shared table keeps the indexes of known names.
+	is2 := Scan(&struct16{}).Snapshot().Intern(&table)
+	if n := len(table.Names); n != len(is.Names) {
+		t.Errorf("table grew to %d names for known type", n)
+	}
+	if is2.ByType[0].Type != table.Index("memsize.struct16") {
+		t.Errorf("wrong index %d for struct16", is2.ByType[0].Type)
+	}
+}
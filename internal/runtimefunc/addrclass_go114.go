@@ -0,0 +1,92 @@
+//go:build go1.14 && !purego && !js && !wasip1
+// +build go1.14,!purego,!js,!wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+// HaveAddrClass reports whether CheckptrBase and FindObjectBase are available.
+const HaveAddrClass = true
+
+// CheckptrBase returns the base address of the heap object or global data segment
+// containing p. It returns 1 for the current goroutine's stack and zero for other
+// memory.
+//
+//go:linkname CheckptrBase runtime.checkptrBase
+func CheckptrBase(p uintptr) uintptr
+
+//go:linkname findObject runtime.findObject
+func findObject(p, refBase, refOff uintptr) (base uintptr, s unsafe.Pointer, objIndex uintptr)
+
+// FindObjectBase returns the base address of the heap object containing p, or
+// zero if p doesn't point into the heap.
+func FindObjectBase(p uintptr) uintptr {
+	base, _, _ := findObject(p, 0, 0)
+	return base
+}
+
+// maxSpanElemsizeOffset bounds the offset of the elemsize field in runtime.mspan.
+const maxSpanElemsizeOffset = 192
+
+// spanElemsizeOffset is the offset of the elemsize field in runtime.mspan, or -1
+// if it could not be found.
+var spanElemsizeOffset = findSpanElemsizeOffset()
+
+// HaveObjectSize reports whether ObjectSize is available.
+var HaveObjectSize = spanElemsizeOffset >= 0
+
+// probeSink makes the probe objects escape to the heap.
+var probeSink []interface{}
+
+// findSpanElemsizeOffset locates the elemsize field by looking at the spans of
+// objects from three different size classes.
+func findSpanElemsizeOffset() int {
+	var (
+		a = new([6]*byte)  // 48 byte size class
+		b = new([10]*byte) // 80 byte size class
+		c = new([14]*byte) // 112 byte size class
+	)
+	probeSink = []interface{}{a, b, c}
+	defer func() { probeSink = nil }()
+	objs := []struct {
+		p    uintptr
+		size uintptr
+	}{
+		{uintptr(unsafe.Pointer(a)), unsafe.Sizeof(*a)},
+		{uintptr(unsafe.Pointer(b)), unsafe.Sizeof(*b)},
+		{uintptr(unsafe.Pointer(c)), unsafe.Sizeof(*c)},
+	}
+	spans := make([]unsafe.Pointer, len(objs))
+	for i, o := range objs {
+		base, s, _ := findObject(o.p, 0, 0)
+		if base != o.p || s == nil {
+			return -1
+		}
+		spans[i] = s
+	}
+	offset := -1
+	for off := uintptr(0); off < maxSpanElemsizeOffset; off += unsafe.Sizeof(uintptr(0)) {
+		match := true
+		for i, o := range objs {
+			if *(*uintptr)(unsafe.Pointer(uintptr(spans[i]) + off)) != o.size {
+				match = false
+				break
+			}
+		}
+		if match {
+			offset = int(off)
+			break
+		}
+	}
+	return offset
+}
+
+// ObjectSize returns the size of the heap object containing p, or zero if p doesn't
+// point into the heap or HaveObjectSize is false.
+func ObjectSize(p uintptr) uintptr {
+	base, s, _ := findObject(p, 0, 0)
+	if base == 0 || s == nil || spanElemsizeOffset < 0 {
+		return 0
+	}
+	return *(*uintptr)(unsafe.Pointer(uintptr(s) + uintptr(spanElemsizeOffset)))
+}
@@ -0,0 +1,20 @@
+//go:build !go1.14 || purego || js || wasip1
+// +build !go1.14 purego js wasip1
+
+package runtimefunc
+
+const HaveAddrClass = false
+
+func CheckptrBase(p uintptr) uintptr {
+	panic("checkptrBase not available")
+}
+
+func FindObjectBase(p uintptr) uintptr {
+	panic("findObject not available")
+}
+
+var HaveObjectSize = false
+
+func ObjectSize(p uintptr) uintptr {
+	panic("object size not available")
+}
@@ -0,0 +1,177 @@
+//go:build go1.22 && !purego && !js && !wasip1
+// +build go1.22,!purego,!js,!wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+// Since Go 1.22, the pointer bitmap of heap objects up to ptrBits words is stored
+// at the end of their span, one bit per word of the span. Spans of larger objects
+// store a type pointer in a header of each object instead, which is not handled.
+// The GreenTea collector (Go 1.25+) places its inline mark bits between the bitmap
+// and the end of the span.
+const (
+	pageSize        = 8192
+	ptrSize         = unsafe.Sizeof(uintptr(0))
+	ptrBits         = 8 * ptrSize
+	maxHeapBitsSize = ptrSize * ptrBits
+)
+
+// Offsets of the startAddr and npages fields in runtime.mspan, which follow the
+// next, prev and list pointers.
+const (
+	spanStartAddrOffset = 3 * ptrSize
+	spanNpagesOffset    = 4 * ptrSize
+)
+
+// maxHeapBitsGap bounds the distance between the pointer bitmap and the end of the
+// span.
+const maxHeapBitsGap = 256
+
+var (
+	// spanClassOffset is the offset of the spanclass field in runtime.mspan and
+	// heapBitsGap is the distance between the pointer bitmap and the end of the span.
+	// Both are -1 if they could not be found.
+	spanClassOffset = findSpanClassOffset()
+	heapBitsGap     = findHeapBitsGap()
+)
+
+// HavePointerWords reports whether PointerWords is available.
+var HavePointerWords = spanClassOffset >= 0 && heapBitsGap >= 0
+
+// PointerWords calls fn with the offset of each word holding a pointer in the heap
+// object at p, which must be the base address of the object. It returns false if
+// the pointer bitmap of the object is unknown, e.g. for objects larger than 512
+// bytes or when HavePointerWords is false.
+func PointerWords(p unsafe.Pointer, fn func(off uintptr)) bool {
+	return HavePointerWords && pointerWords(p, uintptr(heapBitsGap), fn)
+}
+
+// pointerWords reads the bitmap for PointerWords. It is exempt from checkptr
+// instrumentation because the bitmap is outside of the object at p.
+//
+//go:nocheckptr
+func pointerWords(p unsafe.Pointer, gap uintptr, fn func(off uintptr)) bool {
+	base, s, _ := findObject(uintptr(p), 0, 0)
+	if base != uintptr(p) || s == nil {
+		return false
+	}
+	elemsize := *(*uintptr)(unsafe.Pointer(uintptr(s) + uintptr(spanElemsizeOffset)))
+	if elemsize < 2*ptrSize || elemsize > maxHeapBitsSize {
+		return false
+	}
+	if *(*uint8)(unsafe.Pointer(uintptr(s) + uintptr(spanClassOffset)))&1 != 0 {
+		return true // noscan span, the object has no pointers
+	}
+	start := *(*uintptr)(unsafe.Pointer(uintptr(s) + spanStartAddrOffset))
+	npages := *(*uintptr)(unsafe.Pointer(uintptr(s) + spanNpagesOffset))
+	spanSize := npages * pageSize
+	bitmap := start + spanSize - spanSize/ptrBits - gap
+	for i := uintptr(0); i < elemsize/ptrSize; i++ {
+		k := (base-start)/ptrSize + i
+		word := *(*uintptr)(unsafe.Pointer(uintptr(p) + (bitmap - base) + k/ptrBits*ptrSize))
+		if word>>(k%ptrBits)&1 != 0 {
+			fn(i * ptrSize)
+		}
+	}
+	return true
+}
+
+// heapBitsProbe is an object with a known pointer bitmap.
+type heapBitsProbe struct {
+	p    unsafe.Pointer
+	bits uintptr // bit i is set for pointer word i
+}
+
+func heapBitsProbes() []heapBitsProbe {
+	var (
+		a = new(struct { // 16 byte size class
+			x uintptr
+			p *byte
+		})
+		b = new(struct { // 32 byte size class
+			p    *byte
+			x, y uintptr
+			q    *byte
+		})
+		c = new(struct { // 48 byte size class
+			x    uintptr
+			p, q *byte
+			y, z uintptr
+			r    *byte
+		})
+	)
+	probeSink = []interface{}{a, b, c}
+	return []heapBitsProbe{
+		{unsafe.Pointer(a), 0x2},
+		{unsafe.Pointer(b), 0x9},
+		{unsafe.Pointer(c), 0x26},
+	}
+}
+
+// findSpanClassOffset locates the spanclass field by comparing the spans of objects
+// with and without pointers from three different size classes. The spanclass of the
+// span without pointers has the noscan bit set.
+func findSpanClassOffset() int {
+	if spanElemsizeOffset < 0 {
+		return -1
+	}
+	scan := heapBitsProbes()
+	noscan := []unsafe.Pointer{
+		unsafe.Pointer(new([2]uintptr)),
+		unsafe.Pointer(new([4]uintptr)),
+		unsafe.Pointer(new([6]uintptr)),
+	}
+	probeSink = append(probeSink, noscan[0], noscan[1], noscan[2])
+	defer func() { probeSink = nil }()
+	spans := make([][2]unsafe.Pointer, len(noscan))
+	for i := range noscan {
+		for j, p := range []unsafe.Pointer{scan[i].p, noscan[i]} {
+			base, s, _ := findObject(uintptr(p), 0, 0)
+			if base != uintptr(p) || s == nil {
+				return -1
+			}
+			spans[i][j] = s
+		}
+	}
+	for off := uintptr(0); off < maxSpanElemsizeOffset; off++ {
+		match := true
+		for _, s := range spans {
+			sc := *(*uint8)(unsafe.Pointer(uintptr(s[0]) + off))
+			nc := *(*uint8)(unsafe.Pointer(uintptr(s[1]) + off))
+			if sc == 0 || sc&1 != 0 || nc != sc|1 {
+				match = false
+				break
+			}
+		}
+		if match {
+			return int(off)
+		}
+	}
+	return -1
+}
+
+// findHeapBitsGap locates the pointer bitmap by checking the bitmaps of objects with
+// known pointer words.
+func findHeapBitsGap() int {
+	if spanClassOffset < 0 {
+		return -1
+	}
+	probes := heapBitsProbes()
+	defer func() { probeSink = nil }()
+	for gap := uintptr(0); gap <= maxHeapBitsGap; gap += ptrSize {
+		match := true
+		for _, pr := range probes {
+			var bits uintptr
+			ok := pointerWords(pr.p, gap, func(off uintptr) { bits |= 1 << (off / ptrSize) })
+			if !ok || bits != pr.bits {
+				match = false
+				break
+			}
+		}
+		if match {
+			return int(gap)
+		}
+	}
+	return -1
+}
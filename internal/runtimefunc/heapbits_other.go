@@ -0,0 +1,12 @@
+//go:build !go1.22 || purego || js || wasip1
+// +build !go1.22 purego js wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+var HavePointerWords = false
+
+func PointerWords(p unsafe.Pointer, fn func(off uintptr)) bool {
+	return false
+}
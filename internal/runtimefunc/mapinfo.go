@@ -0,0 +1,17 @@
+package runtimefunc
+
+// MapBucketSlots is the number of entries in a map bucket. Since the swiss table
+// implementation of Go 1.24, buckets are called groups.
+const MapBucketSlots = 8
+
+// MapIndirectSize is the size above which map keys and elements are stored
+// outside of the buckets, referenced by a pointer.
+const MapIndirectSize = 128
+
+// MapInfo describes the memory layout of a map.
+type MapInfo struct {
+	Buckets  uintptr // number of buckets, or groups of swiss tables
+	Overflow uintptr // number of overflow buckets, approximate for large maps
+	Tables   uintptr // number of swiss tables
+	Dir      uintptr // length of the swiss table directory
+}
@@ -0,0 +1,43 @@
+//go:build (!go1.24 || goexperiment.noswissmap) && !purego && !js && !wasip1
+// +build !go1.24 goexperiment.noswissmap
+// +build !purego
+// +build !js
+// +build !wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+// SwissMaps reports whether maps are implemented as swiss tables.
+const SwissMaps = false
+
+// hmap mirrors the beginning of runtime.hmap.
+type hmap struct {
+	count     int
+	flags     uint8
+	B         uint8
+	noverflow uint16
+	hash0     uint32
+	buckets   unsafe.Pointer
+}
+
+// HaveMapInfo reports whether the layout of maps matches the mirrored types.
+var HaveMapInfo = checkMapLayout()
+
+func checkMapLayout() bool {
+	m := make(map[uint64]uint64)
+	for i := uint64(0); i < 100; i++ {
+		m[i] = i
+	}
+	h := *(**hmap)(unsafe.Pointer(&m))
+	return h.count == 100 && h.B >= 3 && h.B <= 5 && h.buckets != nil
+}
+
+// ReadMapInfo returns the layout of map m.
+func ReadMapInfo(m unsafe.Pointer) MapInfo {
+	h := (*hmap)(m)
+	if h.buckets == nil {
+		return MapInfo{}
+	}
+	return MapInfo{Buckets: 1 << h.B, Overflow: uintptr(h.noverflow)}
+}
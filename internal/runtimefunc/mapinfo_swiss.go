@@ -0,0 +1,79 @@
+//go:build go1.24 && !goexperiment.noswissmap && !purego && !js && !wasip1
+// +build go1.24,!goexperiment.noswissmap,!purego,!js,!wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+// SwissMaps reports whether maps are implemented as swiss tables.
+const SwissMaps = true
+
+// swissMap mirrors the beginning of internal/runtime/maps.Map.
+type swissMap struct {
+	used   uint64
+	seed   uintptr
+	dirPtr unsafe.Pointer
+	dirLen int
+}
+
+// swissTable mirrors the beginning of internal/runtime/maps.table.
+type swissTable struct {
+	used       uint16
+	capacity   uint16
+	growthLeft uint16
+	localDepth uint8
+	index      int
+}
+
+// HaveMapInfo reports whether the layout of maps matches the mirrored types.
+var HaveMapInfo = checkMapLayout()
+
+func checkMapLayout() bool {
+	m := make(map[uint64]uint64)
+	for i := uint64(0); i < 100; i++ {
+		m[i] = i
+	}
+	p := *(*unsafe.Pointer)(unsafe.Pointer(&m))
+	sm := (*swissMap)(p)
+	if sm.used != 100 || sm.dirLen <= 0 || sm.dirLen&(sm.dirLen-1) != 0 {
+		return false
+	}
+	used := 0
+	last := unsafe.Pointer(nil)
+	for _, t := range unsafe.Slice((*unsafe.Pointer)(sm.dirPtr), sm.dirLen) {
+		if t == last {
+			continue
+		}
+		last = t
+		st := (*swissTable)(t)
+		if st.capacity < MapBucketSlots || st.capacity&(st.capacity-1) != 0 {
+			return false
+		}
+		used += int(st.used)
+	}
+	return used == 100
+}
+
+// ReadMapInfo returns the layout of map m.
+func ReadMapInfo(m unsafe.Pointer) MapInfo {
+	sm := (*swissMap)(m)
+	if sm.dirLen == 0 {
+		// Small map stored in a single group.
+		if sm.dirPtr == nil {
+			return MapInfo{}
+		}
+		return MapInfo{Buckets: 1}
+	}
+	info := MapInfo{Dir: uintptr(sm.dirLen)}
+	last := unsafe.Pointer(nil)
+	for _, t := range unsafe.Slice((*unsafe.Pointer)(sm.dirPtr), sm.dirLen) {
+		// Directory entries referring to the same table are adjacent.
+		if t == last {
+			continue
+		}
+		last = t
+		info.Tables++
+		info.Buckets += uintptr((*swissTable)(t).capacity) / MapBucketSlots
+	}
+	return info
+}
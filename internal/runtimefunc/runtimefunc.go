@@ -0,0 +1,33 @@
+// Package runtimefunc provides access to the Go runtime internals used by memsize.
+//
+// The layout of runtime internals changes between Go releases, so the functions of
+// this package are implemented in variants selected by build tags, one for each
+// range of Go releases with the same runtime API. A release without a matching
+// variant is unsupported: Supported is false and stopping the world is a no-op.
+// When a new Go release comes out, the variants must be checked against its runtime
+// sources and the build tags extended to cover it.
+//
+// Since Go 1.23, the linker rejects references to runtime.stopTheWorld unless the
+// program is built with -ldflags=-checklinkname=0. Stopping the world is therefore
+// opt-in on these releases: it requires the memsize_stw build tag, and without it
+// Supported is false. The other runtime functions can be linked by default.
+//
+// The portable variant (purego build tag, js and wasip1) doesn't access any runtime
+// internals.
+package runtimefunc
+
+import "unsafe"
+
+// These assertions ensure that all variants provide the same API.
+var (
+	_ func() WorldStop                          = StopTheWorld
+	_ func(WorldStop)                           = StartTheWorld
+	_ func(unsafe.Pointer, uint) unsafe.Pointer = Chanbuf
+	_ func(uintptr) uintptr                     = CheckptrBase
+	_ func(uintptr) uintptr                     = FindObjectBase
+	_ func(uintptr) uintptr                     = ObjectSize
+	_ func(unsafe.Pointer) MapInfo              = ReadMapInfo
+	_ func(unsafe.Pointer, func(uintptr)) bool  = PointerWords
+	_                                           = Supported && HaveChanbuf && HaveInternals && HaveAddrClass
+	_                                           = HaveMapInfo && SwissMaps && HaveObjectSize && HavePointerWords
+)
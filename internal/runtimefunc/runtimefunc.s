@@ -0,0 +1,4 @@
+//go:build !purego && !js && !wasip1
+// +build !purego,!js,!wasip1
+
+// This file is required to make stub function declarations work.
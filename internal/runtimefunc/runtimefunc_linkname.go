@@ -0,0 +1,20 @@
+//go:build !purego && !js && !wasip1
+// +build !purego,!js,!wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+var _ = unsafe.Pointer(nil)
+
+// HaveChanbuf reports whether channel buffers can be accessed.
+const HaveChanbuf = true
+
+// HaveInternals reports whether the memory layout of standard library internals
+// may be accessed for special handling of types like sync.Map.
+const HaveInternals = true
+
+// Chanbuf returns a pointer to the i'th slot of the buffer of channel ch.
+//
+//go:linkname Chanbuf runtime.chanbuf
+func Chanbuf(ch unsafe.Pointer, i uint) unsafe.Pointer
@@ -0,0 +1,36 @@
+//go:build purego || js || wasip1
+// +build purego js wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+// This file provides the portable variants of the runtime functions. They don't
+// access runtime internals and are used on platforms where go:linkname is unavailable.
+//
+// The world isn't stopped during scans in this mode, so objects must not be
+// modified concurrently. Channel buffer contents aren't scanned.
+
+const Supported = true
+
+const HaveChanbuf = false
+
+const HaveInternals = false
+
+const SwissMaps = false
+
+var HaveMapInfo = false
+
+type WorldStop struct{}
+
+func StopTheWorld() WorldStop { return WorldStop{} }
+
+func StartTheWorld(WorldStop) {}
+
+func Chanbuf(ch unsafe.Pointer, i uint) unsafe.Pointer {
+	panic("chanbuf not available in portable mode")
+}
+
+func ReadMapInfo(m unsafe.Pointer) MapInfo {
+	panic("map layout not available in portable mode")
+}
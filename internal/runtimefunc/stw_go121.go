@@ -0,0 +1,38 @@
+//go:build go1.21 && !go1.22 && !purego && !js && !wasip1
+// +build go1.21,!go1.22,!purego,!js,!wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+var _ = unsafe.Pointer(nil)
+
+// Supported reports whether the Go release is supported, see package
+// documentation.
+const Supported = true
+
+// stwReason mirrors runtime.stwReason.
+type stwReason uint8
+
+const stwReadMemStats stwReason = 7
+
+//go:linkname stopTheWorld runtime.stopTheWorld
+func stopTheWorld(reason stwReason)
+
+//go:linkname startTheWorld runtime.startTheWorld
+func startTheWorld()
+
+// WorldStop is returned by StopTheWorld and must be passed to StartTheWorld.
+type WorldStop struct{}
+
+// StopTheWorld stops all goroutines except the calling one. It must be followed by
+// a call to StartTheWorld.
+func StopTheWorld() WorldStop {
+	stopTheWorld(stwReadMemStats)
+	return WorldStop{}
+}
+
+// StartTheWorld resumes the goroutines stopped by StopTheWorld.
+func StartTheWorld(WorldStop) {
+	startTheWorld()
+}
@@ -0,0 +1,46 @@
+//go:build go1.22 && !go1.23 && !purego && !js && !wasip1
+// +build go1.22,!go1.23,!purego,!js,!wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+var _ = unsafe.Pointer(nil)
+
+// Supported reports whether the Go release is supported, see package
+// documentation.
+const Supported = true
+
+// stwReason mirrors runtime.stwReason.
+type stwReason uint8
+
+const stwReadMemStats stwReason = 7
+
+// worldStop mirrors runtime.worldStop. The value returned by stopTheWorld must be
+// passed to startTheWorld.
+type worldStop struct {
+	reason stwReason
+	start  int64
+}
+
+//go:linkname stopTheWorld runtime.stopTheWorld
+func stopTheWorld(reason stwReason) worldStop
+
+//go:linkname startTheWorld runtime.startTheWorld
+func startTheWorld(w worldStop)
+
+// WorldStop is returned by StopTheWorld and must be passed to StartTheWorld.
+type WorldStop struct {
+	w worldStop
+}
+
+// StopTheWorld stops all goroutines except the calling one. It must be followed by
+// a call to StartTheWorld.
+func StopTheWorld() WorldStop {
+	return WorldStop{stopTheWorld(stwReadMemStats)}
+}
+
+// StartTheWorld resumes the goroutines stopped by StopTheWorld.
+func StartTheWorld(w WorldStop) {
+	startTheWorld(w.w)
+}
@@ -0,0 +1,54 @@
+//go:build go1.23 && !go1.28 && memsize_stw && !purego && !js && !wasip1
+// +build go1.23,!go1.28,memsize_stw,!purego,!js,!wasip1
+
+package runtimefunc
+
+import "unsafe"
+
+var _ = unsafe.Pointer(nil)
+
+// Supported reports whether the Go release is supported, see package
+// documentation.
+//
+// Since Go 1.23, the linker rejects the references to runtime.stopTheWorld and
+// runtime.startTheWorld by default, so this variant is only used with the
+// memsize_stw build tag. Programs must also be built with -ldflags=-checklinkname=0.
+const Supported = true
+
+// stwReason mirrors runtime.stwReason.
+type stwReason uint8
+
+const stwReadMemStats stwReason = 7
+
+// worldStop mirrors runtime.worldStop. The value returned by stopTheWorld must be
+// passed to startTheWorld. The layout of worldStop and the value of stwReadMemStats
+// are the same in all releases covered by this file. They must be checked against
+// runtime/proc.go when the build tags are extended.
+type worldStop struct {
+	reason           stwReason
+	startedStopping  int64
+	finishedStopping int64
+	stoppingCPUTime  int64
+}
+
+//go:linkname stopTheWorld runtime.stopTheWorld
+func stopTheWorld(reason stwReason) worldStop
+
+//go:linkname startTheWorld runtime.startTheWorld
+func startTheWorld(w worldStop)
+
+// WorldStop is returned by StopTheWorld and must be passed to StartTheWorld.
+type WorldStop struct {
+	w worldStop
+}
+
+// StopTheWorld stops all goroutines except the calling one. It must be followed by
+// a call to StartTheWorld.
+func StopTheWorld() WorldStop {
+	return WorldStop{stopTheWorld(stwReadMemStats)}
+}
+
+// StartTheWorld resumes the goroutines stopped by StopTheWorld.
+func StartTheWorld(w WorldStop) {
+	startTheWorld(w.w)
+}
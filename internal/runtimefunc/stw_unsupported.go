@@ -0,0 +1,22 @@
+//go:build (go1.28 || (go1.23 && !memsize_stw)) && !purego && !js && !wasip1
+// +build go1.28 go1.23,!memsize_stw
+// +build !purego
+// +build !js
+// +build !wasip1
+
+package runtimefunc
+
+// Supported reports whether the Go release is supported, see package
+// documentation. Go 1.23 to 1.27 are only supported with the memsize_stw build tag.
+const Supported = false
+
+// WorldStop is returned by StopTheWorld.
+type WorldStop struct{}
+
+// StopTheWorld does nothing because the runtime API of the Go release is unknown or
+// can't be linked.
+func StopTheWorld() WorldStop { return WorldStop{} }
+
+// StartTheWorld does nothing because the runtime API of the Go release is unknown or
+// can't be linked.
+func StartTheWorld(WorldStop) {}
@@ -0,0 +1,72 @@
+package memsize
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// numKinds is the number of reflect.Kind values.
+const numKinds = reflect.UnsafePointer + 1
+
+// ByKind returns the total memory per kind of data. Counted values are accounted to
+// the kind of their type, while referenced data is accounted to the kind that holds
+// it: string data to reflect.String, slice backing arrays to reflect.Slice, channel
+// buffers to reflect.Chan, map storage to reflect.Map and the values stored in
+// interfaces to reflect.Interface. The internal storage of sync.Map and sync.Pool is
+// accounted to reflect.Struct.
+//
+// The totals of all kinds add up to Total.
+func (s Sizes) ByKind() map[reflect.Kind]uintptr {
+	m := make(map[reflect.Kind]uintptr)
+	for k, size := range s.kinds {
+		if size > 0 {
+			m[reflect.Kind(k)] = size
+		}
+	}
+	return m
+}
+
+// appendKindReport renders the kind table of AppendReport.
+func (s Sizes) appendKindReport(buf []byte) []byte {
+	var (
+		scratch [32]byte
+		maxlen  = len("ALL")
+		maxsz   = len(appendHumanSize(scratch[:0], s.Total))
+	)
+	for k, size := range s.kinds {
+		if size > 0 && len(reflect.Kind(k).String()) > maxlen {
+			maxlen = len(reflect.Kind(k).String())
+		}
+	}
+	line := func(buf []byte, name string, total uintptr) []byte {
+		buf = append(buf, name...)
+		buf = appendSpaces(buf, maxlen-len(name))
+		h := appendHumanSize(scratch[:0], total)
+		buf = appendSpaces(buf, 2+maxsz-len(h))
+		buf = append(buf, h...)
+		pct := uint64(100)
+		if s.Total > 0 {
+			pct = uint64(total) * 100 / uint64(s.Total)
+		}
+		p := strconv.AppendUint(scratch[:0], pct, 10)
+		buf = appendSpaces(buf, 5-len(p))
+		buf = append(buf, p...)
+		return append(buf, "%\n"...)
+	}
+	buf = line(buf, "ALL", s.Total)
+	// Kinds are ordered by decreasing size, then by kind.
+	var done [numKinds]bool
+	for {
+		best := -1
+		for k, size := range s.kinds {
+			if size > 0 && !done[k] && (best < 0 || size > s.kinds[best]) {
+				best = k
+			}
+		}
+		if best < 0 {
+			return buf
+		}
+		done[best] = true
+		buf = line(buf, reflect.Kind(best).String(), s.kinds[best])
+	}
+}
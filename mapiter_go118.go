@@ -0,0 +1,55 @@
+//go:build go1.18 && !purego && !js && !wasip1
+// +build go1.18,!purego,!js,!wasip1
+
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// mapIter mirrors the layout of reflect.MapIter. Since Go 1.18, the runtime map
+// iterator is embedded into MapIter. The iterator begins with pointers to the current
+// key and element slot. This is true for both the hmap iterator and the swiss
+// map iterator added in Go 1.24.
+type mapIter struct {
+	m    reflect.Value
+	key  unsafe.Pointer
+	elem unsafe.Pointer
+}
+
+// haveMapSlots reports whether the layout of reflect.MapIter matches mapIter.
+var haveMapSlots = checkMapIterLayout()
+
+func checkMapIterLayout() bool {
+	if unsafe.Sizeof(reflect.MapIter{}) < unsafe.Sizeof(mapIter{}) {
+		return false
+	}
+	m := map[uint64]uint64{0x1122334455667788: 0x8877665544332211}
+	it := reflect.ValueOf(m).MapRange()
+	if !it.Next() {
+		return false
+	}
+	mi := (*mapIter)(unsafe.Pointer(it))
+	return mi.key != nil && mi.elem != nil &&
+		*(*uint64)(mi.key) == 0x1122334455667788 && *(*uint64)(mi.elem) == 0x8877665544332211
+}
+
+// iterateMap calls fn for all entries of m. The key and value passed to fn refer
+// to the actual map slots, and their addresses are provided as well.
+func iterateMap(m reflect.Value, fn func(k, v reflect.Value, kaddr, vaddr address)) {
+	it := m.MapRange()
+	if !haveMapSlots {
+		for it.Next() {
+			fn(it.Key(), it.Value(), invalidAddr, invalidAddr)
+		}
+		return
+	}
+	ktyp, vtyp := m.Type().Key(), m.Type().Elem()
+	mi := (*mapIter)(unsafe.Pointer(it))
+	for it.Next() {
+		k := reflect.NewAt(ktyp, mi.key).Elem()
+		v := reflect.NewAt(vtyp, mi.elem).Elem()
+		fn(k, v, address(mi.key), address(mi.elem))
+	}
+}
@@ -1,12 +1,17 @@
+//go:build go1.12 && (!go1.18 || purego || js || wasip1)
 // +build go1.12
+// +build !go1.18 purego js wasip1
 
 package memsize
 
 import "reflect"
 
-func iterateMap(m reflect.Value, fn func(k, v reflect.Value)) {
+// haveMapSlots reports whether iterateMap provides slot addresses.
+const haveMapSlots = false
+
+func iterateMap(m reflect.Value, fn func(k, v reflect.Value, kaddr, vaddr address)) {
 	it := m.MapRange()
 	for it.Next() {
-		fn(it.Key(), it.Value())
+		fn(it.Key(), it.Value(), invalidAddr, invalidAddr)
 	}
 }
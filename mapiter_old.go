@@ -1,11 +1,15 @@
+//go:build !go1.12
 // +build !go1.12
 
 package memsize
 
 import "reflect"
 
-func iterateMap(m reflect.Value, fn func(k, v reflect.Value)) {
+// haveMapSlots reports whether iterateMap provides slot addresses.
+const haveMapSlots = false
+
+func iterateMap(m reflect.Value, fn func(k, v reflect.Value, kaddr, vaddr address)) {
 	for _, k := range m.MapKeys() {
-		fn(k, m.MapIndex(k))
+		fn(k, m.MapIndex(k), invalidAddr, invalidAddr)
 	}
 }
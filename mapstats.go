@@ -0,0 +1,119 @@
+package memsize
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/fjl/memsize/internal/runtimefunc"
+)
+
+// MapStats summarizes the hash table layout of all maps of one type.
+type MapStats struct {
+	Count   uintptr // number of non-nil maps
+	Entries uintptr // number of entries in all maps
+	Slots   uintptr // number of entry slots in all buckets, excluding overflow buckets
+	// Bytes is the estimated memory of the hash tables. OverflowBytes is the part of
+	// Bytes taken by overflow buckets. Maps implemented as swiss tables (Go 1.24 and
+	// later) don't have overflow buckets.
+	Bytes         uintptr
+	OverflowBytes uintptr
+}
+
+// LoadFactor returns the fraction of slots holding an entry.
+func (ms *MapStats) LoadFactor() float64 {
+	if ms.Slots == 0 {
+		return 0
+	}
+	return float64(ms.Entries) / float64(ms.Slots)
+}
+
+// mapBucketSize returns the size of a bucket of the given map type.
+func (c *scanState) mapBucketSize(typ reflect.Type) uintptr {
+	if size, ok := c.bucketSizes[typ]; ok {
+		return size
+	}
+	slotType := func(t reflect.Type) reflect.Type {
+		if t.Size() > runtimefunc.MapIndirectSize {
+			return reflect.TypeOf(unsafe.Pointer(nil))
+		}
+		return t
+	}
+	ktyp, etyp := slotType(typ.Key()), slotType(typ.Elem())
+	var bucket reflect.Type
+	if runtimefunc.SwissMaps {
+		slot := reflect.StructOf([]reflect.StructField{{Name: "K", Type: ktyp}, {Name: "E", Type: etyp}})
+		bucket = reflect.StructOf([]reflect.StructField{
+			{Name: "Ctrl", Type: reflect.TypeOf(uint64(0))},
+			{Name: "Slots", Type: reflect.ArrayOf(runtimefunc.MapBucketSlots, slot)},
+		})
+	} else {
+		bucket = reflect.StructOf([]reflect.StructField{
+			{Name: "Tophash", Type: reflect.TypeOf([runtimefunc.MapBucketSlots]uint8{})},
+			{Name: "Keys", Type: reflect.ArrayOf(runtimefunc.MapBucketSlots, ktyp)},
+			{Name: "Elems", Type: reflect.ArrayOf(runtimefunc.MapBucketSlots, etyp)},
+			{Name: "Overflow", Type: reflect.TypeOf(unsafe.Pointer(nil))},
+		})
+	}
+	c.bucketSizes[typ] = bucket.Size()
+	return bucket.Size()
+}
+
+func (c *scanState) addMapStats(v reflect.Value) {
+	if v.IsNil() {
+		return
+	}
+	info := runtimefunc.ReadMapInfo(unsafe.Pointer(v.Pointer()))
+	ms := c.s.Maps[v.Type()]
+	if ms == nil {
+		ms = new(MapStats)
+		c.s.Maps[v.Type()] = ms
+	}
+	bsize := c.mapBucketSize(v.Type())
+	ms.Count++
+	ms.Entries += uintptr(v.Len())
+	ms.Slots += info.Buckets * runtimefunc.MapBucketSlots
+	ms.Bytes += (info.Buckets+info.Overflow)*bsize + info.Dir*unsafe.Sizeof(uintptr(0))
+	ms.OverflowBytes += info.Overflow * bsize
+}
+
+// MapReport returns the statistics recorded by Options.MapStats, ordered by
+// decreasing hash table size. Map types with overflow buckets are marked with
+// "grown", since their maps were resized while entries were added and would
+// benefit from a size hint at construction. Map types with a load factor below
+// 50% are marked with "sparse": their maps hold unused capacity, e.g. because
+// entries were deleted.
+func (s Sizes) MapReport() string {
+	types := make([]reflect.Type, 0, len(s.Maps))
+	for typ := range s.Maps {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		mi, mj := s.Maps[types[i]], s.Maps[types[j]]
+		if mi.Bytes != mj.Bytes {
+			return mi.Bytes > mj.Bytes
+		}
+		return types[i].String() < types[j].String()
+	})
+	maxname := 0
+	for _, typ := range types {
+		if n := len(typ.String()); n > maxname {
+			maxname = n
+		}
+	}
+	var sb strings.Builder
+	for _, typ := range types {
+		ms := s.Maps[typ]
+		fmt.Fprintf(&sb, "%-*s  maps %d  entries %d  load %.1f%%  tables %s  overflow %s", maxname, typ, ms.Count, ms.Entries, ms.LoadFactor()*100, HumanSize(ms.Bytes), HumanSize(ms.OverflowBytes))
+		switch {
+		case ms.OverflowBytes > 0:
+			sb.WriteString("  grown")
+		case ms.Slots > ms.Count*runtimefunc.MapBucketSlots && ms.LoadFactor() < 0.5:
+			sb.WriteString("  sparse")
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
@@ -1,246 +1,968 @@
 package memsize
 
 import (
-	"bytes"
-	"fmt"
+	"context"
 	"reflect"
-	"sort"
-	"strings"
-	"text/tabwriter"
+	"time"
 	"unsafe"
+
+	"github.com/fjl/memsize/internal/runtimefunc"
 )
 
 // Scan traverses all objects reachable from v and counts how much memory
 // is used per type. The value must be a non-nil pointer to any value.
 func Scan(v interface{}) Sizes {
+	return ScanWithOptions(v, Options{})
+}
+
+// ScanWithOptions is like Scan, but allows configuring the scan.
+func ScanWithOptions(v interface{}, opts Options) Sizes {
+	return scanRoot(context.Background(), v, &opts)
+}
+
+// ScanContext is like Scan, but stops scanning when the context is canceled or its
+// deadline is exceeded. In that case, the world is restarted promptly and the
+// returned Sizes contain the values counted so far, with Partial set.
+//
+// Note that other goroutines don't run while the scan is in progress, so
+// cancellation is only effective through the deadline of ctx while scanning.
+func ScanContext(ctx context.Context, v interface{}) Sizes {
+	return scanRoot(ctx, v, &Options{})
+}
+
+// ScanValue is like Scan, but accepts any value. The value is copied into a new
+// variable, which is scanned. The result includes the memory of the copy (e.g. the
+// pointer word when v is a pointer) instead of the original location. The memory
+// referenced by v is shared between the original and the copy, so it is counted
+// normally. ScanValue returns an empty result when v is nil.
+func ScanValue(v interface{}) Sizes {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return *newSizes()
+	}
+	cpy := reflect.New(rv.Type())
+	cpy.Elem().Set(rv)
+	return Scan(cpy.Interface())
+}
+
+func scanRoot(ctx context.Context, v interface{}, opts *Options) Sizes {
+	return newScanState(opts).scanRoot(ctx, v)
+}
+
+func (c *scanState) scanRoot(ctx context.Context, v interface{}) Sizes {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		panic("value to scan must be non-nil pointer")
 	}
+	if ctx.Err() != nil {
+		c.s.Partial = true
+		return *c.s
+	}
+	c.setContext(ctx)
+	c.goroutines = captureGoroutines(c.goroutineStacks)
+	if c.metadata {
+		c.meta = captureMetadata(time.Now())
+	}
 
-	stopTheWorld(stwReadMemStats)
-	defer startTheWorld()
+	var (
+		res  []Sizes
+		weak []weakScan
+	)
+	c.withWorldStopped(func() {
+		if c.addrClasses {
+			c.s.RootClass = classifyAddress(address(rv.Pointer()))
+		}
+		c.scanContent(invalidAddr, rv)
+		weak = append(weak, c.takeWeak())
+		res = append(res, c.finish())
+	})
+	c.scanWeak(weak, res)
+	return res[0]
+}
 
-	ctx := newContext()
-	ctx.scan(invalidAddr, rv, false)
-	ctx.s.BitmapSize = ctx.seen.size()
-	ctx.s.BitmapUtilization = ctx.seen.utilization()
-	return *ctx.s
+// finish completes the current result.
+func (c *scanState) finish() Sizes {
+	c.s.Partial = c.stopped
+	c.s.Goroutines = c.goroutines
+	c.s.Metadata = c.meta
+	if c.budget != nil {
+		c.budget.apply(c.s)
+	}
+	if c.pointers != nil {
+		c.s.Pointers = c.pointers.result()
+	}
+	if c.slow != nil && c.slow.captured != nil {
+		c.s.SlowScanPath, c.slow.captured = c.slow.captured, nil
+	}
+	if c.slicer != nil {
+		c.slicer.end(time.Now())
+		c.s.Pauses, c.s.MaxPause = c.slicer.pauses, c.slicer.maxPause
+	}
+	if c.stringPins != nil {
+		c.s.StringPins = c.stringPins.pins()
+		c.stringPins.ranges = nil
+	}
+	if c.addrs != nil {
+		c.addrs.finish()
+		c.s.addrs, c.addrs = c.addrs, new(addrIndex) // for the next root
+	}
+	c.s.BitmapSize = c.seen.size()
+	c.s.BitmapUtilization = c.seen.utilization()
+	return *c.s
 }
 
 // Sizes is the result of a scan.
 type Sizes struct {
 	Total  uintptr
 	ByType map[reflect.Type]*TypeSize
-	// Internal stats (for debugging)
+	// Ownership is set when Options.Ownership is enabled. For each parent type, it
+	// holds the number of bytes of each child type referenced by values of the
+	// parent type. Only direct references are recorded, i.e. for a chain A -> B -> C,
+	// the bytes of C are attributed to B but not to A.
+	Ownership map[reflect.Type]map[reflect.Type]uintptr
+	// External holds the totals of external resources reported by
+	// Options.Accountants, keyed by resource name.
+	External map[string]uint64
+	// StringPins is set when Options.StringPins is enabled.
+	// It lists strings which share their data with much larger strings.
+	StringPins []StringPin
+	// Cycles is set when Options.Cycles is enabled. It holds the number of
+	// reference cycles found per type, see CyclePath.
+	Cycles map[reflect.Type]uintptr
+	// CyclePaths holds example cycles, up to Options.MaxCyclePaths.
+	CyclePaths []CyclePath
+	// Partial is set when the scan was interrupted by ScanContext.
+	Partial bool
+	// Goroutines holds the goroutine statistics captured before the scan.
+	Goroutines GoroutineStats
+	// Contexts summarizes the context.Context values found by the scan. MaxDepth is
+	// the length of the longest chain of nested contexts.
+	Contexts RetainStats
+	// Timers summarizes the time.Timer and time.Ticker values found by the scan.
+	// The functions of timers created by time.AfterFunc are closures, which are
+	// recorded in Unscannable under time.Timer and not included in Retained.
+	Timers RetainStats
+	// Pointers is set when Options.NilPointers is enabled. It holds the nil
+	// statistics of pointer-like fields, keyed by struct type and field name. The
+	// elements of arrays and slices are keyed by the array or slice type and "[]".
+	Pointers map[reflect.Type]map[string]PointerStats
+	// Slices is set when Options.SliceHistograms is enabled. It holds the length
+	// and capacity statistics of slices, keyed by element type.
+	Slices map[reflect.Type]*SliceStats
+	// BackingArrays is set when Options.BackingArrays is enabled. It holds the
+	// number of distinct slice backing arrays per element type. StringBlocks is
+	// the number of distinct string data blocks. Slices and strings starting
+	// inside memory reached before don't count as distinct. Objects referenced by
+	// pointers are counted in TypeSize.Count.
+	BackingArrays map[reflect.Type]uintptr
+	StringBlocks  uintptr
+	// Maps is set when Options.MapStats is enabled and the map layout of the Go
+	// release is known. It holds the hash table statistics of maps, keyed by map
+	// type.
+	Maps map[reflect.Type]*MapStats
+	// Chans is set when Options.ChanStats is enabled. It holds the buffer
+	// statistics of channels, keyed by channel type.
+	Chans map[reflect.Type]*ChanStats
+	// Pauses is the number of times the world was stopped during the scan and
+	// MaxPause is the longest of these pauses. They are set when
+	// Options.MaxPausePerSlice is enabled.
+	Pauses   int
+	MaxPause time.Duration
+	// Unscannable holds the values whose referenced memory can't be traversed by
+	// reflection, keyed by the type of the object containing them. These are
+	// function values referring to closures or method values, whose captured
+	// variables are hidden from reflection. The memory of the closures is not
+	// included in Total.
+	Unscannable map[reflect.Type]*BlockedStats
+	// SlowScanPath is set when the scan took longer than Options.SlowScan. It
+	// holds the types of the objects being scanned at that time, outermost first.
+	SlowScanPath []reflect.Type
+	// UnusedChanCapacity is the memory of empty channel buffer slots when
+	// Options.ChanOccupiedOnly is enabled. It is not included in Total.
+	UnusedChanCapacity uintptr
+	// RootClass is the address class of the scanned value when
+	// Options.AddressClasses is enabled.
+	RootClass AddressClass
+	// Metadata is set when Options.Metadata is enabled. It describes the program
+	// which produced the result.
+	Metadata *Metadata
+	// WeakReachable is set when Options.WeakReachable is enabled. It holds the
+	// memory which is only reachable through weak pointers.
+	WeakReachable *Sizes
+	// Internal stats (for debugging). When Options.ApproxDedup is set,
+	// these refer to the Bloom filter.
 	BitmapSize        uintptr
 	BitmapUtilization float32
+
+	kinds   [numKinds]uintptr          // see ByKind
+	classes [numAddressClasses]uintptr // see ByAddressClass
+	addrs   *addrIndex                 // see TypeAt
 }
 
+// TypeSize is the memory usage of a single type.
 type TypeSize struct {
 	Total uintptr
 	Count uintptr
+	// Shallow is the memory occupied by the values themselves.
+	// Referenced is the memory of data referenced by the values that isn't
+	// accounted to any other type, e.g. slice backing arrays or string data.
+	// The sum of Shallow and Referenced is Total.
+	Shallow    uintptr
+	Referenced uintptr
+	// Shared is the number of bytes of referenced data, i.e. slice backing arrays
+	// and string data, which were shared with previously scanned values. This memory
+	// is not included in Referenced.
+	Shared uintptr
+	// PointerWords is the number of pointer-sized words holding pointers in the
+	// memory of the type. The remaining memory holds scalar data.
+	PointerWords uintptr
+	// Headers is the memory of string, slice, map and channel headers in Total
+	// when Options.Headers is enabled. The remaining memory is payload data.
+	Headers uintptr `json:",omitempty"`
+	// Edges is the number of references followed from the values: non-nil
+	// pointers, maps and channels, and the data of slices and strings. See
+	// FanOut.
+	Edges uintptr `json:",omitempty"`
+	// Estimated is the number of values which were not traversed because of
+	// Options.MaxPerType. Their referenced memory is extrapolated.
+	Estimated uintptr `json:",omitempty"`
+	// External holds the amounts of external resources attributed to the type
+	// by Options.Accountants.
+	External map[string]uint64 `json:",omitempty"`
 }
 
-func newSizes() *Sizes {
-	return &Sizes{ByType: make(map[reflect.Type]*TypeSize)}
+// add accumulates the counters of other into ts.
+func (ts *TypeSize) add(other TypeSize) {
+	ts.Total += other.Total
+	ts.Count += other.Count
+	ts.Shallow += other.Shallow
+	ts.Referenced += other.Referenced
+	ts.PointerWords += other.PointerWords
+	ts.Headers += other.Headers
+	ts.Edges += other.Edges
+	ts.Shared += other.Shared
+	ts.Estimated += other.Estimated
+	for resource, amount := range other.External {
+		if ts.External == nil {
+			ts.External = make(map[string]uint64, len(other.External))
+		}
+		ts.External[resource] += amount
+	}
 }
 
-// Report returns a human-readable report.
-func (s Sizes) Report() string {
-	type typLine struct {
-		name  string
-		count uintptr
-		total uintptr
+// PointerDensity returns the fraction of the type's memory which holds pointers.
+// Types with high pointer density are expensive for the garbage collector to scan.
+func (ts *TypeSize) PointerDensity() float64 {
+	if ts.Total == 0 {
+		return 0
 	}
-	tab := []typLine{{"ALL", 0, s.Total}}
-	for _, typ := range s.ByType {
-		tab[0].count += typ.Count
+	return float64(ts.PointerWords*uintptrBytes) / float64(ts.Total)
+}
+
+// Payload returns the memory of the type which isn't taken by headers, see
+// Options.Headers.
+func (ts *TypeSize) Payload() uintptr {
+	return ts.Total - ts.Headers
+}
+
+// FanOut returns the average number of references followed per value (see Edges).
+// Values which were not traversed because of Options.MaxPerType are not included.
+// Types with high fan-out may benefit from being flattened, e.g. by storing
+// structs in arrays instead of referencing them through pointers.
+func (ts *TypeSize) FanOut() float64 {
+	n := ts.Count - ts.Estimated
+	if n == 0 {
+		return 0
 	}
-	maxname := 0
-	for typ, s := range s.ByType {
-		line := typLine{typ.String(), s.Count, s.Total}
-		tab = append(tab, line)
-		if len(line.name) > maxname {
-			maxname = len(line.name)
-		}
+	return float64(ts.Edges) / float64(n)
+}
+
+// Exact reports whether the memory of the type was measured for all values, i.e.
+// no values were extrapolated because of Options.MaxPerType.
+func (ts *TypeSize) Exact() bool {
+	return ts.Estimated == 0
+}
+
+// SampledFraction returns the fraction of values of the type which were traversed.
+// It is 1 for exact results.
+func (ts *TypeSize) SampledFraction() float64 {
+	if ts.Count == 0 {
+		return 1
 	}
-	sort.Slice(tab, func(i, j int) bool { return tab[i].total > tab[j].total })
+	return float64(ts.Count-ts.Estimated) / float64(ts.Count)
+}
+
+// estimatedMark is shown in reports in front of sizes which are extrapolated.
+const estimatedMark = "~"
+
+func newSizes() *Sizes {
+	return &Sizes{ByType: make(map[reflect.Type]*TypeSize)}
+}
 
-	buf := new(bytes.Buffer)
-	w := tabwriter.NewWriter(buf, 0, 0, 0, ' ', tabwriter.AlignRight)
-	for _, line := range tab {
-		namespace := strings.Repeat(" ", maxname-len(line.name))
-		fmt.Fprintf(w, "%s%s\t  %v\t  %s\t\n", line.name, namespace, line.count, HumanSize(line.total))
+// addOwnership records that parent references size bytes of child.
+func (s *Sizes) addOwnership(parent, child reflect.Type, size uintptr) {
+	children := s.Ownership[parent]
+	if children == nil {
+		children = make(map[reflect.Type]uintptr)
+		s.Ownership[parent] = children
 	}
-	w.Flush()
-	return buf.String()
+	children[child] += size
+}
+
+// Report returns a human-readable report.
+func (s Sizes) Report() string {
+	return s.Snapshot().Report()
+}
+
+// String returns a one-line summary, e.g. "total=1.400MB types=312 top=*trie.Node(800.000KB)".
+// Use Report for the full table.
+func (s Sizes) String() string {
+	return s.Snapshot().String()
 }
 
 // addValue is called during scan and adds the memory of given object.
-func (s *Sizes) addValue(v reflect.Value, size uintptr) {
-	s.Total += size
+func (s *Sizes) addValue(v reflect.Value, shallow, referenced uintptr, obj objStats) {
+	s.Total += shallow + referenced
 	rs := s.ByType[v.Type()]
 	if rs == nil {
 		rs = new(TypeSize)
 		s.ByType[v.Type()] = rs
 	}
-	rs.Total += size
-	rs.Count++
+	rs.add(TypeSize{
+		Total:        shallow + referenced,
+		Count:        1,
+		Shallow:      shallow,
+		Referenced:   referenced,
+		PointerWords: obj.ptrWords,
+		Headers:      obj.hdrWords * uintptrBytes,
+		Edges:        obj.edges,
+		Shared:       obj.shared,
+	})
+	if obj.estimated {
+		rs.Estimated++
+	}
 }
 
-type context struct {
+type scanState struct {
+	opts Options // options of the scan, see reset
 	// We track previously scanned objects to prevent infinite loops
 	// when scanning cycles and to prevent counting objects more than once.
-	seen *bitmap
-	tc   typCache
-	s    *Sizes
+	seen     seenSet
+	seenOpts Options // dedup options used to create seen
+	tc       typCache
+	s        *Sizes
+	// owner is the type of the object currently being scanned.
+	owner        reflect.Type
+	ownership    bool
+	accountants  []Accountant
+	poolContents bool
+	stringPins   *stringPinTracker
+	cycles       *cycleTracker
+	pointers     pointerTracker
+	chanOccupied bool
+	exclude      []AddressRange
+	focus        typeSet
+	addrs        *addrIndex
+	sliceStats   bool
+	buffers      bool
+	chanStats    bool
+	onValue      func(path string, v reflect.Value) Action
+	slow         *slowScan
+	slicer       *pauseSlicer
+	headers      bool
+	countArrays  bool
+	bucketSizes  map[reflect.Type]uintptr // for map statistics
+	blocked      map[uintptr]struct{}     // objects recorded in Sizes.Unscannable
+	path         []byte                   // path of the current value, tracked for onValue
+	budget       *scanBudget
+	// Goroutine statistics.
+	goroutineStacks bool
+	goroutines      GoroutineStats
+	// Program metadata, see Options.Metadata.
+	metadata bool
+	meta     *Metadata
+	// groupDepth is the number of values of each retain group being scanned.
+	groupDepth  [numRetainGroups]int
+	addrClasses bool
+	// Weak pointer targets, see scanWeak.
+	weakReachable bool
+	weak          []weakRef
+	// Interruption by context. The context itself must not be accessed while the
+	// world is stopped because its methods may wait for stopped goroutines.
+	done        <-chan struct{}
+	deadline    time.Time
+	hasDeadline bool
+	steps       uint
+	stopped     bool
+	// Multi-root attribution, see ScanRoots.
+	policy AttributionPolicy
+	reach  []seenSet // memory reached by each root
+	root   int       // index of the root being scanned
+	shared *Sizes    // result for AttributeShared
+	// obj accumulates statistics of the object being scanned.
+	obj objStats
+	// boxKind is the kind to which the memory of values scanned by scanBoxed is
+	// accounted. split is the attribution divisor of the object being scanned.
+	// skip is set while the memory of the object being scanned is left out of the
+	// result, see Options.FocusTypes.
+	boxKind reflect.Kind
+	split   uintptr
+	skip    bool
+	// budgetKinds accumulates the referenced memory of the value of a type limited
+	// by Options.MaxPerType that is being traversed, see scanBudget.
+	budgetKinds *[numKinds]uintptr
+}
+
+// objStats are per-object statistics collected during scan.
+type objStats struct {
+	ptrWords  uintptr // pointer words
+	hdrWords  uintptr // header words, see Options.Headers
+	edges     uintptr // references followed
+	shared    uintptr // bytes of referenced data shared with other objects
+	estimated bool    // referenced memory is extrapolated, see Options.MaxPerType
+}
+
+func (o *objStats) add(other objStats) {
+	o.ptrWords += other.ptrWords
+	o.hdrWords += other.hdrWords
+	o.edges += other.edges
+	o.shared += other.shared
+}
+
+func newScanState(opts *Options) *scanState {
+	c := &scanState{tc: make(typCache)}
+	c.reset(opts)
+	return c
+}
+
+// reset prepares c for a new scan. The seen set and type cache are
+// retained to avoid allocating them again.
+func (c *scanState) reset(opts *Options) {
+	seen, seenOpts := c.seen, c.seenOpts
+	if seen != nil && seenOpts.ApproxDedup == opts.ApproxDedup &&
+		(!opts.ApproxDedup || seenOpts.approxDedupBytes() == opts.approxDedupBytes()) {
+		seen.reset()
+	} else {
+		seen = newSeenSet(opts)
+	}
+	seenOpts = Options{ApproxDedup: opts.ApproxDedup, ApproxDedupBytes: opts.approxDedupBytes()}
+
+	*c = scanState{
+		opts:         *opts,
+		seen:         seen,
+		seenOpts:     seenOpts,
+		tc:           c.tc,
+		ownership:    opts.Ownership,
+		accountants:  opts.Accountants,
+		poolContents: opts.PoolContents,
+		chanOccupied: opts.ChanOccupiedOnly,
+		exclude:      opts.ExcludeRanges,
+		focus:        newTypeSet(opts.FocusTypes),
+		sliceStats:   opts.SliceHistograms,
+		chanStats:    opts.ChanStats,
+		onValue:      opts.OnValue,
+		buffers:      opts.AttributeBuffers,
+		headers:      opts.Headers,
+		countArrays:  opts.BackingArrays,
+		policy:       opts.Attribution,
+
+		goroutineStacks: opts.GoroutineStacks,
+		metadata:        opts.Metadata,
+		weakReachable:   opts.WeakReachable,
+		addrClasses:     opts.AddressClasses && haveAddrClass,
+	}
+	c.s = c.newSizes()
+	if opts.StringPins {
+		c.stringPins = &stringPinTracker{factor: opts.stringPinFactor()}
+	}
+	if opts.NilPointers {
+		c.pointers = make(pointerTracker)
+	}
+	if opts.MapStats && runtimefunc.HaveMapInfo {
+		c.bucketSizes = make(map[reflect.Type]uintptr)
+		c.s.Maps = make(map[reflect.Type]*MapStats)
+	}
+	if opts.AddressIndex {
+		c.addrs = new(addrIndex)
+	}
+	if opts.MaxPausePerSlice > 0 {
+		c.slicer = &pauseSlicer{max: opts.MaxPausePerSlice}
+	}
+	if opts.SlowScan > 0 {
+		c.slow = &slowScan{threshold: opts.SlowScan, start: time.Now()}
+	}
+	if opts.MaxPerType != nil {
+		c.budget = newScanBudget(opts.MaxPerType)
+	}
+	if opts.Cycles {
+		c.cycles = &cycleTracker{active: make(map[address]int), maxPaths: opts.MaxCyclePaths}
+		c.s.Cycles = make(map[reflect.Type]uintptr)
+	}
+}
+
+func newSeenSet(opts *Options) seenSet {
+	if opts.ApproxDedup {
+		return newBloomFilter(opts.approxDedupBytes())
+	}
+	return newBitmap()
 }
 
-func newContext() *context {
-	return &context{seen: newBitmap(), tc: make(typCache), s: newSizes()}
+// newSizes creates an empty result.
+func (c *scanState) newSizes() *Sizes {
+	s := newSizes()
+	if c.ownership {
+		s.Ownership = make(map[reflect.Type]map[reflect.Type]uintptr)
+	}
+	if c.cycles != nil {
+		s.Cycles = make(map[reflect.Type]uintptr)
+	}
+	if c.sliceStats {
+		s.Slices = make(map[reflect.Type]*SliceStats)
+	}
+	if c.chanStats {
+		s.Chans = make(map[reflect.Type]*ChanStats)
+	}
+	if c.countArrays {
+		s.BackingArrays = make(map[reflect.Type]uintptr)
+	}
+	if c.bucketSizes != nil {
+		s.Maps = make(map[reflect.Type]*MapStats)
+	}
+	return s
 }
 
 // scan walks all objects below v, determining their size. It returns the size of the
 // previously unscanned parts of the object.
-func (c *context) scan(addr address, v reflect.Value, add bool) (extraSize uintptr) {
+func (c *scanState) scan(addr address, v reflect.Value, add bool) (extraSize uintptr) {
 	size := v.Type().Size()
 	var marked uintptr
 	if addr.valid() {
+		if c.excluded(uintptr(addr)) {
+			return 0
+		}
 		marked = c.seen.countRange(uintptr(addr), size)
 		if marked == size {
+			if add && c.cycles != nil {
+				c.checkCycle(addr, v.Type())
+			}
 			return 0 // Skip if we have already seen the whole object.
 		}
 		c.seen.markRange(uintptr(addr), size)
 	}
+	split := uintptr(1)
+	if add && addr.valid() && c.reach != nil {
+		first, n := c.reachedBy(addr, size)
+		if n > 1 {
+			switch c.policy {
+			case AttributeSplit:
+				split = n
+			case AttributeShared:
+				if first < c.root {
+					return 0 // already counted in the shared result
+				}
+				if c.s != c.shared {
+					defer func(s *Sizes) { c.s = s }(c.s)
+					c.s = c.shared
+				}
+			}
+		}
+	}
 	// fmt.Printf("%v: %v ⮑ (marked %d)\n", addr, v.Type(), marked)
-	if c.tc.needScan(v.Type()) {
-		extraSize = c.scanContent(addr, v)
+	parent, outerObj, outerSplit, outerSkip := c.owner, c.obj, c.split, c.skip
+	if add {
+		c.split = split
+		c.skip = c.unfocused(v.Type())
+	}
+	c.obj = objStats{ptrWords: c.tc.pointerWords(v.Type())}
+	if c.headers {
+		c.obj.hdrWords = c.tc.headerWords(v.Type())
+	}
+	if marked > 0 {
+		c.obj.ptrWords = c.obj.ptrWords * (size - marked) / size
+		c.obj.hdrWords = c.obj.hdrWords * (size - marked) / size
+	}
+	group := groupNone
+	if add {
+		group = c.tc.info(v.Type()).group
+	}
+	var groupStart uintptr
+	if group != groupNone {
+		groupStart = c.enterGroup(group)
+	}
+	estimate := add && c.budget != nil && c.tc.needScan(v.Type()) && !c.budget.take(v.Type())
+	outerKinds := c.budgetKinds
+	if c.tc.needScan(v.Type()) && !estimate {
+		if add {
+			c.owner = v.Type()
+			if c.budget != nil {
+				c.budgetKinds = c.budget.kinds(v.Type())
+			}
+		}
+		if c.slow != nil {
+			c.slow.push(v.Type())
+		}
+		if add && addr.valid() && c.cycles != nil {
+			c.cycles.push(addr, v.Type())
+			extraSize = c.scanContent(addr, v)
+			c.cycles.pop(addr)
+		} else {
+			extraSize = c.scanContent(addr, v)
+		}
+		if c.slow != nil {
+			c.slow.pop()
+		}
+		c.owner = parent
+	}
+	if estimate {
+		c.obj.estimated = true
+		c.budgetKinds = nil
+		c.scanBudgetEdges(v)
+	} else if add && c.budget != nil {
+		c.budget.record(v.Type())
+	}
+	obj := c.obj
+	c.obj = outerObj
+	if !add {
+		c.obj.add(obj) // memory belongs to the enclosing value
 	}
 	size -= marked
-	size += extraSize
-	// fmt.Printf("%v: %v %d (add %v, size %d, marked %d, extra %d)\n", addr, v.Type(), size+extraSize, add, v.Type().Size(), marked, extraSize)
 	if add {
-		c.s.addValue(v, size)
+		// The value itself isn't referenced memory of the enclosing value.
+		c.budgetKinds = nil
+		c.addMemory(v.Kind(), addr, size)
+		c.budgetKinds = outerKinds
+	} else {
+		c.addMemory(c.boxKind, addr, size)
 	}
-	return size
+	skip := c.skip
+	c.split, c.skip = outerSplit, outerSkip
+	// fmt.Printf("%v: %v %d (add %v, size %d, marked %d, extra %d)\n", addr, v.Type(), size+extraSize, add, v.Type().Size(), marked, extraSize)
+	if add && !skip {
+		if addr.valid() {
+			c.recordAddr(uintptr(addr), v.Type().Size(), v.Type())
+		}
+		c.s.addValue(v, size/split, extraSize/split, obj)
+		if parent != nil && c.ownership {
+			c.s.addOwnership(parent, v.Type(), (size+extraSize)/split)
+		}
+		c.account(v, split)
+	}
+	if group != groupNone {
+		c.leaveGroup(group, groupStart)
+	}
+	return size + extraSize
+}
+
+// interruptCheckInterval is the number of values scanned between context checks.
+const interruptCheckInterval = 1024
+
+// setContext enables interruption of the scan by ctx. It must be called before
+// the world is stopped.
+func (c *scanState) setContext(ctx context.Context) {
+	if c.done = ctx.Done(); c.done == nil {
+		return // can't be interrupted
+	}
+	c.deadline, c.hasDeadline = ctx.Deadline()
+}
+
+// interrupted reports whether the scan should stop. Once it has returned true,
+// all scan functions return immediately.
+func (c *scanState) interrupted() bool {
+	if c.stopped || (c.done == nil && c.slow == nil && c.slicer == nil) {
+		return c.stopped
+	}
+	c.steps++
+	if c.steps%interruptCheckInterval != 0 {
+		return false
+	}
+	now := time.Now()
+	if c.slow != nil {
+		c.slow.check(now)
+	}
+	if c.slicer != nil {
+		c.slicer.check(now)
+	}
+	if c.hasDeadline && !now.Before(c.deadline) {
+		c.stopped = true
+	}
+	if c.done != nil {
+		select {
+		case <-c.done:
+			c.stopped = true
+		default:
+		}
+	}
+	return c.stopped
 }
 
 // scanContent and all other scan* functions below return the amount of 'extra' memory
 // (e.g. slice data) that is referenced by the object.
-func (c *context) scanContent(addr address, v reflect.Value) uintptr {
+func (c *scanState) scanContent(addr address, v reflect.Value) uintptr {
+	if c.interrupted() || !c.visit(v) {
+		return 0
+	}
 	switch v.Kind() {
 	case reflect.Array:
 		return c.scanArray(addr, v)
 	case reflect.Chan:
+		if c.excluded(v.Pointer()) {
+			return 0
+		}
+		if !v.IsNil() {
+			c.obj.edges++
+		}
 		return c.scanChan(v)
 	case reflect.Func:
-		// can't do anything here
-		return 0
+		return c.scanFunc(v)
 	case reflect.Interface:
 		return c.scanInterface(v)
 	case reflect.Map:
+		if !v.IsNil() {
+			c.obj.edges++
+		}
 		return c.scanMap(v)
 	case reflect.Ptr:
-		if !v.IsNil() {
+		if !v.IsNil() && !c.excluded(v.Pointer()) {
+			c.obj.edges++
 			c.scan(address(v.Pointer()), v.Elem(), true)
 		}
 		return 0
 	case reflect.Slice:
 		return c.scanSlice(v)
 	case reflect.String:
-		return uintptr(v.Len())
+		return c.scanString(v)
 	case reflect.Struct:
-		return c.scanStruct(addr, v)
+		switch sk := c.tc.info(v.Type()).special; sk {
+		case specialNone:
+			return c.scanStruct(addr, v)
+		case specialBuffer:
+			return c.scanBuffer(addr, v) // doesn't access runtime internals
+		default:
+			c.enterNoYield()
+			defer c.leaveNoYield()
+			return c.scanSpecial(addr, v, sk)
+		}
 	default:
 		unhandledKind(v.Kind())
 		return 0
 	}
 }
 
-func (c *context) scanChan(v reflect.Value) uintptr {
+func (c *scanState) scanChan(v reflect.Value) uintptr {
+	// Like maps, channels are marked as seen at the address of their header to
+	// count them only once.
+	p := v.Pointer()
+	if p == 0 || c.seen.countRange(p, 1) > 0 {
+		return 0
+	}
+	c.seen.markRange(p, 1)
 	etyp := v.Type().Elem()
-	extra := uintptr(0)
-	if c.tc.needScan(etyp) {
+	slots := uintptr(v.Cap())
+	if c.chanOccupied {
+		slots = uintptr(v.Len())
+		c.s.UnusedChanCapacity += (uintptr(v.Cap()) - slots) * etyp.Size()
+	}
+	extra, total := uintptr(0), c.s.Total
+	if haveChanbuf && c.tc.needScan(etyp) {
 		// Scan the channel buffer. This is unsafe but doesn't race because
 		// the world is stopped during scan.
 		hchan := unsafe.Pointer(v.Pointer())
-		for i := uint(0); i < uint(v.Cap()); i++ {
-			addr := chanbuf(hchan, i)
+		c.enterNoYield()
+		defer c.leaveNoYield()
+		first, n := uint(0), uint(v.Cap())
+		if c.chanOccupied && chanRecvxOffset != 0 {
+			first, n = chanRecvx(hchan), uint(v.Len())
+		}
+		for i := uint(0); i < n && !c.stopped; i++ {
+			addr := runtimefunc.Chanbuf(hchan, (first+i)%uint(v.Cap()))
 			elem := reflect.NewAt(etyp, addr).Elem()
+			p := c.enterIndex(int(i))
 			extra += c.scanContent(address(addr), elem)
+			c.leavePath(p)
 		}
 	}
-	return uintptr(v.Cap())*etyp.Size() + extra
+	c.obj.ptrWords += slots * c.tc.pointerWords(etyp)
+	if c.headers {
+		c.obj.hdrWords += slots * c.tc.headerWords(etyp)
+	}
+	if c.s.Chans != nil {
+		// Objects reached through the elements are already in Total.
+		c.addChanStats(v, c.s.Total-total+extra)
+	}
+	c.addMemory(reflect.Chan, address(v.Pointer()), slots*etyp.Size())
+	return slots*etyp.Size() + extra
 }
 
-func (c *context) scanStruct(base address, v reflect.Value) uintptr {
+func (c *scanState) scanStruct(base address, v reflect.Value) uintptr {
 	extra := uintptr(0)
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Type().Field(i)
+		if c.pointers != nil && isPointerLike(f.Type.Kind()) {
+			c.pointers.add(v.Type(), i, v.NumField(), v.Field(i))
+		}
 		if c.tc.needScan(f.Type) {
 			addr := base.addOffset(f.Offset)
+			p := c.enterField(f.Name)
 			extra += c.scanContent(addr, v.Field(i))
+			c.leavePath(p)
 		}
 	}
 	return extra
 }
 
-func (c *context) scanArray(addr address, v reflect.Value) uintptr {
+func (c *scanState) scanArray(addr address, v reflect.Value) uintptr {
 	esize := v.Type().Elem().Size()
 	extra := uintptr(0)
-	for i := 0; i < v.Len(); i++ {
+	track := c.pointers != nil && isPointerLike(v.Type().Elem().Kind())
+	for i := 0; i < v.Len() && !c.stopped; i++ {
+		if track {
+			c.pointers.add(v.Type(), 0, 1, v.Index(i))
+		}
+		p := c.enterIndex(i)
 		extra += c.scanContent(addr, v.Index(i))
+		c.leavePath(p)
 		addr = addr.addOffset(esize)
 	}
 	return extra
 }
 
-func (c *context) scanSlice(v reflect.Value) uintptr {
-	slice := v.Slice(0, v.Cap())
-	esize := slice.Type().Elem().Size()
-	base := slice.Pointer()
+func (c *scanState) scanSlice(v reflect.Value) uintptr {
+	etyp := v.Type().Elem()
+	esize := etyp.Size()
+	base := v.Pointer()
+	if c.excluded(base) {
+		return 0
+	}
+	if base != 0 {
+		c.obj.edges++
+	}
+	if c.sliceStats {
+		c.addSliceStats(v)
+	}
+	blen := uintptr(v.Cap()) * esize
+	if c.s.BackingArrays != nil && blen > 0 && c.seen.countRange(base, 1) == 0 {
+		c.s.BackingArrays[etyp]++
+	}
+	if blen > 0 && c.attributeData() {
+		return c.scanData(base, func() uintptr { return c.scanSliceData(v, base, blen) })
+	}
+	return c.scanSliceData(v, base, blen)
+}
+
+// scanSliceData accounts the backing array of slice v and scans its elements.
+func (c *scanState) scanSliceData(v reflect.Value, base, blen uintptr) uintptr {
+	etyp := v.Type().Elem()
+	esize := etyp.Size()
 	// Add size of the unscanned portion of the backing array to extra.
-	blen := uintptr(slice.Len()) * esize
 	marked := c.seen.countRange(base, blen)
+	if marked < blen {
+		c.recordAddr(base, blen, v.Type())
+	}
 	extra := blen - marked
 	c.seen.markRange(uintptr(base), blen)
-	if c.tc.needScan(slice.Type().Elem()) {
-		// Elements may contain pointers, scan them individually.
+	c.addMemory(reflect.Slice, address(base), extra)
+	c.obj.shared += marked
+	if esize > 0 {
+		c.obj.ptrWords += extra / esize * c.tc.pointerWords(etyp)
+		if c.headers {
+			c.obj.hdrWords += extra / esize * c.tc.headerWords(etyp)
+		}
+	}
+	if c.tc.needScan(etyp) {
+		// Elements may contain pointers, scan them individually. Slice copies the
+		// header, so it can't change when the world is restarted in between.
+		slice := v.Slice(0, v.Cap())
 		addr := address(base)
-		for i := 0; i < slice.Len(); i++ {
+		track := c.pointers != nil && isPointerLike(etyp.Kind())
+		for i := 0; i < slice.Len() && !c.stopped; i++ {
+			if track {
+				c.pointers.add(v.Type(), 0, 1, slice.Index(i))
+			}
+			p := c.enterIndex(i)
 			extra += c.scanContent(addr, slice.Index(i))
+			c.leavePath(p)
 			addr = addr.addOffset(esize)
 		}
 	}
 	return extra
 }
 
-func (c *context) scanMap(v reflect.Value) uintptr {
+func (c *scanState) scanMap(v reflect.Value) uintptr {
+	// Maps are identified by the address of their hash table, which is marked
+	// as seen to count maps reachable through several references only once.
+	p := v.Pointer()
+	if p == 0 || c.excluded(p) || c.seen.countRange(p, 1) > 0 {
+		return 0
+	}
+	c.seen.markRange(p, 1)
+	if c.bucketSizes != nil {
+		c.addMapStats(v)
+	}
+	if c.attributeData() {
+		return c.scanData(p, func() uintptr { return c.scanMapEntries(v) })
+	}
+	return c.scanMapEntries(v)
+}
+
+// scanMapEntries accounts and scans the entries of map v.
+func (c *scanState) scanMapEntries(v reflect.Value) uintptr {
 	var (
 		typ   = v.Type()
 		len   = uintptr(v.Len())
 		extra = uintptr(0)
 	)
 	if c.tc.needScan(typ.Key()) || c.tc.needScan(typ.Elem()) {
-		iterateMap(v, func(k, v reflect.Value) {
-			extra += c.scan(invalidAddr, k, false)
-			extra += c.scan(invalidAddr, v, false)
+		c.enterNoYield()
+		defer c.leaveNoYield()
+		iterateMap(v, func(k, v reflect.Value, kaddr, vaddr address) {
+			p := c.enterKey(k)
+			extra += c.scanBoxed(kaddr, k, reflect.Map)
+			extra += c.scanBoxed(vaddr, v, reflect.Map)
+			c.leavePath(p)
 		})
 	} else {
 		extra = len*typ.Key().Size() + len*typ.Elem().Size()
+		c.addMemory(reflect.Map, invalidAddr, extra)
 	}
 	return extra
 }
 
-func (c *context) scanInterface(v reflect.Value) uintptr {
+func (c *scanState) scanInterface(v reflect.Value) uintptr {
 	elem := v.Elem()
 	if !elem.IsValid() {
 		return 0 // nil interface
 	}
-	extra := c.scan(invalidAddr, elem, false)
-	if elem.Type().Kind() == reflect.Ptr {
-		extra -= uintptrBytes
+	if elem.Kind() == reflect.Ptr {
+		// The pointer is stored in the data word.
+		return c.scanContent(invalidAddr, elem)
 	}
+	return c.scanBoxed(invalidAddr, elem, reflect.Interface)
+}
+
+// scanBoxed scans a value which is stored outside of any counted object, e.g. a map
+// entry. Its memory is accounted to the given kind.
+func (c *scanState) scanBoxed(addr address, v reflect.Value, kind reflect.Kind) uintptr {
+	outer := c.boxKind
+	c.boxKind = kind
+	extra := c.scan(addr, v, false)
+	c.boxKind = outer
 	return extra
 }
+
+// addMemory accounts n bytes of memory at addr to the given kind and, if enabled,
+// to the address class of addr.
+func (c *scanState) addMemory(kind reflect.Kind, addr address, n uintptr) {
+	if c.skip {
+		return
+	}
+	if c.split > 1 {
+		n /= c.split
+	}
+	c.s.kinds[kind] += n
+	if c.budgetKinds != nil {
+		c.budgetKinds[kind] += n
+	}
+	if c.addrClasses {
+		c.s.classes[classifyAddress(addr)] += n
+	}
+}
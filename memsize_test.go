@@ -1,8 +1,17 @@
 package memsize
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
+
+	"github.com/fjl/memsize/internal/runtimefunc"
 )
 
 const (
@@ -49,316 +58,1705 @@ type (
 	array64        [64]byte
 )
 
+var totalTests = []struct {
+	name string
+	v    interface{}
+	want uintptr
+	// chanbuf is set for tests which need channel buffer access.
+	chanbuf bool
+	// overlap is set for tests with ranges starting inside other ranges, which
+	// are counted with block granularity by Options.ApproxDedup.
+	overlap bool
+}{
+	{
+		name: "struct16",
+		v:    &struct16{},
+		want: 16,
+	},
+	{
+		name: "structptr_nil",
+		v:    &structptr{},
+		want: 2 * sizeofWord,
+	},
+	{
+		name: "structptr",
+		v:    &structptr{cld: &structptr{}},
+		want: 2 * 2 * sizeofWord,
+	},
+	{
+		name: "structptr_loop",
+		v: func() *structptr {
+			v := &structptr{}
+			v.cld = v
+			return v
+		}(),
+		want: 2 * sizeofWord,
+	},
+	{
+		name: "structmultiptr_loop",
+		v: func() *structmultiptr {
+			v1 := &structptr{x: 1}
+			v2 := &structptr{x: 2, cld: v1}
+			return &structmultiptr{s1: v1, s2: v1, s3: v2}
+		}(),
+		want: 6*sizeofWord /* structmultiptr */ + 2*2*sizeofWord, /* structptr */
+	},
+	{
+		name:    "structmultiptr_interior",
+		overlap: true,
+		v: func() *structmultiptr {
+			v1 := &structptr{x: 1}
+			v2 := &structptr{x: 2}
+			return &structmultiptr{
+				// s1 is scanned before u1, which has a reference to a field of s1.
+				s1: v1,
+				u1: &structuint32ptr{x: &v1.x},
+				// This one goes the other way around: u2, which has a reference to a
+				// field of s3 is scanned before s3.
+				u2: &structuint32ptr{x: &v2.x},
+				s3: v2,
+			}
+		}(),
+		want: 6*sizeofWord /* structmultiptr */ + 2*2*sizeofWord /* structptr */ + 2*sizeofWord, /* structuint32ptr */
+	},
+	{
+		name: "struct64array",
+		v:    &struct64array{},
+		want: 64,
+	},
+	{
+		name: "structptrslice",
+		v:    &structptrslice{&structslice{s: []uint32{1, 2, 3}}},
+		want: sizeofWord + sizeofSlice + 3*4,
+	},
+	{
+		name: "array_unadressable",
+		v: func() *map[[3]uint64]struct{} {
+			v := map[[3]uint64]struct{}{
+				{1, 2, 3}: struct{}{},
+			}
+			return &v
+		}(),
+		want: sizeofMap + 3*8,
+	},
+	{
+		name: "structslice",
+		v:    &structslice{s: []uint32{1, 2, 3}},
+		want: sizeofSlice + 3*4,
+	},
+	{
+		name: "structloop",
+		v: func() *structloop {
+			v := new(structloop)
+			v.s = v
+			return v
+		}(),
+		want: sizeofWord,
+	},
+	{
+		name: "array64",
+		v:    &array64{},
+		want: 64,
+	},
+	{
+		name: "byteslice",
+		v:    &[]byte{1, 2, 3},
+		want: sizeofSlice + 3,
+	},
+	{
+		name: "slice3_ptrval",
+		v:    &[]*struct16{{}, {}, {}},
+		want: sizeofSlice + 3*sizeofWord + 3*16,
+	},
+	{
+		name: "map0",
+		v:    &map[uint64]uint64{},
+		want: sizeofMap,
+	},
+	{
+		name: "map3",
+		v:    &map[uint64]uint64{1: 1, 2: 2, 3: 3},
+		want: sizeofMap + 3*8 /* keys */ + 3*8, /* values */
+	},
+	{
+		name: "map3_ptrval",
+		v:    &map[uint64]*struct16{1: {}, 2: {}, 3: {}},
+		want: sizeofMap + 3*8 /* keys */ + 3*sizeofWord /* value pointers */ + 3*16, /* values */
+	},
+	{
+		name: "map3_ptrkey",
+		v:    &map[*struct16]uint64{{x: 1}: 1, {x: 2}: 2, {x: 3}: 3},
+		want: sizeofMap + 3*sizeofWord /* key pointers */ + 3*16 /* keys */ + 3*8, /* values */
+	},
+	{
+		name: "map_interface",
+		v:    &map[interface{}]interface{}{"aa": uint64(1)},
+		want: sizeofMap + sizeofInterface + sizeofString + 2 /* key */ + sizeofInterface + 8, /* value */
+	},
+	{
+		name: "pointerpointer",
+		v: func() **uint64 {
+			i := uint64(0)
+			p := &i
+			return &p
+		}(),
+		want: sizeofWord + 8,
+	},
+	{
+		name: "structstring",
+		v:    &structstring{"123"},
+		want: sizeofString + 3,
+	},
+	{
+		name:    "strings_shared",
+		overlap: true,
+		v: func() *[3]string {
+			s := strings.Repeat("x", 64)
+			return &[3]string{s, s[:16], s[32:]}
+		}(),
+		want: 3*sizeofString + 64,
+	},
+	{
+		name:    "slices_samearray",
+		overlap: true,
+		v: func() *[3][]byte {
+			backarray := [64]byte{}
+			return &[3][]byte{
+				backarray[16:],
+				backarray[4:16],
+				backarray[0:4],
+			}
+		}(),
+		want: 3*sizeofSlice + 64,
+	},
+	{
+		name: "slices_nil",
+		v: func() *[2][]byte {
+			return &[2][]byte{nil, nil}
+		}(),
+		want: 2 * sizeofSlice,
+	},
+	{
+		name: "slices_overlap_total",
+		v: func() *[2][]byte {
+			backarray := [32]byte{}
+			return &[2][]byte{backarray[:], backarray[:]}
+		}(),
+		want: 2*sizeofSlice + 32,
+	},
+	{
+		name:    "slices_overlap",
+		overlap: true,
+		v: func() *[4][]uint16 {
+			backarray := [32]uint16{}
+			return &[4][]uint16{
+				backarray[2:4],
+				backarray[10:12],
+				backarray[20:25],
+				backarray[:],
+			}
+		}(),
+		want: 4*sizeofSlice + 32*2,
+	},
+	{
+		name:    "slices_overlap_array",
+		overlap: true,
+		v: func() *struct {
+			a [32]byte
+			s [2][]byte
+		} {
+			v := struct {
+				a [32]byte
+				s [2][]byte
+			}{}
+			v.s[0] = v.a[2:4]
+			v.s[1] = v.a[5:8]
+			return &v
+		}(),
+		want: 32 + 2*sizeofSlice,
+	},
+	{
+		name: "interface",
+		v:    &[2]interface{}{uint64(0), &struct16{}},
+		want: 2*sizeofInterface + 8 + 16,
+	},
+	{
+		name: "interface_nil",
+		v:    &[2]interface{}{nil, nil},
+		want: 2 * sizeofInterface,
+	},
+	{
+		name: "structiface_slice",
+		v:    &structiface{x: make([]byte, 10)},
+		want: sizeofWord + sizeofInterface + sizeofSlice + 10,
+	},
+	{
+		name: "structiface_pointer",
+		v: func() *structiface {
+			s := &struct16{1, 2}
+			return &structiface{s: s, x: &s.x}
+		}(),
+		want: sizeofWord + 16 + sizeofInterface,
+	},
+	{
+		name: "empty_chan",
+		v: func() *chan uint64 {
+			c := make(chan uint64)
+			return &c
+		}(),
+		want: sizeofChan,
+	},
+	{
+		name: "empty_closed_chan",
+		v: func() *chan uint64 {
+			c := make(chan uint64)
+			close(c)
+			return &c
+		}(),
+		want: sizeofChan,
+	},
+	{
+		name: "empty_chan_buffer",
+		v: func() *chan uint64 {
+			c := make(chan uint64, 10)
+			return &c
+		}(),
+		want: sizeofChan + 10*8,
+	},
+	{
+		name: "chan_buffer",
+		v: func() *chan uint64 {
+			c := make(chan uint64, 10)
+			for i := 0; i < 8; i++ {
+				c <- 0
+			}
+			return &c
+		}(),
+		want: sizeofChan + 10*8,
+	},
+	{
+		name: "closed_chan_buffer",
+		v: func() *chan uint64 {
+			c := make(chan uint64, 10)
+			for i := 0; i < 8; i++ {
+				c <- 0
+			}
+			close(c)
+			return &c
+		}(),
+		want: sizeofChan + 10*8,
+	},
+	{
+		name: "chan_buffer_escan",
+		v: func() *chan *struct16 {
+			c := make(chan *struct16, 10)
+			for i := 0; i < 8; i++ {
+				c <- &struct16{x: uint64(i)}
+			}
+			return &c
+		}(),
+		want:    sizeofChan + 10*sizeofWord + 8*16,
+		chanbuf: true,
+	},
+	{
+		name: "closed_chan_buffer_escan",
+		v: func() *chan *struct16 {
+			c := make(chan *struct16, 10)
+			for i := 0; i < 8; i++ {
+				c <- &struct16{x: uint64(i)}
+			}
+			close(c)
+			return &c
+		}(),
+		want:    sizeofChan + 10*sizeofWord + 8*16,
+		chanbuf: true,
+	},
+	{
+		name: "nil_chan",
+		v: func() *chan *struct16 {
+			var c chan *struct16
+			return &c
+		}(),
+		want: sizeofChan,
+	},
+}
+
 func TestTotal(t *testing.T) {
+	testTotal(t, Options{})
+}
+
+func TestTotalApproxDedup(t *testing.T) {
+	testTotal(t, Options{ApproxDedup: true, ApproxDedupBytes: 1024 * 1024})
+}
+
+// TestApproxDedupLarge checks the total of a scan visiting much more memory than
+// the size of the Bloom filter.
+func TestApproxDedupLarge(t *testing.T) {
+	type node struct {
+		next *node
+		data []byte
+		sub  []byte // shares data
+		name string
+	}
+	var list *node
+	for i := 0; i < 4000; i++ {
+		n := &node{next: list, data: make([]byte, 1000), name: strings.Repeat("x", i%50)}
+		n.sub = n.data[100:200]
+		list = n
+	}
+	exact := Scan(&list)
+	opts := Options{ApproxDedup: true, ApproxDedupEntries: 20000}
+	approx := ScanWithOptions(&list, opts)
+	if exact.Total < 8*approx.BitmapSize {
+		t.Fatalf("scan of %d bytes isn't larger than the filter (%d bytes)", exact.Total, approx.BitmapSize)
+	}
+	// The subslices overlap blocks counted before by up to 256 bytes each.
+	min, max := exact.Total-exact.Total/100, exact.Total+4000*bloomBlock
+	if approx.Total < min || approx.Total > max {
+		t.Errorf("approximate total %d, want %d, tolerance [%d, %d]", approx.Total, exact.Total, min, max)
+	}
+}
+
+func testTotal(t *testing.T, opts Options) {
+	for _, test := range totalTests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.chanbuf && !haveChanbuf {
+				t.Skip("channel buffer access not available")
+			}
+			size := ScanWithOptions(test.v, opts)
+			if opts.ApproxDedup && test.overlap {
+				if diff := int(size.Total) - int(test.want); diff < -bloomBlock || diff > bloomBlock {
+					t.Errorf("total=%d, want %d ± %d", size.Total, test.want, bloomBlock)
+				}
+				return
+			}
+			if size.Total != test.want {
+				t.Errorf("total=%d, want %d", size.Total, test.want)
+				t.Logf("\n%s", size.Report())
+			}
+		})
+	}
+}
+
+func TestIterateMapSlots(t *testing.T) {
+	if !haveMapSlots {
+		t.Skip("map slot addresses not available")
+	}
+	m := map[string][]int{"a": {1}, "b": {2, 3}, "c": nil}
+	rv := reflect.ValueOf(m)
+	n := 0
+	iterateMap(rv, func(k, v reflect.Value, kaddr, vaddr address) {
+		n++
+		if !kaddr.valid() || !vaddr.valid() {
+			t.Fatalf("invalid slot address for key %q", k.String())
+		}
+		if uintptr(kaddr) != k.UnsafeAddr() || uintptr(vaddr) != v.UnsafeAddr() {
+			t.Fatalf("slot address mismatch for key %q", k.String())
+		}
+		if want := rv.MapIndex(k); !reflect.DeepEqual(v.Interface(), want.Interface()) {
+			t.Fatalf("wrong value for key %q: got %v, want %v", k.String(), v, want)
+		}
+	})
+	if n != len(m) {
+		t.Fatalf("iterated %d entries, want %d", n, len(m))
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	v1 := &structptr{x: 1, cld: &structptr{x: 2}}
+	v := &structmultiptr{s1: v1, u1: &structuint32ptr{x: new(uint32)}}
+	sizes := ScanWithOptions(v, Options{Ownership: true})
+
+	var (
+		tMulti  = reflect.TypeOf(structmultiptr{})
+		tPtr    = reflect.TypeOf(structptr{})
+		tU32Ptr = reflect.TypeOf(structuint32ptr{})
+		tU32    = reflect.TypeOf(uint32(0))
+	)
+	want := map[reflect.Type]map[reflect.Type]uintptr{
+		tMulti:  {tPtr: 2 * sizeofWord, tU32Ptr: sizeofWord},
+		tPtr:    {tPtr: 2 * sizeofWord},
+		tU32Ptr: {tU32: 4},
+	}
+	if !reflect.DeepEqual(sizes.Ownership, want) {
+		t.Fatalf("wrong ownership matrix:\ngot  %v\nwant %v", sizes.Ownership, want)
+	}
+	if Scan(v).Ownership != nil {
+		t.Fatal("ownership matrix recorded without Options.Ownership")
+	}
+}
+
+func TestAccountant(t *testing.T) {
+	type file struct{ fd int }
+	type owner struct {
+		name  string
+		files []*file
+	}
+	v := &owner{name: "x", files: []*file{{1}, {2}, {3}}}
+	fds := AccountantFunc(func(v reflect.Value) (string, uint64) {
+		if v.Type() == reflect.TypeOf(file{}) {
+			return "fds", 1
+		}
+		return "", 0
+	})
+	sizes := ScanWithOptions(v, Options{Accountants: []Accountant{fds}})
+
+	if sizes.External["fds"] != 3 {
+		t.Errorf("wrong total external count %d, want 3", sizes.External["fds"])
+	}
+	if c := sizes.ByType[reflect.TypeOf(file{})].External["fds"]; c != 3 {
+		t.Errorf("wrong external count for file %d, want 3", c)
+	}
+	if ext := sizes.ByType[reflect.TypeOf(owner{})].External; ext != nil {
+		t.Errorf("unexpected external resources for owner: %v", ext)
+	}
+	t.Logf("\n%s", sizes.Report())
+}
+
+func TestScanContext(t *testing.T) {
+	list := new(structptr)
+	for i := 0; i < 100000; i++ {
+		list = &structptr{cld: list}
+	}
+
+	// Scanning with an expired deadline returns an empty result immediately.
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if sizes := ScanContext(ctx, list); !sizes.Partial || sizes.Total != 0 {
+		t.Fatalf("wrong result for expired context: partial %t, total %d", sizes.Partial, sizes.Total)
+	}
+
+	// Scanning with a deadline that expires during the scan returns a partial result.
+	full := Scan(list)
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(time.Millisecond))
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		sizes := ScanContext(ctx, list)
+		if sizes.Partial {
+			if sizes.Total >= full.Total {
+				t.Fatalf("partial scan total %d not smaller than full total %d", sizes.Total, full.Total)
+			}
+			return
+		}
+	}
+	t.Skip("scan completed before deadline")
+}
+
+// stwContext is a context which fails the test when it is used during a scan.
+// Its Done channel is closed, but Err reports nil to get past the check before the
+// world is stopped.
+type stwContext struct {
+	t        *testing.T
+	done     chan struct{}
+	scanning bool
+}
+
+func (ctx *stwContext) check(method string) {
+	if ctx.scanning {
+		ctx.t.Errorf("context method %s called during scan", method)
+	}
+}
+
+func (ctx *stwContext) Deadline() (time.Time, bool) {
+	ctx.check("Deadline")
+	return time.Time{}, false
+}
+
+func (ctx *stwContext) Done() <-chan struct{} {
+	ctx.check("Done")
+	return ctx.done
+}
+
+func (ctx *stwContext) Err() error {
+	ctx.check("Err")
+	return nil
+}
+
+func (ctx *stwContext) Value(key interface{}) interface{} {
+	ctx.check("Value")
+	return nil
+}
+
+func TestScanContextStopped(t *testing.T) {
+	list := new(structptr)
+	for i := 0; i < 100000; i++ {
+		list = &structptr{cld: list}
+	}
+	ctx := &stwContext{t: t, done: make(chan struct{})}
+	close(ctx.done)
+	s := Scanner{Options: Options{OnValue: func(string, reflect.Value) Action {
+		ctx.scanning = true
+		return Continue
+	}}}
+	if sizes := s.ScanContext(ctx, list); !sizes.Partial {
+		t.Fatal("scan not interrupted by closed Done channel")
+	}
+}
+
+func TestPointerWords(t *testing.T) {
 	tests := []struct {
 		name string
 		v    interface{}
+		typ  reflect.Type
 		want uintptr
 	}{
 		{
 			name: "struct16",
-			v:    &struct16{},
-			want: 16,
-		},
-		{
-			name: "structptr_nil",
-			v:    &structptr{},
-			want: 2 * sizeofWord,
+			v:    &struct{ s *struct16 }{&struct16{}},
+			typ:  reflect.TypeOf(struct16{}),
+			want: 0,
 		},
 		{
 			name: "structptr",
-			v:    &structptr{cld: &structptr{}},
-			want: 2 * 2 * sizeofWord,
-		},
-		{
-			name: "structptr_loop",
-			v: func() *structptr {
-				v := &structptr{}
-				v.cld = v
-				return v
-			}(),
-			want: 2 * sizeofWord,
-		},
-		{
-			name: "structmultiptr_loop",
-			v: func() *structmultiptr {
-				v1 := &structptr{x: 1}
-				v2 := &structptr{x: 2, cld: v1}
-				return &structmultiptr{s1: v1, s2: v1, s3: v2}
-			}(),
-			want: 6*sizeofWord /* structmultiptr */ + 2*2*sizeofWord, /* structptr */
-		},
-		{
-			name: "structmultiptr_interior",
-			v: func() *structmultiptr {
-				v1 := &structptr{x: 1}
-				v2 := &structptr{x: 2}
-				return &structmultiptr{
-					// s1 is scanned before u1, which has a reference to a field of s1.
-					s1: v1,
-					u1: &structuint32ptr{x: &v1.x},
-					// This one goes the other way around: u2, which has a reference to a
-					// field of s3 is scanned before s3.
-					u2: &structuint32ptr{x: &v2.x},
-					s3: v2,
-				}
-			}(),
-			want: 6*sizeofWord /* structmultiptr */ + 2*2*sizeofWord /* structptr */ + 2*sizeofWord, /* structuint32ptr */
-		},
-		{
-			name: "struct64array",
-			v:    &struct64array{},
-			want: 64,
-		},
-		{
-			name: "structptrslice",
-			v:    &structptrslice{&structslice{s: []uint32{1, 2, 3}}},
-			want: sizeofWord + sizeofSlice + 3*4,
-		},
-		{
-			name: "array_unadressable",
-			v: func() *map[[3]uint64]struct{} {
-				v := map[[3]uint64]struct{}{
-					{1, 2, 3}: struct{}{},
-				}
-				return &v
-			}(),
-			want: sizeofMap + 3*8,
-		},
-		{
-			name: "structslice",
-			v:    &structslice{s: []uint32{1, 2, 3}},
-			want: sizeofSlice + 3*4,
-		},
-		{
-			name: "structloop",
-			v: func() *structloop {
-				v := new(structloop)
-				v.s = v
-				return v
-			}(),
-			want: sizeofWord,
-		},
-		{
-			name: "array64",
-			v:    &array64{},
-			want: 64,
-		},
-		{
-			name: "byteslice",
-			v:    &[]byte{1, 2, 3},
-			want: sizeofSlice + 3,
-		},
-		{
-			name: "slice3_ptrval",
-			v:    &[]*struct16{{}, {}, {}},
-			want: sizeofSlice + 3*sizeofWord + 3*16,
-		},
-		{
-			name: "map0",
-			v:    &map[uint64]uint64{},
-			want: sizeofMap,
-		},
-		{
-			name: "map3",
-			v:    &map[uint64]uint64{1: 1, 2: 2, 3: 3},
-			want: sizeofMap + 3*8 /* keys */ + 3*8, /* values */
-		},
-		{
-			name: "map3_ptrval",
-			v:    &map[uint64]*struct16{1: {}, 2: {}, 3: {}},
-			want: sizeofMap + 3*8 /* keys */ + 3*sizeofWord /* value pointers */ + 3*16, /* values */
-		},
-		{
-			name: "map3_ptrkey",
-			v:    &map[*struct16]uint64{{x: 1}: 1, {x: 2}: 2, {x: 3}: 3},
-			want: sizeofMap + 3*sizeofWord /* key pointers */ + 3*16 /* keys */ + 3*8, /* values */
-		},
-		{
-			name: "map_interface",
-			v:    &map[interface{}]interface{}{"aa": uint64(1)},
-			want: sizeofMap + sizeofInterface + sizeofString + 2 /* key */ + sizeofInterface + 8, /* value */
-		},
-		{
-			name: "pointerpointer",
-			v: func() **uint64 {
-				i := uint64(0)
-				p := &i
-				return &p
-			}(),
-			want: sizeofWord + 8,
-		},
-		{
-			name: "structstring",
-			v:    &structstring{"123"},
-			want: sizeofString + 3,
-		},
-		{
-			name: "slices_samearray",
-			v: func() *[3][]byte {
-				backarray := [64]byte{}
-				return &[3][]byte{
-					backarray[16:],
-					backarray[4:16],
-					backarray[0:4],
-				}
-			}(),
-			want: 3*sizeofSlice + 64,
+			v:    &struct{ s *structptr }{&structptr{}},
+			typ:  reflect.TypeOf(structptr{}),
+			want: 1,
 		},
 		{
-			name: "slices_nil",
-			v: func() *[2][]byte {
-				return &[2][]byte{nil, nil}
-			}(),
-			want: 2 * sizeofSlice,
+			name: "ptrslice",
+			v:    &struct{ s *[]*struct16 }{&[]*struct16{nil, nil, nil}},
+			typ:  reflect.TypeOf([]*struct16{}),
+			want: 1 + 3,
 		},
 		{
-			name: "slices_overlap_total",
-			v: func() *[2][]byte {
-				backarray := [32]byte{}
-				return &[2][]byte{backarray[:], backarray[:]}
-			}(),
-			want: 2*sizeofSlice + 32,
-		},
-		{
-			name: "slices_overlap",
-			v: func() *[4][]uint16 {
-				backarray := [32]uint16{}
-				return &[4][]uint16{
-					backarray[2:4],
-					backarray[10:12],
-					backarray[20:25],
-					backarray[:],
-				}
-			}(),
-			want: 4*sizeofSlice + 32*2,
-		},
-		{
-			name: "slices_overlap_array",
-			v: func() *struct {
-				a [32]byte
-				s [2][]byte
-			} {
-				v := struct {
-					a [32]byte
-					s [2][]byte
-				}{}
-				v.s[0] = v.a[2:4]
-				v.s[1] = v.a[5:8]
-				return &v
-			}(),
-			want: 32 + 2*sizeofSlice,
+			name: "interface_ptr",
+			v:    &struct{ s *[1]interface{} }{&[1]interface{}{&struct16{}}},
+			typ:  reflect.TypeOf([1]interface{}{}),
+			want: 2,
 		},
 		{
-			name: "interface",
-			v:    &[2]interface{}{uint64(0), &struct16{}},
-			want: 2*sizeofInterface + 8 + 16,
-		},
-		{
-			name: "interface_nil",
-			v:    &[2]interface{}{nil, nil},
-			want: 2 * sizeofInterface,
-		},
-		{
-			name: "structiface_slice",
-			v:    &structiface{x: make([]byte, 10)},
-			want: sizeofWord + sizeofInterface + sizeofSlice + 10,
-		},
-		{
-			name: "structiface_pointer",
-			v: func() *structiface {
-				s := &struct16{1, 2}
-				return &structiface{s: s, x: &s.x}
-			}(),
-			want: sizeofWord + 16 + sizeofInterface,
-		},
-		{
-			name: "empty_chan",
-			v: func() *chan uint64 {
-				c := make(chan uint64)
-				return &c
-			}(),
-			want: sizeofChan,
-		},
-		{
-			name: "empty_closed_chan",
-			v: func() *chan uint64 {
-				c := make(chan uint64)
-				close(c)
-				return &c
-			}(),
-			want: sizeofChan,
-		},
-		{
-			name: "empty_chan_buffer",
-			v: func() *chan uint64 {
-				c := make(chan uint64, 10)
-				return &c
-			}(),
-			want: sizeofChan + 10*8,
-		},
-		{
-			name: "chan_buffer",
-			v: func() *chan uint64 {
-				c := make(chan uint64, 10)
-				for i := 0; i < 8; i++ {
-					c <- 0
-				}
-				return &c
-			}(),
-			want: sizeofChan + 10*8,
-		},
-		{
-			name: "closed_chan_buffer",
-			v: func() *chan uint64 {
-				c := make(chan uint64, 10)
-				for i := 0; i < 8; i++ {
-					c <- 0
-				}
-				close(c)
-				return &c
-			}(),
-			want: sizeofChan + 10*8,
-		},
-		{
-			name: "chan_buffer_escan",
-			v: func() *chan *struct16 {
-				c := make(chan *struct16, 10)
-				for i := 0; i < 8; i++ {
-					c <- &struct16{x: uint64(i)}
-				}
-				return &c
-			}(),
-			want: sizeofChan + 10*sizeofWord + 8*16,
-		},
-		{
-			name: "closed_chan_buffer_escan",
-			v: func() *chan *struct16 {
-				c := make(chan *struct16, 10)
-				for i := 0; i < 8; i++ {
-					c <- &struct16{x: uint64(i)}
-				}
-				close(c)
-				return &c
-			}(),
-			want: sizeofChan + 10*sizeofWord + 8*16,
-		},
-		{
-			name: "nil_chan",
-			v: func() *chan *struct16 {
-				var c chan *struct16
-				return &c
-			}(),
-			want: sizeofChan,
+			name: "interface_string",
+			v:    &struct{ s *[1]interface{} }{&[1]interface{}{"abc"}},
+			typ:  reflect.TypeOf([1]interface{}{}),
+			want: 2 + 1,
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			size := Scan(test.v)
-			if size.Total != test.want {
-				t.Errorf("total=%d, want %d", size.Total, test.want)
-				t.Logf("\n%s", size.Report())
+			sizes := Scan(test.v)
+			ts := sizes.ByType[test.typ]
+			if ts == nil {
+				t.Fatalf("type %v not found in result", test.typ)
+			}
+			if ts.PointerWords != test.want {
+				t.Errorf("PointerWords = %d, want %d", ts.PointerWords, test.want)
 			}
 		})
 	}
 }
+
+func TestStringPins(t *testing.T) {
+	type holder struct{ s string }
+	big := strings.Repeat("x", 1000)
+	v := &struct {
+		big   *string
+		small *holder
+		other *holder
+	}{
+		big:   &big,
+		small: &holder{big[10:20]},
+		other: &holder{strings.Repeat("y", 10)},
+	}
+	sizes := ScanWithOptions(v, Options{StringPins: true})
+	want := []StringPin{{Owner: reflect.TypeOf(holder{}), Len: 10, Backing: 1000}}
+	if haveObjectSize() {
+		want[0].Backing = runtimefunc.ObjectSize(stringData(big))
+	}
+	if !reflect.DeepEqual(sizes.StringPins, want) {
+		t.Fatalf("wrong string pins: %+v, want %+v", sizes.StringPins, want)
+	}
+}
+
+func TestStringPinsUnreferenced(t *testing.T) {
+	if !haveObjectSize() {
+		t.Skip("object sizes not available")
+	}
+	type holder struct{ s string }
+	big := make([]byte, 4000)
+	v := &holder{string(big)[:8]} // the only reference to the string data
+	sizes := ScanWithOptions(v, Options{StringPins: true})
+	want := []StringPin{{Owner: reflect.TypeOf(holder{}), Len: 8, Backing: runtimefunc.ObjectSize(stringData(v.s))}}
+	if want[0].Backing < 4000 || !reflect.DeepEqual(sizes.StringPins, want) {
+		t.Fatalf("wrong string pins: %+v, want %+v", sizes.StringPins, want)
+	}
+}
+
+func haveObjectSize() bool {
+	return haveAddrClass && runtimefunc.HaveObjectSize
+}
+
+func TestShared(t *testing.T) {
+	type holder struct{ b []byte }
+	buf := make([]byte, 100)
+	v := &struct {
+		a, b *holder
+	}{
+		a: &holder{buf[:50]},
+		b: &holder{buf[20:60]},
+	}
+	sizes := Scan(v)
+	ts := sizes.ByType[reflect.TypeOf(holder{})]
+	// a is scanned first and gets the backing array from 0 to cap.
+	// b's backing array (20 to cap) is entirely shared.
+	if ts.Referenced != 100 || ts.Shared != 80 {
+		t.Fatalf("wrong sizes: referenced %d, shared %d", ts.Referenced, ts.Shared)
+	}
+}
+
+func TestByKind(t *testing.T) {
+	type holder struct {
+		s string
+		b []byte
+		m map[int32]int32
+		i interface{}
+	}
+	v := &holder{
+		s: strings.Repeat("x", 10),
+		b: make([]byte, 20),
+		m: map[int32]int32{1: 1, 2: 2},
+		i: struct16{},
+	}
+	sizes := Scan(v)
+	want := map[reflect.Kind]uintptr{
+		reflect.Struct:    reflect.TypeOf(holder{}).Size(),
+		reflect.String:    10,
+		reflect.Slice:     20,
+		reflect.Map:       2 * 8,
+		reflect.Interface: 16,
+	}
+	if got := sizes.ByKind(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong kind totals:\ngot  %v\nwant %v", got, want)
+	}
+	var sum uintptr
+	for _, size := range want {
+		sum += size
+	}
+	if sum != sizes.Total {
+		t.Fatalf("kind totals add up to %d, want %d", sum, sizes.Total)
+	}
+
+	report := string(sizes.AppendReport(nil, ReportOptions{ByKind: true}))
+	lines := strings.Split(strings.TrimSpace(report), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "ALL") || !strings.HasSuffix(lines[0], "100%") {
+		t.Fatalf("wrong kind report:\n%s", report)
+	}
+}
+
+func TestCycles(t *testing.T) {
+	v1 := &structptr{x: 1}
+	v2 := &structptr{x: 2, cld: v1}
+	v1.cld = v2
+	v := &structmultiptr{s1: v1, s2: v2, u1: &structuint32ptr{}}
+
+	sizes := ScanWithOptions(v, Options{Cycles: true, MaxCyclePaths: 1})
+	tPtr := reflect.TypeOf(structptr{})
+	if want := map[reflect.Type]uintptr{tPtr: 1}; !reflect.DeepEqual(sizes.Cycles, want) {
+		t.Fatalf("wrong cycles: %v", sizes.Cycles)
+	}
+	wantPath := "memsize.structptr -> memsize.structptr -> memsize.structptr"
+	if len(sizes.CyclePaths) != 1 || sizes.CyclePaths[0].String() != wantPath {
+		t.Fatalf("wrong cycle paths: %v", sizes.CyclePaths)
+	}
+
+	// Reaching an object twice without a cycle isn't reported.
+	v1.cld = nil
+	if sizes := ScanWithOptions(v, Options{Cycles: true}); len(sizes.Cycles) != 0 {
+		t.Fatalf("cycles reported for acyclic graph: %v", sizes.Cycles)
+	}
+	if sizes := Scan(v); sizes.Cycles != nil {
+		t.Fatal("cycles recorded without Options.Cycles")
+	}
+}
+
+func TestMaxPerType(t *testing.T) {
+	type node struct {
+		next *node
+		data []byte
+	}
+	// Build four lists of ten nodes each.
+	var lists []*node
+	for i := 0; i < 4; i++ {
+		var head *node
+		for j := 0; j < 10; j++ {
+			head = &node{next: head, data: make([]byte, 16)}
+		}
+		lists = append(lists, head)
+	}
+	sizes := ScanWithOptions(&lists, Options{MaxPerType: map[reflect.Type]int{reflect.TypeOf(node{}): 3}})
+	ts := sizes.ByType[reflect.TypeOf(node{})]
+	// The first list is traversed until the budget is exhausted. The other nodes
+	// are reached through the next pointers, but their data is estimated.
+	if ts.Count != 40 || ts.Estimated != 37 {
+		t.Fatalf("wrong count %d, estimated %d", ts.Count, ts.Estimated)
+	}
+	if want := ts.Count * 16; ts.Referenced != want {
+		t.Fatalf("wrong referenced memory %d, want %d", ts.Referenced, want)
+	}
+	// Extrapolated memory is accounted to the kind of the data.
+	exact := Scan(&lists)
+	if got, want := sizes.ByKind(), exact.ByKind(); !reflect.DeepEqual(got, want) || sizes.Total != exact.Total {
+		t.Fatalf("wrong kinds %v, want %v", got, want)
+	}
+}
+
+func TestMaxPerTypeTree(t *testing.T) {
+	type tree struct {
+		children []*tree
+		name     string
+	}
+	var build func(depth int) *tree
+	build = func(depth int) *tree {
+		n := &tree{name: strings.Repeat("x", 8)}
+		for i := 0; depth > 0 && i < 3; i++ {
+			n.children = append(n.children, build(depth-1))
+		}
+		return n
+	}
+	root := build(4) // 121 nodes
+	typ := reflect.TypeOf(tree{})
+	exact := Scan(&root).ByType[typ]
+	sizes := ScanWithOptions(&root, Options{MaxPerType: map[reflect.Type]int{typ: 10}})
+	ts := sizes.ByType[typ]
+	if ts.Count != exact.Count || ts.Estimated != exact.Count-10 {
+		t.Fatalf("wrong count %d, estimated %d, want %d, %d", ts.Count, ts.Estimated, exact.Count, exact.Count-10)
+	}
+	if ts.Shallow != exact.Shallow || ts.Referenced == 0 {
+		t.Fatalf("wrong sizes %+v, want about %+v", ts, exact)
+	}
+}
+
+func TestGoroutines(t *testing.T) {
+	sizes := ScanWithOptions(&struct16{}, Options{GoroutineStacks: true})
+	g := sizes.Goroutines
+	if g.Count < 1 || g.StackBytes == 0 {
+		t.Fatalf("wrong goroutine stats: %+v", g)
+	}
+	if g.ByFunction["testing.tRunner"] < 1 {
+		t.Fatalf("test goroutine not found in functions: %v", g.ByFunction)
+	}
+}
+
+func TestGoroutineFunctions(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.(*server).run(0xc000010000)
+	/src/main.go:10 +0x1d
+main.main()
+	/src/main.go:5 +0x25
+
+goroutine 6 [chan receive]:
+main.worker(...)
+	/src/main.go:20
+created by main.main in goroutine 1
+	/src/main.go:6 +0x30
+
+goroutine 7 [chan receive]:
+main.worker(0x1)
+	/src/main.go:20 +0x10
+created by main.main in goroutine 1
+	/src/main.go:6 +0x30
+`
+	want := map[string]int{"main.main": 1, "main.worker": 2}
+	if got := goroutineFunctions([]byte(dump)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong functions: %v", got)
+	}
+}
+
+func TestRetainStats(t *testing.T) {
+	type key struct{}
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		ctx = context.WithValue(ctx, key{}, &struct16{})
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	v := &struct {
+		ctx   context.Context
+		timer *time.Timer
+	}{ctx, timer}
+
+	sizes := Scan(v)
+	if c := sizes.Contexts; c.Count != 6 || c.MaxDepth != 6 || c.Retained < 5*16 {
+		t.Errorf("wrong context stats: %+v", c)
+	}
+	if tm := sizes.Timers; tm.Count != 1 || tm.MaxDepth != 1 || tm.Retained < unsafe.Sizeof(time.Timer{}) {
+		t.Errorf("wrong timer stats: %+v", tm)
+	}
+	if sizes.Total < sizes.Contexts.Retained+sizes.Timers.Retained {
+		t.Errorf("retained memory exceeds total %d: %+v %+v", sizes.Total, sizes.Contexts, sizes.Timers)
+	}
+}
+
+func TestRetainStatsAfterFunc(t *testing.T) {
+	if !haveTimerArg || !runtimefunc.HavePointerWords {
+		t.Skip("timer functions not available")
+	}
+	buf := make([]byte, 1<<20)
+	timer := time.AfterFunc(time.Hour, func() { buf[0]++ })
+	defer timer.Stop()
+	v := &struct{ timer *time.Timer }{timer}
+
+	sizes := Scan(v)
+	if tm := sizes.Timers; tm.Count != 1 {
+		t.Errorf("wrong timer stats: %+v", tm)
+	}
+	// The timer's function captures buf, which is counted with the closure.
+	bs := sizes.Unscannable[reflect.TypeOf(time.Timer{})]
+	if bs == nil || bs.Count != 1 || bs.Bytes < uintptr(len(buf)) {
+		t.Errorf("wrong unscannable stats for timer: %v", sizes.Unscannable)
+	}
+}
+
+var (
+	classGlobal structptr
+	classHeap   *structslice
+)
+
+func TestAddressClasses(t *testing.T) {
+	if !haveAddrClass {
+		t.Skip("address classification not available")
+	}
+	opts := Options{AddressClasses: true}
+	sizes := ScanWithOptions(&classGlobal, opts)
+	if sizes.RootClass != ClassGlobal || !reflect.DeepEqual(sizes.ByAddressClass(), map[AddressClass]uintptr{ClassGlobal: 2 * sizeofWord}) {
+		t.Errorf("wrong classes for global: root %v, %v", sizes.RootClass, sizes.ByAddressClass())
+	}
+
+	classHeap = &structslice{s: make([]uint32, 100)}
+	sizes = ScanWithOptions(classHeap, opts)
+	want := map[AddressClass]uintptr{ClassHeap: sizeofSlice + 400}
+	if sizes.RootClass != ClassHeap || !reflect.DeepEqual(sizes.ByAddressClass(), want) {
+		t.Errorf("wrong classes for heap value: root %v, %v", sizes.RootClass, sizes.ByAddressClass())
+	}
+
+	// Whether the local variable is on the stack depends on escape analysis.
+	var local struct16
+	sizes = ScanWithOptions(&local, opts)
+	if sizes.RootClass != ClassStack && sizes.RootClass != ClassHeap {
+		t.Errorf("wrong class for local variable: %v", sizes.RootClass)
+	}
+
+	if ScanWithOptions(&classGlobal, Options{}).ByAddressClass()[ClassGlobal] != 0 {
+		t.Error("classes recorded without Options.AddressClasses")
+	}
+}
+
+func TestNilPointers(t *testing.T) {
+	nodes := make([]*structptr, 4)
+	nodes[0] = &structptr{cld: &structptr{}}
+	nodes[1] = &structptr{}
+	sizes := ScanWithOptions(&nodes, Options{NilPointers: true})
+	want := map[reflect.Type]map[string]PointerStats{
+		reflect.TypeOf(structptr{}):    {"cld": {Nil: 2, NonNil: 1}},
+		reflect.TypeOf([]*structptr{}): {"[]": {Nil: 2, NonNil: 2}},
+	}
+	if !reflect.DeepEqual(sizes.Pointers, want) {
+		t.Fatalf("wrong pointer stats: %v", sizes.Pointers)
+	}
+	report := sizes.NilPointerReport()
+	if !strings.HasPrefix(report, "[]*memsize.structptr[]  nil 2  non-nil 2  (50.0% nil)\n") {
+		t.Fatalf("wrong report:\n%s", report)
+	}
+	if Scan(&nodes).Pointers != nil {
+		t.Fatal("pointer stats recorded without Options.NilPointers")
+	}
+}
+
+func TestChanOccupiedOnly(t *testing.T) {
+	ch := make(chan *struct16, 8)
+	for i := 0; i < 6; i++ {
+		ch <- &struct16{}
+	}
+	for i := 0; i < 3; i++ {
+		<-ch
+	}
+	// Wrap around the end of the buffer.
+	for i := 0; i < 4; i++ {
+		ch <- &struct16{}
+	}
+	sizes := ScanWithOptions(&ch, Options{ChanOccupiedOnly: true})
+	want := sizeofChan + 7*sizeofWord
+	if haveChanbuf {
+		want += 7 * 16
+	}
+	if sizes.Total != want || sizes.UnusedChanCapacity != sizeofWord {
+		t.Fatalf("total %d, unused %d; want total %d, unused %d", sizes.Total, sizes.UnusedChanCapacity, want, sizeofWord)
+	}
+	if haveChanbuf && haveInternals && chanRecvxOffset == 0 {
+		t.Fatal("channel receive index not found")
+	}
+}
+
+func TestChanStats(t *testing.T) {
+	jobs := make(chan *struct16, 4)
+	for i := 0; i < 4; i++ {
+		jobs <- &struct16{}
+	}
+	v := &struct {
+		in, out chan *struct16 // the same channel, seen by two stages
+		names   chan string
+		idle    chan uint64
+	}{in: jobs, out: jobs, names: make(chan string, 10), idle: make(chan uint64, 100)}
+	v.names <- "abc"
+
+	sizes := ScanWithOptions(v, Options{ChanStats: true})
+	cs := sizes.Chans[reflect.TypeOf(jobs)]
+	if cs == nil || cs.Count != 1 || cs.Len != 4 || cs.Cap != 4 || cs.BufferBytes != 4*sizeofWord {
+		t.Fatalf("wrong stats for shared channel: %+v", cs)
+	}
+	if want := 4 * uintptr(16); haveChanbuf && cs.ElemBytes != want {
+		t.Errorf("element bytes %d, want %d", cs.ElemBytes, want)
+	}
+	if cs := sizes.Chans[reflect.TypeOf(v.names)]; haveChanbuf && cs.ElemBytes != 3 {
+		t.Errorf("string element bytes %d, want 3", cs.ElemBytes)
+	}
+	if cs := sizes.Chans[reflect.TypeOf(v.idle)]; cs.Len != 0 || cs.BufferBytes != 800 {
+		t.Errorf("wrong stats for idle channel: %+v", cs)
+	}
+	report := sizes.ChanReport()
+	lines := strings.Split(strings.TrimSpace(report), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "chan uint64 ") || !strings.HasSuffix(lines[2], "  full") {
+		t.Errorf("wrong report:\n%s", report)
+	}
+	if sizes.Total != Scan(v).Total {
+		t.Errorf("total %d differs from total without stats", sizes.Total)
+	}
+}
+
+func TestExcludeRanges(t *testing.T) {
+	mapped := make([]byte, 4096)
+	v := &struct {
+		b []byte
+		s string
+		p *byte
+		q *struct16
+	}{
+		b: mapped[8:16],
+		s: string(mapped[:0]) + "not mapped",
+		p: &mapped[100],
+		q: &struct16{},
+	}
+	sizes := ScanWithOptions(v, Options{ExcludeRanges: []AddressRange{RangeOf(mapped)}})
+	want := unsafe.Sizeof(*v) + uintptr(len(v.s)) + 16
+	if sizes.Total != want {
+		t.Fatalf("total %d, want %d", sizes.Total, want)
+	}
+}
+
+func TestExcludeRangesSpecial(t *testing.T) {
+	m := map[int]*struct16{1: {}}
+	mp := reflect.ValueOf(m).Pointer()
+	sizes := ScanWithOptions(&m, Options{ExcludeRanges: []AddressRange{{mp, mp + 1}}})
+	if ts := sizes.ByType[reflect.TypeOf(struct16{})]; ts != nil {
+		t.Errorf("objects in excluded map counted: %+v", ts)
+	}
+	if sizes.Total != sizeofWord {
+		t.Errorf("total %d, want %d", sizes.Total, sizeofWord)
+	}
+
+	// Objects reported by traversers.
+	nodes := make([]uintptrNode, 2)
+	nodes[0].next = uintptr(unsafe.Pointer(&nodes[1]))
+	l := &uintptrList{head: uintptr(unsafe.Pointer(&nodes[0]))}
+	RegisterTraverser(reflect.TypeOf(uintptrList{}), func(v reflect.Value, visit func(reflect.Value, uintptr)) {
+		for i := range nodes {
+			visit(reflect.ValueOf(&nodes[i]).Elem(), uintptr(unsafe.Pointer(&nodes[i])))
+		}
+	})
+	defer RegisterTraverser(reflect.TypeOf(uintptrList{}), nil)
+	start := uintptr(unsafe.Pointer(&nodes[1]))
+	sizes = ScanWithOptions(l, Options{ExcludeRanges: []AddressRange{{start, start + 1}}})
+	if ts := sizes.ByType[reflect.TypeOf(uintptrNode{})]; ts == nil || ts.Count != 1 {
+		t.Errorf("wrong node stats: %+v", ts)
+	}
+	runtime.KeepAlive(nodes)
+}
+
+func TestFocusTypes(t *testing.T) {
+	v := &struct {
+		a  *structslice
+		b  *structslice
+		p  *structptr
+		ss []string
+	}{
+		a:  &structslice{s: make([]uint32, 4)},
+		b:  &structslice{s: make([]uint32, 2)},
+		p:  &structptr{cld: &structptr{}},
+		ss: []string{"a", "b"},
+	}
+	tSlice := reflect.TypeOf(structslice{})
+	sizes := ScanWithOptions(v, Options{FocusTypes: []reflect.Type{tSlice}, Ownership: true})
+	if len(sizes.ByType) != 1 {
+		t.Fatalf("result has %d types, want 1", len(sizes.ByType))
+	}
+	ts := sizes.ByType[tSlice]
+	want := Scan(v).ByType[tSlice]
+	if !reflect.DeepEqual(ts, want) {
+		t.Errorf("wrong stats for focused type: %+v, want %+v", ts, want)
+	}
+	if sizes.Total != ts.Total {
+		t.Errorf("total %d, want %d", sizes.Total, ts.Total)
+	}
+	var kinds uintptr
+	for _, n := range sizes.ByKind() {
+		kinds += n
+	}
+	if kinds != sizes.Total {
+		t.Errorf("kinds add up to %d, want %d", kinds, sizes.Total)
+	}
+	parent := reflect.TypeOf(*v)
+	if got := sizes.Ownership[parent][tSlice]; got != ts.Total {
+		t.Errorf("ownership of focused type %d, want %d", got, ts.Total)
+	}
+}
+
+func TestSliceHistograms(t *testing.T) {
+	v := &struct {
+		a, b, c []uint32
+		d       []uint32
+		s       []string
+	}{
+		a: make([]uint32, 3, 4),
+		b: make([]uint32, 1, 4),
+		c: make([]uint32, 0, 100),
+		s: make([]string, 2),
+	}
+	sizes := ScanWithOptions(v, Options{SliceHistograms: true})
+	u32 := sizes.Slices[reflect.TypeOf(uint32(0))]
+	if u32 == nil {
+		t.Fatal("no stats for []uint32")
+	}
+	want := SliceStats{
+		Count:  3,
+		Len:    4,
+		Cap:    108,
+		Unused: (1 + 3 + 100) * 4,
+		Histogram: map[SliceShape]uintptr{
+			{Len: 4, Cap: 4}:   1,
+			{Len: 1, Cap: 4}:   1,
+			{Len: 0, Cap: 128}: 1,
+		},
+	}
+	if !reflect.DeepEqual(*u32, want) {
+		t.Errorf("wrong []uint32 stats:\ngot  %+v\nwant %+v", *u32, want)
+	}
+	if str := sizes.Slices[reflect.TypeOf("")]; str == nil || str.Unused != 0 {
+		t.Errorf("wrong []string stats: %+v", str)
+	}
+	if report := sizes.SliceReport(); !strings.HasPrefix(report, "[]uint32  count 3") {
+		t.Errorf("wrong report:\n%s", report)
+	}
+}
+
+func TestTryScan(t *testing.T) {
+	v := &struct16{}
+	sizes, err := TryScan(v, Options{})
+	if !runtimefunc.Supported {
+		if err != ErrUnsupportedGoVersion {
+			t.Fatalf("got error %v, want ErrUnsupportedGoVersion", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes.Total != 16 {
+		t.Fatalf("total %d, want 16", sizes.Total)
+	}
+}
+
+func TestFilterTop(t *testing.T) {
+	v := &struct {
+		a *struct16
+		b *structptr
+		s []uint32
+	}{&struct16{}, &structptr{cld: &structptr{}}, make([]uint32, 64)}
+	sizes := ScanWithOptions(v, Options{Ownership: true})
+	tS16, tPtr := reflect.TypeOf(struct16{}), reflect.TypeOf(structptr{})
+
+	top := sizes.Top(2)
+	if len(top.ByType) != 2 || top.ByType[tS16] != nil {
+		t.Fatalf("wrong types in Top(2): %v", top.ByType)
+	}
+	if top.Total != sizes.Total {
+		t.Errorf("total changed: %d != %d", top.Total, sizes.Total)
+	}
+	if _, ok := top.Ownership[reflect.TypeOf(*v)][tS16]; ok {
+		t.Error("ownership entry of removed type retained")
+	}
+
+	f := sizes.Filter(func(typ reflect.Type, ts *TypeSize) bool {
+		ts.Count = 0
+		return typ == tPtr
+	})
+	if len(f.ByType) != 1 || f.ByType[tPtr] == nil {
+		t.Fatalf("wrong types in Filter result: %v", f.ByType)
+	}
+	if sizes.ByType[tPtr].Count != 2 {
+		t.Error("Filter modified the original result")
+	}
+}
+
+// TestFilterStats checks that Filter keeps the statistics keyed by composite types
+// when their element types are kept.
+func TestFilterStats(t *testing.T) {
+	x := 1
+	v := &struct {
+		m  map[int]*struct16
+		c  chan *structptr
+		p  *structptr
+		s  []*struct16
+		fn func() int
+	}{
+		m:  map[int]*struct16{1: {}},
+		c:  make(chan *structptr, 1),
+		p:  &structptr{},
+		s:  []*struct16{{}},
+		fn: func() int { return x },
+	}
+	v.c <- v.p
+	sizes := ScanWithOptions(v, Options{SliceHistograms: true, MapStats: true, BackingArrays: true, ChanStats: true})
+	tV, tS16, tPtr := reflect.TypeOf(*v), reflect.TypeOf(struct16{}), reflect.TypeOf(structptr{})
+	stats := []struct {
+		name string
+		len  func(Sizes) int
+		kept reflect.Type // the type keeping the entry
+	}{
+		{"Slices", func(s Sizes) int { return len(s.Slices) }, tS16},
+		{"Maps", func(s Sizes) int { return len(s.Maps) }, tS16},
+		{"BackingArrays", func(s Sizes) int { return len(s.BackingArrays) }, tS16},
+		{"Unscannable", func(s Sizes) int { return len(s.Unscannable) }, tV},
+		{"Chans", func(s Sizes) int { return len(s.Chans) }, tPtr},
+	}
+	for _, st := range stats {
+		n := st.len(sizes)
+		if n == 0 && st.name == "Maps" {
+			continue // map layout unknown
+		}
+		if n != 1 {
+			t.Errorf("%s: %d entries, want 1", st.name, n)
+			continue
+		}
+		for _, typ := range []reflect.Type{tV, tS16, tPtr} {
+			f := sizes.Filter(func(ft reflect.Type, _ *TypeSize) bool { return ft == typ })
+			want := 0
+			if typ == st.kept {
+				want = 1
+			}
+			if got := st.len(f); got != want {
+				t.Errorf("%s: %d entries kept for %v, want %d", st.name, got, typ, want)
+			}
+		}
+		if st.len(sizes) != n {
+			t.Errorf("%s: Filter modified the original result", st.name)
+		}
+	}
+}
+
+func TestOnValue(t *testing.T) {
+	type secret struct {
+		key []byte
+	}
+	v := &struct {
+		pub   []uint32
+		creds map[string]*secret
+		arr   [2]*struct16
+	}{
+		pub:   make([]uint32, 4),
+		creds: map[string]*secret{"admin": {key: make([]byte, 1000)}},
+		arr:   [2]*struct16{nil, {}},
+	}
+	var paths []string
+	sizes := ScanWithOptions(v, Options{OnValue: func(path string, v reflect.Value) Action {
+		paths = append(paths, path)
+		if v.Type() == reflect.TypeOf(secret{}) {
+			return Skip
+		}
+		return Continue
+	}})
+	if ts := sizes.ByType[reflect.TypeOf(secret{})]; ts == nil || ts.Total != sizeofSlice {
+		t.Errorf("secret not counted shallowly: %+v", ts)
+	}
+	if sizes.ByType[reflect.TypeOf(secret{})].Referenced != 0 {
+		t.Error("skipped value referenced memory was counted")
+	}
+	for _, want := range []string{"", ".pub", ".creds", ".creds[admin]", ".arr[1]"} {
+		if !containsString(paths, want) {
+			t.Errorf("path %q not reported, have %q", want, paths)
+		}
+	}
+	if containsString(paths, ".creds[admin].key") {
+		t.Error("skipped value was traversed")
+	}
+}
+
+// hookKey is a map key whose String method must not be called by the scan.
+type hookKey int
+
+var hookKeyCalled bool
+
+func (hookKey) String() string {
+	hookKeyCalled = true
+	return "called"
+}
+
+func TestOnValueKeys(t *testing.T) {
+	key, val := new(struct16), new(struct16)
+	v := &struct {
+		named map[hookKey]*struct16
+		float map[float64]*struct16
+		cplx  map[complex128]*struct16
+		iface map[interface{}]*struct16
+		strct map[struct16]*struct16
+		ptr   map[*struct16]*struct16
+	}{
+		named: map[hookKey]*struct16{1: val},
+		float: map[float64]*struct16{1.5: val},
+		cplx:  map[complex128]*struct16{complex(1, -2): val},
+		iface: map[interface{}]*struct16{hookKey(2): val, "s": val},
+		strct: map[struct16]*struct16{{}: val},
+		ptr:   map[*struct16]*struct16{key: val},
+	}
+	var paths []string
+	ScanWithOptions(v, Options{OnValue: func(path string, v reflect.Value) Action {
+		paths = append(paths, path)
+		return Continue
+	}})
+	if hookKeyCalled {
+		t.Error("String method of key was called")
+	}
+	want := []string{
+		".named[1]", ".float[1.5]", ".cplx[(1-2i)]", ".iface[2]", ".iface[s]", ".strct[memsize.struct16]",
+		fmt.Sprintf(".ptr[%p]", key),
+	}
+	for _, w := range want {
+		if !containsString(paths, w) {
+			t.Errorf("path %q not reported, have %q", w, paths)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSlowScan(t *testing.T) {
+	type node struct {
+		next *node
+	}
+	root := &struct{ list *node }{}
+	for i := 0; i < 5000; i++ {
+		root.list = &node{next: root.list}
+	}
+	sizes := ScanWithOptions(root, Options{SlowScan: time.Nanosecond})
+	path := sizes.SlowScanPath
+	if len(path) < 2 {
+		t.Fatalf("path not captured: %v", path)
+	}
+	if path[0] != reflect.TypeOf(*root) {
+		t.Errorf("path starts with %v", path[0])
+	}
+	for _, typ := range path[1:] {
+		if typ != reflect.TypeOf(node{}) {
+			t.Fatalf("unexpected type %v in path", typ)
+		}
+	}
+	if sizes := Scan(root); sizes.SlowScanPath != nil {
+		t.Error("path captured without Options.SlowScan")
+	}
+}
+
+func TestMaxPausePerSlice(t *testing.T) {
+	type node struct {
+		next *node
+		m    map[int]*node
+	}
+	root := &struct{ list *node }{}
+	for i := 0; i < 5000; i++ {
+		root.list = &node{next: root.list}
+	}
+	root.list.m = map[int]*node{1: {}, 2: {}}
+
+	want := Scan(root)
+	sizes := ScanWithOptions(root, Options{MaxPausePerSlice: time.Nanosecond})
+	if sizes.Total != want.Total {
+		t.Errorf("total %d, want %d", sizes.Total, want.Total)
+	}
+	if sizes.Pauses < 2 {
+		t.Errorf("world stopped %d times, want more than once", sizes.Pauses)
+	}
+	if sizes.MaxPause <= 0 {
+		t.Errorf("max pause not recorded")
+	}
+	if want.Pauses != 0 || want.MaxPause != 0 {
+		t.Errorf("pauses recorded without Options.MaxPausePerSlice")
+	}
+}
+
+// TestMaxPausePerSliceConcurrent checks that scans with pause slicing observe
+// whole headers of values modified while the world is started. Run it with -race.
+func TestMaxPausePerSliceConcurrent(t *testing.T) {
+	type node struct {
+		next *node
+		b    []byte
+		s    string
+	}
+	const maxLen = 6
+	root := &struct{ list *node }{}
+	for i := 0; i < 2000; i++ {
+		root.list = &node{next: root.list}
+	}
+	empty := Scan(root).Total
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for n := root.list; n != nil; n = n.next {
+				n.b = make([]byte, i%(maxLen+1))
+				n.s = string(n.b)
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	for i := 0; i < 5; i++ {
+		sizes := ScanWithOptions(root, Options{MaxPausePerSlice: time.Nanosecond})
+		if max := empty + 2000*2*maxLen; sizes.Total < empty || sizes.Total > max {
+			t.Fatalf("scan %d: total %d out of range [%d, %d]", i, sizes.Total, empty, max)
+		}
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	shared := &structptr{}
+	v := &struct {
+		list []*structptr
+		s    string
+		m    map[int]int
+		nilm map[int]int
+		p    *structptr
+	}{
+		list: []*structptr{{cld: shared}, {cld: shared}, {}},
+		s:    "x",
+		m:    map[int]int{},
+		p:    shared,
+	}
+	sizes := Scan(v)
+	// list, s, m and p. The pointers in the list are followed from the slice's
+	// backing array, which belongs to the struct.
+	if ts := sizes.ByType[reflect.TypeOf(*v)]; ts.Edges != 4+3 {
+		t.Errorf("struct has %d edges, want %d", ts.Edges, 4+3)
+	}
+	ts := sizes.ByType[reflect.TypeOf(structptr{})]
+	if ts.Count != 4 || ts.Edges != 2 {
+		t.Fatalf("structptr has count %d and %d edges, want 4 and 2", ts.Count, ts.Edges)
+	}
+	if f := ts.FanOut(); f != 0.5 {
+		t.Errorf("structptr fan-out %v, want 0.5", f)
+	}
+}
+
+func TestUnscannable(t *testing.T) {
+	buf := make([]byte, 1000)
+	shared := func() int { return len(buf) }
+	v := &struct {
+		closure, again, method, plain, nilf func() int
+	}{
+		closure: shared,
+		again:   shared,
+		method:  new(bytes.Buffer).Len,
+		plain:   runtime.NumGoroutine,
+	}
+	sizes := Scan(v)
+	bs := sizes.Unscannable[reflect.TypeOf(*v)]
+	if bs == nil || len(sizes.Unscannable) != 1 {
+		t.Fatalf("wrong unscannable types: %v", sizes.Unscannable)
+	}
+	if !runtimefunc.HaveAddrClass {
+		if bs.Count != 4 || bs.Bytes != 0 {
+			t.Errorf("wrong stats %+v, want 4 values without bytes", *bs)
+		}
+		return
+	}
+	if bs.Count != 3 {
+		t.Errorf("count %d, want 3", bs.Count)
+	}
+	min, max := 2*sizeofWord, uintptr(64)
+	if runtimefunc.HavePointerWords {
+		// The captured buffer and the bytes.Buffer of the method value are included.
+		min, max = uintptr(len(buf))+unsafe.Sizeof(bytes.Buffer{}), 1200
+	}
+	if runtimefunc.HaveObjectSize && (bs.Bytes < min || bs.Bytes > max) {
+		t.Errorf("wrong closure bytes %d, want %d-%d", bs.Bytes, min, max)
+	}
+}
+
+// bigKey and bigValue are stored outside of the map slots because they are
+// larger than 128 bytes.
+type (
+	bigKey struct {
+		name string
+		pad  [16]uint64
+	}
+	bigValue struct {
+		tags []string
+		pad  [16]uint64
+	}
+)
+
+func TestMapSharedKeys(t *testing.T) {
+	names := []string{strings.Repeat("a", 100), strings.Repeat("b", 200)}
+	m := make(map[string]int)
+	for i, name := range names {
+		m[name] = i
+	}
+	v := &struct {
+		names []string
+		m     map[string]int
+	}{names, m}
+	sizes := Scan(v)
+	// The string data is shared between the slice and the map keys.
+	if got := sizes.ByKind()[reflect.String]; got != 300 {
+		t.Errorf("string data %d, want 300", got)
+	}
+	if want := Scan(&v.names).Total + Scan(&v.m).Total - 300; sizes.Total != want {
+		t.Errorf("total %d, want %d", sizes.Total, want)
+	}
+
+	// Large keys and values are referenced by the slots.
+	tags := []string{"x", "y"}
+	big := &struct {
+		tags []string
+		name string
+		m    map[bigKey]bigValue
+	}{tags, names[0], map[bigKey]bigValue{{name: names[0]}: {tags: tags}}}
+	sizes = Scan(big)
+	if got := sizes.ByKind()[reflect.String]; got != 102 {
+		t.Errorf("big entries: string data %d, want 102", got)
+	}
+	if got := sizes.ByKind()[reflect.Slice]; got != 2*sizeofString {
+		t.Errorf("big entries: slice data %d, want %d", got, 2*sizeofString)
+	}
+}
+
+func TestMapMultipleReferences(t *testing.T) {
+	ints := map[int]int{1: 1, 2: 2}
+	ptrs := map[string]*int{"a": new(int)}
+	v := &struct {
+		a, b map[int]int
+		c, d map[string]*int
+	}{ints, ints, ptrs, ptrs}
+	want := 4*sizeofMap + Scan(&ints).Total - sizeofMap + Scan(&ptrs).Total - sizeofMap
+	if sizes := Scan(v); sizes.Total != want {
+		t.Errorf("total %d, want %d", sizes.Total, want)
+	}
+}
+
+func TestTypeAt(t *testing.T) {
+	v := &struct {
+		p    *structptr
+		data []uint32
+		s    string
+	}{p: &structptr{}, data: make([]uint32, 4), s: strings.Repeat("x", 10)}
+	sizes := ScanWithOptions(v, Options{AddressIndex: true})
+	tests := []struct {
+		addr uintptr
+		want reflect.Type
+	}{
+		{uintptr(unsafe.Pointer(v)), reflect.TypeOf(*v)},
+		{uintptr(unsafe.Pointer(&v.s)), reflect.TypeOf(*v)},
+		{uintptr(unsafe.Pointer(v.p)), reflect.TypeOf(structptr{})},
+		{uintptr(unsafe.Pointer(&v.p.cld)), reflect.TypeOf(structptr{})},
+		{uintptr(unsafe.Pointer(&v.data[3])), reflect.TypeOf(v.data)},
+		{(*reflect.StringHeader)(unsafe.Pointer(&v.s)).Data + 9, reflect.TypeOf("")},
+	}
+	for _, test := range tests {
+		if typ, ok := sizes.TypeAt(test.addr); !ok || typ != test.want {
+			t.Errorf("TypeAt(%#x) = %v, %v; want %v", test.addr, typ, ok, test.want)
+		}
+	}
+	if typ, ok := sizes.TypeAt(uintptr(unsafe.Pointer(&sizes))); ok {
+		t.Errorf("TypeAt of address not found by the scan = %v", typ)
+	}
+	if _, ok := Scan(v).TypeAt(uintptr(unsafe.Pointer(v))); ok {
+		t.Error("TypeAt found address without Options.AddressIndex")
+	}
+}
+
+func TestAddrIndexOverlap(t *testing.T) {
+	var (
+		ix     addrIndex
+		tOuter = reflect.TypeOf(struct16{})
+		tInner = reflect.TypeOf(uint64(0))
+		tNext  = reflect.TypeOf("")
+	)
+	ix.add(0x1008, 8, tInner)
+	ix.add(0x1000, 16, tOuter)
+	ix.add(0x1010, 16, tNext)
+	ix.finish()
+	for addr, want := range map[uintptr]reflect.Type{0x1000: tOuter, 0x100c: tInner, 0x1010: tNext, 0x101f: tNext, 0x1020: nil, 0xfff: nil} {
+		if typ, _ := ix.lookup(addr); typ != want {
+			t.Errorf("lookup(%#x) = %v, want %v", addr, typ, want)
+		}
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	v := &struct {
+		names []string
+		m     map[int]int
+		n     int
+	}{names: make([]string, 10), m: map[int]int{}}
+	for i := range v.names {
+		v.names[i] = "x"
+	}
+	sizes := ScanWithOptions(v, Options{Headers: true})
+	ts := sizes.ByType[reflect.TypeOf(*v)]
+	if want := sizeofSlice + sizeofMap + 10*sizeofString; ts.Headers != want {
+		t.Errorf("headers %d, want %d", ts.Headers, want)
+	}
+	// The payload is the int field and the string data, which is shared by all
+	// strings.
+	if want := sizeofWord + 1; ts.Payload() != want {
+		t.Errorf("payload %d, want %d", ts.Payload(), want)
+	}
+	if sizes := Scan(v); sizes.ByType[reflect.TypeOf(*v)].Headers != 0 {
+		t.Error("headers reported without Options.Headers")
+	}
+}
+
+func TestScanValue(t *testing.T) {
+	s := make([]uint32, 8)
+	if sizes := ScanValue(s); sizes.Total != sizeofSlice+32 {
+		t.Errorf("slice total %d, want %d", sizes.Total, sizeofSlice+32)
+	}
+	var iface interface{} = struct16{}
+	if sizes := ScanValue(iface); sizes.Total != 16 {
+		t.Errorf("struct total %d, want 16", sizes.Total)
+	}
+	if sizes := ScanValue(&struct16{}); sizes.Total != sizeofWord+16 {
+		t.Errorf("pointer total %d, want %d", sizes.Total, sizeofWord+16)
+	}
+	if sizes := ScanValue(nil); sizes.Total != 0 {
+		t.Errorf("nil total %d", sizes.Total)
+	}
+}
+
+func TestMapStats(t *testing.T) {
+	v := &struct {
+		small map[uint64]uint64
+		large map[uint64]uint64
+		nilm  map[string]int
+	}{small: map[uint64]uint64{1: 1}, large: make(map[uint64]uint64)}
+	for i := uint64(0); i < 1000; i++ {
+		v.large[i] = i
+	}
+	sizes := ScanWithOptions(v, Options{MapStats: true})
+	if !runtimefunc.HaveMapInfo {
+		if len(sizes.Maps) != 0 {
+			t.Fatalf("map stats recorded without layout information")
+		}
+		t.Skip("map layout not available")
+	}
+	ms := sizes.Maps[reflect.TypeOf(v.large)]
+	if ms == nil || ms.Count != 2 || ms.Entries != 1001 {
+		t.Fatalf("wrong map stats: %+v", ms)
+	}
+	if lf := ms.LoadFactor(); lf < 0.3 || lf > 1 {
+		t.Errorf("implausible load factor %f", lf)
+	}
+	// At least 16 bytes per entry are needed.
+	if ms.Bytes < 1001*16 {
+		t.Errorf("table size %d too small", ms.Bytes)
+	}
+	if sizes.Maps[reflect.TypeOf(v.nilm)] != nil {
+		t.Error("stats recorded for nil map")
+	}
+	if !strings.HasPrefix(sizes.MapReport(), "map[uint64]uint64  maps 2  entries 1001") {
+		t.Errorf("wrong report:\n%s", sizes.MapReport())
+	}
+}
+
+func TestBackingArrays(t *testing.T) {
+	buf := make([]uint32, 16)
+	v := &struct {
+		a, b, c []uint32
+		d       [][]byte
+		s1, s2  string
+		s3      string
+	}{
+		a:  buf,
+		b:  buf[4:8],
+		c:  make([]uint32, 2),
+		d:  [][]byte{make([]byte, 4), nil, {}},
+		s1: strings.Repeat("x", 10),
+	}
+	v.s2 = v.s1[2:]
+	v.s3 = strings.Repeat("y", 3)
+	sizes := ScanWithOptions(v, Options{BackingArrays: true})
+	want := map[reflect.Type]uintptr{
+		reflect.TypeOf(uint32(0)): 2,
+		reflect.TypeOf([]byte{}):  1,
+		reflect.TypeOf(byte(0)):   1,
+	}
+	if !reflect.DeepEqual(sizes.BackingArrays, want) {
+		t.Errorf("wrong backing array counts: %v", sizes.BackingArrays)
+	}
+	if sizes.StringBlocks != 2 {
+		t.Errorf("wrong string block count %d, want 2", sizes.StringBlocks)
+	}
+}
+
+// uintptrList is a linked list whose nodes are referenced through uintptr, so
+// they are invisible to reflection.
+type uintptrList struct {
+	head uintptr
+}
+
+type uintptrNode struct {
+	next uintptr
+	data [32]byte
+}
+
+func TestRegisterTraverser(t *testing.T) {
+	nodes := make([]uintptrNode, 5)
+	l := &uintptrList{head: uintptr(unsafe.Pointer(&nodes[0]))}
+	for i := range nodes[:len(nodes)-1] {
+		nodes[i].next = uintptr(unsafe.Pointer(&nodes[i+1]))
+	}
+	nodeType := reflect.TypeOf(uintptrNode{})
+	// The traverser resolves addresses through the backing slice, standing in
+	// for an allocator which can look up its objects.
+	base := uintptr(unsafe.Pointer(&nodes[0]))
+	RegisterTraverser(reflect.TypeOf(uintptrList{}), func(v reflect.Value, visit func(reflect.Value, uintptr)) {
+		for p := uintptr(v.Field(0).Uint()); p != 0; {
+			n := &nodes[(p-base)/nodeType.Size()]
+			visit(reflect.ValueOf(n).Elem(), p)
+			p = n.next
+		}
+	})
+	defer RegisterTraverser(reflect.TypeOf(uintptrList{}), nil)
+
+	sizes := Scan(l)
+	if ts := sizes.ByType[nodeType]; ts == nil || ts.Count != 5 || ts.Total != 5*nodeType.Size() {
+		t.Fatalf("wrong node stats: %+v", ts)
+	}
+	if want := unsafe.Sizeof(*l) + 5*nodeType.Size(); sizes.Total != want {
+		t.Fatalf("total %d, want %d", sizes.Total, want)
+	}
+	runtime.KeepAlive(nodes)
+}
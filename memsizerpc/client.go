@@ -0,0 +1,172 @@
+package memsizerpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Client is a connection to a remote scan service.
+type Client struct {
+	hc   *http.Client
+	base string
+}
+
+// NewClient creates a client for the service at the given base URL, e.g.
+// "https://host:6061". The HTTP client must support HTTP/2 for the URL.
+func NewClient(hc *http.Client, baseURL string) *Client {
+	return &Client{hc: hc, base: baseURL}
+}
+
+// Close closes the idle connections of the client.
+func (c *Client) Close() error {
+	c.hc.CloseIdleConnections()
+	return nil
+}
+
+// Roots returns the names of the roots registered on the server.
+func (c *Client) Roots() ([]string, error) {
+	resp, err := c.call(context.Background(), "ListRoots", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	msg, err := resp.recv()
+	if err != nil {
+		return nil, err
+	}
+	return decodeRoots(msg)
+}
+
+// Scan scans a root on the server.
+func (c *Client) Scan(root string) (*ScanResult, error) {
+	resp, err := c.call(context.Background(), "Scan", appendStringField(nil, 1, root))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	msg, err := resp.recv()
+	if err != nil {
+		return nil, err
+	}
+	return decodeScanResult(msg)
+}
+
+// ScanStream is the result stream of Watch.
+type ScanStream struct {
+	resp   *call
+	cancel context.CancelFunc
+}
+
+// Watch starts periodic scans of root on the server, once per interval. The first
+// scan happens immediately. The interval must be at least MinWatchInterval. The
+// stream ends when ctx is canceled or Close is called.
+func (c *Client) Watch(ctx context.Context, root string, interval time.Duration) (*ScanStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	resp, err := c.call(ctx, "Watch", encodeWatchRequest(root, interval))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &ScanStream{resp, cancel}, nil
+}
+
+// Recv returns the next result. It returns io.EOF when the server ends the stream
+// without error.
+func (s *ScanStream) Recv() (*ScanResult, error) {
+	msg, err := s.resp.recv()
+	if err != nil {
+		return nil, err
+	}
+	return decodeScanResult(msg)
+}
+
+// Close ends the stream.
+func (s *ScanStream) Close() error {
+	s.cancel()
+	return s.resp.Body.Close()
+}
+
+// Stream scans root repeatedly, once per interval, and calls fn with each result.
+// The first scan happens immediately. Stream returns when fn or the stream returns
+// an error.
+func (c *Client) Stream(root string, interval time.Duration, fn func(*ScanResult) error) error {
+	s, err := c.Watch(context.Background(), root, interval)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	for {
+		res, err := s.Recv()
+		if err != nil {
+			return err
+		}
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+}
+
+// call is a gRPC call in progress.
+type call struct {
+	*http.Response
+}
+
+// call starts a call of the given method.
+func (c *Client) call(ctx context.Context, method string, req []byte) (*call, error) {
+	var body bytes.Buffer
+	writeMessage(&body, req)
+	hreq, err := http.NewRequest(http.MethodPost, c.base+"/"+ServiceName+"/"+method, &body)
+	if err != nil {
+		return nil, err
+	}
+	hreq = hreq.WithContext(ctx)
+	hreq.Header.Set("Content-Type", "application/grpc")
+	hreq.Header.Set("TE", "trailers")
+	resp, err := c.hc.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &Error{CodeUnavailable, "HTTP status " + resp.Status}
+	}
+	// Errors may be reported in the header when there is no response message.
+	if err := statusError(resp.Header); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &call{resp}, nil
+}
+
+// recv reads the next response message. At the end of the response, it returns
+// the status of the call or io.EOF.
+func (c *call) recv() ([]byte, error) {
+	msg, err := readMessage(c.Body)
+	if err != io.EOF {
+		return msg, err
+	}
+	if err := statusError(c.Trailer); err != nil {
+		return nil, err
+	}
+	if c.Trailer.Get("Grpc-Status") == "" {
+		return nil, errors.New("memsizerpc: response without status")
+	}
+	return nil, io.EOF
+}
+
+func statusError(h http.Header) error {
+	s := h.Get("Grpc-Status")
+	if s == "" || s == "0" {
+		return nil
+	}
+	code, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return &Error{CodeUnknown, "invalid status " + s}
+	}
+	return &Error{Code(code), decodeGRPCMessage(h.Get("Grpc-Message"))}
+}
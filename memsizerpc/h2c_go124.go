@@ -0,0 +1,38 @@
+//go:build go1.24
+// +build go1.24
+
+package memsizerpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// Serve accepts connections on l and serves the scan service using unencrypted
+// HTTP/2. It returns when l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: s, Protocols: &protocols}
+	return srv.Serve(l)
+}
+
+// Dial creates a client for a scan service served by Server.Serve. The connection
+// is established by the first call.
+func Dial(network, addr string) (*Client, error) {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	var dialer net.Dialer
+	tr := &http.Transport{
+		Protocols: &protocols,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	host := addr
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		host = "localhost" // e.g. unix socket path
+	}
+	return NewClient(&http.Client{Transport: tr}, "http://"+host), nil
+}
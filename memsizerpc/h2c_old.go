@@ -0,0 +1,23 @@
+//go:build !go1.24
+// +build !go1.24
+
+package memsizerpc
+
+import (
+	"errors"
+	"net"
+)
+
+var errNoH2C = errors.New("memsizerpc: unencrypted HTTP/2 requires Go 1.24")
+
+// Serve is not supported before Go 1.24. Mount the Server on an HTTP/2 server
+// using TLS instead.
+func (s *Server) Serve(l net.Listener) error {
+	return errNoH2C
+}
+
+// Dial is not supported before Go 1.24. Use NewClient with an HTTP/2 client
+// using TLS instead.
+func Dial(network, addr string) (*Client, error) {
+	return nil, errNoH2C
+}
@@ -0,0 +1,67 @@
+// Package memsizerpc provides remote access to memsize scans using gRPC.
+//
+// The service is defined in memsize.proto. It is implemented on top of net/http
+// and doesn't depend on the gRPC libraries. The server side exposes the roots of a
+// memsize.RootSet:
+//
+//	var roots memsize.RootSet
+//	roots.Add("myObject", &myObject)
+//	srv := memsizerpc.NewServer(&roots)
+//	go srv.Serve(listener)
+//
+// Programs can then list roots and scan them remotely:
+//
+//	c, err := memsizerpc.Dial("tcp", "127.0.0.1:6061")
+//	res, err := c.Scan("myObject")
+//
+// Serve and Dial use unencrypted HTTP/2, which requires Go 1.24. With older Go
+// releases, the Server can be mounted on an HTTP/2 server using TLS and the client
+// can be created with NewClient.
+package memsizerpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fjl/memsize"
+)
+
+// ServiceName is the full name of the gRPC service.
+const ServiceName = "memsize.Memsize"
+
+// MinWatchInterval is the shortest interval accepted by Watch.
+const MinWatchInterval = 100 * time.Millisecond
+
+// ScanResult is the outcome of a remote scan.
+type ScanResult struct {
+	Root     string
+	Date     time.Time
+	Duration time.Duration
+	Sizes    memsize.Snapshot
+}
+
+// Code is a gRPC status code.
+type Code uint32
+
+// Status codes used by the service.
+const (
+	CodeOK                Code = 0
+	CodeCanceled          Code = 1
+	CodeUnknown           Code = 2
+	CodeInvalidArgument   Code = 3
+	CodeNotFound          Code = 5
+	CodeResourceExhausted Code = 8
+	CodeUnimplemented     Code = 12
+	CodeInternal          Code = 13
+	CodeUnavailable       Code = 14
+)
+
+// Error is a non-OK status returned by a call.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("memsizerpc: %s (code %d)", e.Message, e.Code)
+}
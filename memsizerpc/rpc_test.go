@@ -0,0 +1,152 @@
+package memsizerpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fjl/memsize"
+)
+
+func TestWireEncoding(t *testing.T) {
+	// Expected encodings as produced by protoc-generated code.
+	if got, want := appendStringField(nil, 1, "ab"), []byte{0x0a, 0x02, 'a', 'b'}; !bytes.Equal(got, want) {
+		t.Errorf("ScanRequest encoding %x, want %x", got, want)
+	}
+	if got, want := encodeWatchRequest("a", 300), []byte{0x0a, 0x01, 'a', 0x10, 0xac, 0x02}; !bytes.Equal(got, want) {
+		t.Errorf("WatchRequest encoding %x, want %x", got, want)
+	}
+	root, interval, err := decodeWatchRequest([]byte{0x0a, 0x01, 'a', 0x10, 0xac, 0x02})
+	if err != nil || root != "a" || interval != 300 {
+		t.Errorf("WatchRequest decoded to %q %v %v", root, interval, err)
+	}
+	roots, err := decodeRoots(encodeRoots([]string{"x", "y"}))
+	if err != nil || !reflect.DeepEqual(roots, []string{"x", "y"}) {
+		t.Errorf("roots decoded to %q %v", roots, err)
+	}
+	// Unknown fields are skipped.
+	if s, err := parseString([]byte{0x15, 1, 2, 3, 4, 0x0a, 0x01, 'z'}, 1); err != nil || s != "z" {
+		t.Errorf("parseString returned %q %v", s, err)
+	}
+	if _, err := parseString([]byte{0x0a, 0x05, 'a'}, 1); err == nil {
+		t.Error("no error for truncated message")
+	}
+	if got, want := encodeGRPCMessage("100% \n"), "100%25 %0A"; got != want {
+		t.Errorf("grpc-message encoding %q, want %q", got, want)
+	}
+	if got := decodeGRPCMessage("100%25 %0A"); got != "100% \n" {
+		t.Errorf("grpc-message decoded to %q", got)
+	}
+}
+
+type testValue struct {
+	s []byte
+	m map[int]string
+}
+
+func startServer(t *testing.T) (*Client, *testValue) {
+	v := &testValue{s: make([]byte, 100), m: map[int]string{1: "one"}}
+	var roots memsize.RootSet
+	roots.Add("v", v)
+	roots.Add("w", &struct{ x int }{})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go NewServer(&roots).Serve(l)
+	c, err := Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		l.Close()
+	})
+	return c, v
+}
+
+func TestRoundTrip(t *testing.T) {
+	c, v := startServer(t)
+
+	roots, err := c.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roots, []string{"v", "w"}) {
+		t.Errorf("wrong roots %q", roots)
+	}
+	res, err := c.Scan("v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := memsize.Scan(v).Snapshot()
+	if res.Root != "v" || res.Sizes.Total != want.Total || !reflect.DeepEqual(res.Sizes.ByType, want.ByType) {
+		t.Errorf("wrong result %+v, want %+v", res, want)
+	}
+	if time.Since(res.Date) > time.Minute || res.Duration <= 0 {
+		t.Errorf("wrong date %v or duration %v", res.Date, res.Duration)
+	}
+
+	_, err = c.Scan("unknown")
+	if e, ok := err.(*Error); !ok || e.Code != CodeNotFound || e.Message != "unknown root unknown" {
+		t.Errorf("wrong error for unknown root: %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c, _ := startServer(t)
+
+	s, err := c.Watch(context.Background(), "v", MinWatchInterval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		res, err := s.Recv()
+		if err != nil {
+			t.Fatalf("result %d: %v", i, err)
+		}
+		if res.Root != "v" || res.Sizes.Total == 0 {
+			t.Errorf("result %d: %+v", i, res)
+		}
+	}
+	s.Close()
+
+	// Errors are reported to Stream.
+	n := 0
+	errStop := errors.New("stop")
+	err = c.Stream("w", MinWatchInterval, func(*ScanResult) error {
+		if n++; n == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("Stream returned %v, want %v", err, errStop)
+	}
+	err = c.Stream("w", time.Millisecond, func(*ScanResult) error { return nil })
+	if e, ok := err.(*Error); !ok || e.Code != CodeInvalidArgument {
+		t.Errorf("wrong error for short interval: %v", err)
+	}
+}
+
+func TestRequireHTTP2(t *testing.T) {
+	srv := httptest.NewServer(NewServer(new(memsize.RootSet)))
+	defer srv.Close()
+
+	var body bytes.Buffer
+	writeMessage(&body, nil)
+	resp, err := http.Post(srv.URL+"/"+ServiceName+"/ListRoots", "application/grpc", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Errorf("wrong status %s", resp.Status)
+	}
+}
@@ -0,0 +1,132 @@
+package memsizerpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fjl/memsize"
+)
+
+// Server implements the scan service for the roots of a RootSet. It is an
+// http.Handler serving gRPC calls, which must be made over HTTP/2.
+type Server struct {
+	roots *memsize.RootSet
+	mu    sync.Mutex // serializes scans
+}
+
+// NewServer creates the scan service for the given roots.
+func NewServer(roots *memsize.RootSet) *Server {
+	return &Server{roots: roots}
+}
+
+// ServeHTTP handles a gRPC call.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "memsizerpc: not a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.ProtoMajor != 2 {
+		http.Error(w, "memsizerpc: gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	err := s.call(w, r)
+	status := &Error{Code: CodeOK}
+	if err != nil {
+		var ok bool
+		if status, ok = err.(*Error); !ok {
+			status = &Error{CodeUnknown, err.Error()}
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	if status.Message != "" {
+		w.Header().Set("Grpc-Message", encodeGRPCMessage(status.Message))
+	}
+}
+
+// call invokes the method of a request.
+func (s *Server) call(w http.ResponseWriter, r *http.Request) error {
+	req, err := readMessage(r.Body)
+	if err != nil {
+		if _, ok := err.(*Error); ok {
+			return err
+		}
+		return &Error{CodeInvalidArgument, "can't read request: " + err.Error()}
+	}
+	switch r.URL.Path {
+	case "/" + ServiceName + "/ListRoots":
+		return writeMessage(w, encodeRoots(s.roots.Names()))
+	case "/" + ServiceName + "/Scan":
+		root, err := parseString(req, 1)
+		if err != nil {
+			return &Error{CodeInvalidArgument, err.Error()}
+		}
+		res, err := s.scan(root)
+		if err != nil {
+			return err
+		}
+		return writeMessage(w, res)
+	case "/" + ServiceName + "/Watch":
+		root, interval, err := decodeWatchRequest(req)
+		if err != nil {
+			return &Error{CodeInvalidArgument, err.Error()}
+		}
+		return s.watch(w, r, root, interval)
+	default:
+		return &Error{CodeUnimplemented, "unknown method " + r.URL.Path}
+	}
+}
+
+// watch streams scans of root until the call is canceled.
+func (s *Server) watch(w http.ResponseWriter, r *http.Request, root string, interval time.Duration) error {
+	if interval < MinWatchInterval {
+		return &Error{CodeInvalidArgument, "interval must be at least " + MinWatchInterval.String()}
+	}
+	flusher, _ := w.(http.Flusher)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		res, err := s.scan(root)
+		if err != nil {
+			return err
+		}
+		if err := writeMessage(w, res); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return &Error{CodeCanceled, "call canceled"}
+		}
+	}
+}
+
+// scan scans the named root and returns the encoded result.
+func (s *Server) scan(root string) ([]byte, error) {
+	v, ok := s.roots.Get(root)
+	if !ok {
+		return nil, &Error{CodeNotFound, "unknown root " + root}
+	}
+	s.mu.Lock()
+	start := time.Now()
+	sizes := memsize.Scan(v)
+	res := &ScanResult{
+		Root:     root,
+		Date:     start,
+		Duration: time.Since(start),
+		Sizes:    sizes.Snapshot(),
+	}
+	s.mu.Unlock()
+	msg, err := encodeScanResult(res)
+	if err != nil {
+		return nil, &Error{CodeInternal, err.Error()}
+	}
+	return msg, nil
+}
@@ -0,0 +1,258 @@
+package memsizerpc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// This file implements the protobuf encoding of the messages in memsize.proto and
+// the gRPC message framing.
+
+const maxMessageSize = 64 << 20
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendStringField appends a string field. Like all proto3 scalar fields, it is
+// omitted when empty.
+func appendStringField(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytesField(b, field, []byte(s))
+}
+
+func appendInt64Field(b []byte, field int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendVarint(b, uint64(field)<<3|wireVarint)
+	return appendVarint(b, uint64(v))
+}
+
+func readVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errTruncated
+}
+
+// parseFields calls fn for every field of a message. For varint fields, v holds
+// the value. For length-delimited fields, data holds the content. Fields of other
+// wire types are skipped.
+func parseFields(b []byte, fn func(field int, v uint64, data []byte)) error {
+	for len(b) > 0 {
+		key, n, err := readVarint(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			v, n, err := readVarint(b)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+			fn(field, v, nil)
+		case wireBytes:
+			size, n, err := readVarint(b)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+			if size > uint64(len(b)) {
+				return errTruncated
+			}
+			fn(field, 0, b[:size])
+			b = b[size:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			b = b[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+	}
+	return nil
+}
+
+// parseString decodes a message whose only field of interest is a string, e.g.
+// ScanRequest.
+func parseString(b []byte, field int) (string, error) {
+	var s string
+	err := parseFields(b, func(f int, _ uint64, data []byte) {
+		if f == field {
+			s = string(data)
+		}
+	})
+	return s, err
+}
+
+func encodeRoots(roots []string) []byte {
+	var b []byte
+	for _, r := range roots {
+		b = appendBytesField(b, 1, []byte(r))
+	}
+	return b
+}
+
+func decodeRoots(b []byte) ([]string, error) {
+	roots := []string{}
+	err := parseFields(b, func(field int, _ uint64, data []byte) {
+		if field == 1 {
+			roots = append(roots, string(data))
+		}
+	})
+	return roots, err
+}
+
+func encodeWatchRequest(root string, interval time.Duration) []byte {
+	b := appendStringField(nil, 1, root)
+	return appendInt64Field(b, 2, int64(interval))
+}
+
+func decodeWatchRequest(b []byte) (root string, interval time.Duration, err error) {
+	err = parseFields(b, func(field int, v uint64, data []byte) {
+		switch field {
+		case 1:
+			root = string(data)
+		case 2:
+			interval = time.Duration(v)
+		}
+	})
+	return root, interval, err
+}
+
+func encodeScanResult(res *ScanResult) ([]byte, error) {
+	snap, err := json.Marshal(res.Sizes)
+	if err != nil {
+		return nil, err
+	}
+	b := appendStringField(nil, 1, res.Root)
+	b = appendInt64Field(b, 2, res.Date.UnixNano())
+	b = appendInt64Field(b, 3, int64(res.Duration))
+	return appendBytesField(b, 4, snap), nil
+}
+
+func decodeScanResult(b []byte) (*ScanResult, error) {
+	var (
+		res  = new(ScanResult)
+		date int64
+		snap []byte
+	)
+	err := parseFields(b, func(field int, v uint64, data []byte) {
+		switch field {
+		case 1:
+			res.Root = string(data)
+		case 2:
+			date = int64(v)
+		case 3:
+			res.Duration = time.Duration(v)
+		case 4:
+			snap = data
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.Date = time.Unix(0, date)
+	if err := json.Unmarshal(snap, &res.Sizes); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	return res, nil
+}
+
+// writeMessage writes a gRPC length-prefixed message.
+func writeMessage(w io.Writer, msg []byte) error {
+	var hdr [5]byte // not compressed
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(msg)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// readMessage reads a gRPC length-prefixed message. It returns io.EOF when the
+// stream ends before the message.
+func readMessage(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errTruncated
+		}
+		return nil, err
+	}
+	if hdr[0] != 0 {
+		return nil, &Error{CodeUnimplemented, "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > maxMessageSize {
+		return nil, &Error{CodeResourceExhausted, fmt.Sprintf("message of %d bytes is too large", size)}
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, errTruncated
+	}
+	return msg, nil
+}
+
+// encodeGRPCMessage percent-encodes a status message for the grpc-message
+// trailer.
+func encodeGRPCMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func decodeGRPCMessage(msg string) string {
+	if s, err := url.PathUnescape(msg); err == nil {
+		return s
+	}
+	return msg
+}
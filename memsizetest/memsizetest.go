@@ -0,0 +1,68 @@
+//go:build go1.13
+// +build go1.13
+
+// Package memsizetest provides helpers for measuring memory usage in tests and
+// benchmarks.
+//
+// BenchmarkSize reports the size of a data structure as benchmark metrics, so it
+// can be tracked with the usual benchmark tooling:
+//
+//	func BenchmarkCache(b *testing.B) {
+//		memsizetest.BenchmarkSize(b, func() interface{} {
+//			return buildCache(1000)
+//		})
+//	}
+package memsizetest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fjl/memsize"
+)
+
+// BenchmarkSize calls build b.N times and scans the value returned by the last
+// call. The total size is reported as the "bytes" metric. When the value is an
+// array, slice, map, channel or string, or a pointer to one, the size per element
+// is reported as "bytes/elem".
+//
+// The time measured by the benchmark is the time spent in build. Values which
+// aren't pointers are copied into a new variable before scanning, and the size of
+// that variable is included in the total.
+func BenchmarkSize(b *testing.B, build func() interface{}) {
+	b.Helper()
+	var v interface{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v = build()
+	}
+	b.StopTimer()
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		b.Fatal("build returned nil")
+	}
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		rv = p
+	}
+	sizes := memsize.Scan(rv.Interface())
+	b.ReportMetric(float64(sizes.Total), "bytes")
+	if n := elements(rv.Elem()); n > 0 {
+		b.ReportMetric(float64(sizes.Total)/float64(n), "bytes/elem")
+	}
+}
+
+// elements returns the number of elements of v, or zero if v has no elements.
+func elements(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Chan, reflect.String:
+		return v.Len()
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return elements(v.Elem())
+		}
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+//go:build go1.13
+// +build go1.13
+
+package memsizetest
+
+import (
+	"testing"
+
+	"github.com/fjl/memsize"
+)
+
+func TestBenchmarkSize(t *testing.T) {
+	type point struct{ x, y int64 }
+
+	slice := make([]byte, 100)
+	m := map[int]string{1: "one", 2: "two", 3: "three"}
+	s := &point{1, 2}
+	tests := []struct {
+		name  string
+		v     interface{}
+		total uintptr
+		elems int
+	}{
+		// Values which aren't pointers are scanned through a new variable.
+		{"slice", slice, memsize.Scan(&slice).Total, 100},
+		{"map", m, memsize.Scan(&m).Total, 3},
+		{"struct", *s, memsize.Scan(s).Total, 0},
+		{"pointer", s, memsize.Scan(s).Total, 0},
+		{"slice pointer", &slice, memsize.Scan(&slice).Total, 100},
+	}
+	for _, test := range tests {
+		res := testing.Benchmark(func(b *testing.B) {
+			BenchmarkSize(b, func() interface{} { return test.v })
+		})
+		if got := res.Extra["bytes"]; got != float64(test.total) {
+			t.Errorf("%s: bytes = %v, want %d", test.name, got, test.total)
+		}
+		perElem, ok := res.Extra["bytes/elem"]
+		switch {
+		case test.elems == 0 && ok:
+			t.Errorf("%s: unexpected bytes/elem %v", test.name, perElem)
+		case test.elems > 0 && perElem != float64(test.total)/float64(test.elems):
+			t.Errorf("%s: bytes/elem = %v, want %v", test.name, perElem, float64(test.total)/float64(test.elems))
+		}
+	}
+}
@@ -2,6 +2,7 @@ package memsizeui
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -14,6 +15,10 @@ import (
 	"github.com/fjl/memsize"
 )
 
+// maxReports is the number of reports kept by Handler. Older reports are
+// discarded when a new scan is made.
+const maxReports = 50
+
 type Handler struct {
 	init     sync.Once
 	mux      http.ServeMux
@@ -62,6 +67,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.mux.HandleFunc("/", h.handleRoot)
 		h.mux.HandleFunc("/scan", h.handleScan)
 		h.mux.HandleFunc("/report/", h.handleReport)
+		h.mux.HandleFunc("/api/roots", h.handleAPIRoots)
+		h.mux.HandleFunc("/api/scan", h.handleAPIScan)
 	})
 	h.mux.ServeHTTP(w, r)
 }
@@ -72,12 +79,16 @@ func (h *Handler) templateInfo(r *http.Request, data interface{}) *templateInfo
 	for name := range h.roots {
 		roots = append(roots, name)
 	}
+	reports := make(map[int]Report, len(h.reports))
+	for id, r := range h.reports {
+		reports[id] = r
+	}
 	h.mu.Unlock()
 	sort.Strings(roots)
 
 	return &templateInfo{
 		Roots:     roots,
-		Reports:   h.reports,
+		Reports:   reports,
 		PathDepth: strings.Count(r.URL.Path, "/") - 1,
 		Data:      data,
 	}
@@ -120,6 +131,41 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// apiReport is the JSON encoding of a report.
+type apiReport struct {
+	ID       int
+	Root     string
+	Date     time.Time
+	Duration time.Duration
+	Sizes    memsize.Snapshot
+}
+
+func (h *Handler) handleAPIRoots(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, http.StatusOK, h.templateInfo(r, nil).Roots)
+}
+
+func (h *Handler) handleAPIScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "invalid HTTP method, want POST", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := h.scan(r.URL.Query().Get("root"))
+	if !ok {
+		http.Error(w, "unknown root", http.StatusNotFound)
+		return
+	}
+	h.mu.Lock()
+	report := h.reports[id]
+	h.mu.Unlock()
+	serveJSON(w, http.StatusOK, apiReport{
+		ID:       report.ID,
+		Root:     report.RootName,
+		Date:     report.Date,
+		Duration: report.Duration,
+		Sizes:    report.Sizes.Snapshot(),
+	})
+}
+
 func (h *Handler) scan(root string) (int, bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -138,6 +184,7 @@ func (h *Handler) scan(root string) (int, bool) {
 		Duration: time.Since(start),
 		Sizes:    sizes,
 	}
+	delete(h.reports, id-maxReports)
 	h.reportID++
 	return id, true
 }
@@ -149,5 +196,17 @@ func serveHTML(w http.ResponseWriter, tpl *template.Template, status int, ti *te
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
+
+func serveJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,32 @@
+package memsizeui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReportLimit(t *testing.T) {
+	var h Handler
+	h.Add("v", &struct{ x []byte }{make([]byte, 10)})
+	for i := 0; i < maxReports+10; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/scan?root=v", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("scan %d: status %d", i, w.Code)
+		}
+	}
+	if len(h.reports) != maxReports {
+		t.Fatalf("%d reports stored, want %d", len(h.reports), maxReports)
+	}
+	for _, test := range []struct {
+		id   string
+		code int
+	}{{"9", http.StatusNotFound}, {"10", http.StatusOK}, {"59", http.StatusOK}} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/report/"+test.id, nil))
+		if w.Code != test.code {
+			t.Errorf("report %s: status %d, want %d", test.id, w.Code, test.code)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package memsize
+
+import (
+	"os"
+	"runtime"
+	"time"
+)
+
+// Metadata describes the program and host which produced a result, see
+// Options.Metadata.
+type Metadata struct {
+	GoVersion string    `json:"goVersion"`
+	GOOS      string    `json:"goos"`
+	GOARCH    string    `json:"goarch"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Time      time.Time `json:"time"` // start of the scan, in UTC
+	// Build holds the build information embedded in the binary. It is nil when the
+	// binary was built without module support or by Go 1.17 and older.
+	Build *BuildInfo `json:"build,omitempty"`
+}
+
+// BuildInfo is the build information of the binary, see runtime/debug.BuildInfo.
+type BuildInfo struct {
+	Path    string `json:"path"` // main package path
+	Module  string `json:"module,omitempty"`
+	Version string `json:"version,omitempty"`
+	Sum     string `json:"sum,omitempty"`
+	// Settings holds the build settings, e.g. "vcs.revision" and "GOAMD64".
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// captureMetadata reads the metadata of the running program.
+func captureMetadata(now time.Time) *Metadata {
+	m := &Metadata{
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		Time:      now.UTC(),
+		Build:     readBuildInfo(),
+	}
+	m.Hostname, _ = os.Hostname()
+	return m
+}
+
+// String returns a one-line summary, e.g.
+// "go1.22.1 linux/amd64 host=db1 build=example.com/cmd/server@v1.2.0 rev=4f1c2d9".
+func (m *Metadata) String() string {
+	if m == nil {
+		return "<nil>"
+	}
+	s := m.GoVersion + " " + m.GOOS + "/" + m.GOARCH
+	if m.Hostname != "" {
+		s += " host=" + m.Hostname
+	}
+	if b := m.Build; b != nil {
+		s += " build=" + b.Path
+		if b.Version != "" {
+			s += "@" + b.Version
+		}
+		if rev := b.Settings["vcs.revision"]; rev != "" {
+			if len(rev) > 7 {
+				rev = rev[:7]
+			}
+			s += " rev=" + rev
+			if b.Settings["vcs.modified"] == "true" {
+				s += "+dirty"
+			}
+		}
+	}
+	return s
+}
+
+// SameBuild reports whether m and other were produced by the same build of the
+// program. Results from different builds may differ because of changes to types
+// and algorithms, not because of the program state. Metadata without build
+// information compares the Go version and platform only.
+func (m *Metadata) SameBuild(other *Metadata) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.GoVersion != other.GoVersion || m.GOOS != other.GOOS || m.GOARCH != other.GOARCH {
+		return false
+	}
+	if m.Build == nil || other.Build == nil {
+		return m.Build == other.Build
+	}
+	a, b := m.Build, other.Build
+	if a.Path != b.Path || a.Version != b.Version || a.Sum != b.Sum {
+		return false
+	}
+	return a.Settings["vcs.revision"] == b.Settings["vcs.revision"] && a.Settings["vcs.modified"] == b.Settings["vcs.modified"]
+}
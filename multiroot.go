@@ -0,0 +1,204 @@
+package memsize
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// AttributionPolicy determines how ScanRoots attributes memory which is reachable
+// from more than one root.
+type AttributionPolicy int
+
+const (
+	// AttributeFirstSeen attributes shared memory to the first root reaching it,
+	// in the order of RootSet.Names. This is the default.
+	AttributeFirstSeen AttributionPolicy = iota
+	// AttributeSplit divides the size and the external amounts (see Accountant) of
+	// shared objects evenly among all roots reaching them. Count still includes the
+	// object for every root.
+	AttributeSplit
+	// AttributeShared attributes shared objects to RootSizes.Shared instead of any
+	// root.
+	AttributeShared
+)
+
+// RootSizes is the result of ScanRoots.
+type RootSizes struct {
+	ByRoot map[string]Sizes
+	// Shared holds the objects reachable from more than one root. It is only
+	// populated by AttributeShared.
+	Shared Sizes
+}
+
+// ScanRoots scans all roots of rs while the world is stopped once. Memory reachable
+// from several roots is attributed according to opts.Attribution.
+//
+// Sharing is determined per object and per block of referenced data, i.e. string
+// data, slice backing arrays and map entries. For AttributeSplit and
+// AttributeShared, the scan visits every root twice.
+func ScanRoots(rs *RootSet, opts Options) RootSizes {
+	names := rs.Names()
+	roots := make([]reflect.Value, 0, len(names))
+	for _, name := range names {
+		v, _ := rs.Get(name)
+		roots = append(roots, reflect.ValueOf(v))
+	}
+	c := newScanState(&opts)
+	results := c.scanRoots(context.Background(), roots)
+
+	res := RootSizes{ByRoot: make(map[string]Sizes, len(names))}
+	for i, name := range names {
+		res.ByRoot[name] = results[i]
+	}
+	if c.shared != nil {
+		res.Shared = *c.shared
+	} else {
+		res.Shared = *c.newSizes()
+	}
+	res.Shared.Metadata = c.meta
+	return res
+}
+
+// ScanAll scans all values while the world is stopped once, so the results are
+// consistent with each other. Memory reachable from several values is counted for
+// the first of them. The values must be non-nil pointers.
+func ScanAll(vs ...interface{}) []Sizes {
+	roots := make([]reflect.Value, len(vs))
+	for i, v := range vs {
+		roots[i] = reflect.ValueOf(v)
+		if roots[i].Kind() != reflect.Ptr || roots[i].IsNil() {
+			panic("value to scan must be non-nil pointer")
+		}
+	}
+	return newScanState(&Options{}).scanRoots(context.Background(), roots)
+}
+
+// scanRoots scans the given roots in order, returning a result for each.
+func (c *scanState) scanRoots(ctx context.Context, roots []reflect.Value) []Sizes {
+	c.setContext(ctx)
+	c.stopped = ctx.Err() != nil
+	c.goroutines = captureGoroutines(c.goroutineStacks)
+	if c.metadata {
+		c.meta = captureMetadata(time.Now())
+	}
+
+	results := make([]Sizes, len(roots))
+	weak := make([]weakScan, len(roots))
+	c.withWorldStopped(func() {
+		if c.policy != AttributeFirstSeen {
+			c.markReach(roots)
+			if c.policy == AttributeShared {
+				c.shared = c.newSizes()
+			}
+		}
+		for i, rv := range roots {
+			c.root = i
+			c.s = c.newSizes()
+			if c.addrClasses {
+				c.s.RootClass = classifyAddress(address(rv.Pointer()))
+			}
+			if c.reach != nil {
+				// Shared objects must be visited again for every root.
+				c.seen = newSeenSet(&c.seenOpts)
+			}
+			c.scanContent(invalidAddr, rv)
+			weak[i] = c.takeWeak()
+			results[i] = c.finish()
+		}
+	})
+	c.scanWeak(weak, results)
+	if c.shared != nil {
+		c.shared.Partial = c.stopped
+	}
+	return results
+}
+
+// markReach records the memory reachable from each root in c.reach.
+func (c *scanState) markReach(roots []reflect.Value) {
+	c.reach = make([]seenSet, len(roots))
+	for i, rv := range roots {
+		// The pass traverses like the scan, so it is configured by the same options.
+		// It shares the pause and interruption state of c. Accountants are not
+		// called because they must see every object once.
+		p := &scanState{tc: c.tc}
+		p.reset(&c.opts)
+		p.policy = AttributeFirstSeen
+		p.accountants = nil
+		p.slicer, p.slow = c.slicer, c.slow
+		p.done, p.deadline, p.hasDeadline, p.stopped = c.done, c.deadline, c.hasDeadline, c.stopped
+		p.scanContent(invalidAddr, rv)
+		c.reach[i] = p.seen
+		c.stopped = p.stopped
+	}
+}
+
+// attributeData reports whether the memory of data referenced by objects, i.e.
+// string data, slice backing arrays and map entries, must be attributed by
+// scanData.
+func (c *scanState) attributeData() bool {
+	return c.reach != nil && c.policy != AttributeFirstSeen
+}
+
+// scanData attributes the memory of data at addr according to the policy. The data
+// is accounted by scan, which returns its size. Like objects, data reachable from
+// several roots is split among them or moved to the shared result. The data is
+// identified by its first byte.
+func (c *scanState) scanData(addr uintptr, scan func() uintptr) uintptr {
+	first, n := c.reachedBy(address(addr), 1)
+	if n <= 1 {
+		return scan()
+	}
+	switch c.policy {
+	case AttributeSplit:
+		// Memory is accounted with a divisor of n. The returned size is divided by the
+		// divisor of the enclosing object, so it has to be scaled accordingly.
+		outer := c.split
+		c.split = n
+		extra := scan()
+		c.split = outer
+		return extra * outer / n
+	case AttributeShared:
+		if c.s == c.shared {
+			return scan() // the enclosing object is shared
+		}
+		if first < c.root {
+			return 0 // already counted in the shared result
+		}
+		outer := c.s
+		c.s = c.shared
+		extra := scan()
+		c.s = outer
+		c.shared.addReferenced(c.owner, extra)
+		return 0
+	default:
+		return scan()
+	}
+}
+
+// addReferenced adds referenced memory of the given type.
+func (s *Sizes) addReferenced(typ reflect.Type, n uintptr) {
+	s.Total += n
+	ts := s.ByType[typ]
+	if ts == nil {
+		ts = new(TypeSize)
+		s.ByType[typ] = ts
+	}
+	ts.Total += n
+	ts.Referenced += n
+}
+
+// reachedBy returns the index of the first root reaching the given object and the
+// number of roots reaching it.
+func (c *scanState) reachedBy(addr address, size uintptr) (first int, n uintptr) {
+	first = -1
+	for i, seen := range c.reach {
+		if seen.countRange(uintptr(addr), size) > 0 {
+			if first < 0 {
+				first = i
+			}
+			n++
+		}
+	}
+	return first, n
+}
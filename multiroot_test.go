@@ -0,0 +1,170 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestScanRoots(t *testing.T) {
+	var (
+		shared = &structptr{cld: &structptr{}}
+		a      = &structiface{x: shared}
+		b      = &structptrslice{s: &structslice{s: make([]uint32, 4)}}
+		c      = &struct{ p *structptr }{p: shared}
+		rs     RootSet
+	)
+	rs.Add("a", a)
+	rs.Add("b", b)
+	rs.Add("c", c)
+
+	tPtr := reflect.TypeOf(structptr{})
+	ptrSize := func(s Sizes) uintptr {
+		if ts := s.ByType[tPtr]; ts != nil {
+			return ts.Total
+		}
+		return 0
+	}
+	sharedSize := 2 * 2 * sizeofWord
+	bTotal := Scan(b).Total
+
+	tests := []struct {
+		policy   AttributionPolicy
+		a, c, in uintptr // structptr memory per root and in the shared result
+	}{
+		{policy: AttributeFirstSeen, a: sharedSize},
+		{policy: AttributeSplit, a: sharedSize / 2, c: sharedSize / 2},
+		{policy: AttributeShared, in: sharedSize},
+	}
+	for _, test := range tests {
+		res := ScanRoots(&rs, Options{Attribution: test.policy})
+		if len(res.ByRoot) != 3 {
+			t.Fatalf("policy %d: wrong number of results: %d", test.policy, len(res.ByRoot))
+		}
+		if got := ptrSize(res.ByRoot["a"]); got != test.a {
+			t.Errorf("policy %d: root a has %d bytes of structptr, want %d", test.policy, got, test.a)
+		}
+		if got := ptrSize(res.ByRoot["c"]); got != test.c {
+			t.Errorf("policy %d: root c has %d bytes of structptr, want %d", test.policy, got, test.c)
+		}
+		if got := ptrSize(res.Shared); got != test.in {
+			t.Errorf("policy %d: shared result has %d bytes of structptr, want %d", test.policy, got, test.in)
+		}
+		if got := res.ByRoot["b"].Total; got != bTotal {
+			t.Errorf("policy %d: root b total %d, want %d", test.policy, got, bTotal)
+		}
+		sum := res.Shared.Total
+		for _, s := range res.ByRoot {
+			sum += s.Total
+		}
+		if want := Scan(&[]interface{}{a, b, c}).Total - reflect.TypeOf([3]interface{}{}).Size() - sizeofSlice; sum != want {
+			t.Errorf("policy %d: sum of results %d, want %d", test.policy, sum, want)
+		}
+	}
+}
+
+func TestScanRootsExternal(t *testing.T) {
+	type file struct{ fd int }
+	var (
+		shared = &file{1}
+		rs     RootSet
+	)
+	rs.Add("a", &struct{ f *file }{shared})
+	rs.Add("b", &struct{ f *file }{shared})
+	fds := AccountantFunc(func(v reflect.Value) (string, uint64) {
+		if v.Type() == reflect.TypeOf(file{}) {
+			return "fd", 10
+		}
+		return "", 0
+	})
+
+	tests := []struct {
+		policy       AttributionPolicy
+		a, b, shared uint64
+	}{
+		{policy: AttributeFirstSeen, a: 10},
+		{policy: AttributeSplit, a: 5, b: 5},
+		{policy: AttributeShared, shared: 10},
+	}
+	for _, test := range tests {
+		res := ScanRoots(&rs, Options{Attribution: test.policy, Accountants: []Accountant{fds}})
+		a, b, shared := res.ByRoot["a"].External["fd"], res.ByRoot["b"].External["fd"], res.Shared.External["fd"]
+		if a != test.a || b != test.b || shared != test.shared {
+			t.Errorf("policy %d: external amounts a=%d b=%d shared=%d, want a=%d b=%d shared=%d",
+				test.policy, a, b, shared, test.a, test.b, test.shared)
+		}
+	}
+}
+
+func TestScanAll(t *testing.T) {
+	shared := &struct16{}
+	a := &structiface{s: shared}
+	b := &structiface{s: shared, x: shared}
+	res := ScanAll(a, b)
+	if len(res) != 2 {
+		t.Fatalf("wrong number of results: %d", len(res))
+	}
+	if want := Scan(a).Total; res[0].Total != want {
+		t.Errorf("first total %d, want %d", res[0].Total, want)
+	}
+	if want := Scan(&[]*structiface{a, b}).Total - sizeofSlice - 2*sizeofWord; res[0].Total+res[1].Total != want {
+		t.Errorf("totals add up to %d, want %d", res[0].Total+res[1].Total, want)
+	}
+}
+
+func TestScanRootsSharedData(t *testing.T) {
+	names := []string{strings.Repeat("a", 100), strings.Repeat("b", 200)}
+	m := map[string]int{names[0]: 0, names[1]: 1}
+	var rs RootSet
+	rs.Add("names", &names)
+	rs.Add("map", &m)
+	rs.Add("map2", &struct{ m map[string]int }{m})
+	all := Scan(&[]interface{}{&names, &m, rs.roots["map2"]}).Total - sizeofSlice - 3*sizeofInterface
+	for _, policy := range []AttributionPolicy{AttributeFirstSeen, AttributeSplit, AttributeShared} {
+		res := ScanRoots(&rs, Options{Attribution: policy})
+		sum := res.Shared.Total
+		strs := res.Shared.ByKind()[reflect.String]
+		for _, s := range res.ByRoot {
+			sum += s.Total
+			strs += s.ByKind()[reflect.String]
+		}
+		// Allow for rounding of split memory.
+		if sum > all || sum < all-4 {
+			t.Errorf("policy %d: sum of results %d, want %d", policy, sum, all)
+		}
+		if strs > 300 || strs < 300-4 {
+			t.Errorf("policy %d: string data %d, want 300", policy, strs)
+		}
+	}
+}
+
+func TestScanRootsOptions(t *testing.T) {
+	x := &structslice{s: make([]uint32, 4)}
+	start := uintptr(unsafe.Pointer(x))
+	var rs RootSet
+	rs.Add("a", &struct{ p *structslice }{x})
+	rs.Add("b", &struct{ p *structslice }{x})
+
+	// The excluded object must not be visited by any pass of the scan.
+	visits := 0
+	opts := Options{
+		Attribution:   AttributeSplit,
+		ExcludeRanges: []AddressRange{{start, start + unsafe.Sizeof(*x)}},
+		OnValue: func(_ string, v reflect.Value) Action {
+			if v.Type() == reflect.TypeOf(structslice{}) {
+				visits++
+			}
+			return Continue
+		},
+	}
+	res := ScanRoots(&rs, opts)
+	if visits != 0 {
+		t.Errorf("excluded object visited %d times", visits)
+	}
+	for name, s := range res.ByRoot {
+		if s.Total != sizeofWord {
+			t.Errorf("root %s: total %d, want %d", name, s.Total, sizeofWord)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package memsize
+
+import (
+	"reflect"
+	"time"
+)
+
+// Options configures a scan. The zero value is the configuration used by Scan.
+type Options struct {
+	// ApproxDedup makes the scanner track visited memory in a fixed-size Bloom filter
+	// instead of an exact bitmap. This puts a hard cap on the memory used by the
+	// scanner itself, which is useful for extremely large heaps. The downside is
+	// that false positives in the filter make the scanner treat some unvisited
+	// memory as visited, so totals may be underestimated.
+	//
+	// The filter holds one entry per visited object, plus one per 256 bytes of
+	// larger objects. While the number of entries stays below ApproxDedupEntries,
+	// about 0.5% of them are false positives and the total is underestimated by
+	// about the same fraction. The rate grows with the number of entries: it is
+	// about 3% at twice and 15% at four times the expected number. Overlapping
+	// memory is tracked with the same granularity: a slice or string starting
+	// inside memory visited before is counted again up to the next 256-byte
+	// boundary, and memory reached through an interior pointer may be missed up
+	// to there when the enclosing object is visited later.
+	ApproxDedup bool
+
+	// ApproxDedupEntries is the expected number of filter entries when ApproxDedup
+	// is set, see above. The filter takes 2 bytes per entry. The default is 8M
+	// entries, i.e. a 16 MB filter.
+	ApproxDedupEntries uintptr
+
+	// ApproxDedupBytes overrides the size of the Bloom filter computed from
+	// ApproxDedupEntries. The size is rounded down to a power of two.
+	ApproxDedupBytes uintptr
+
+	// Ownership enables recording of the type ownership matrix in Sizes.Ownership.
+	Ownership bool
+
+	// Accountants are invoked for every object counted by the scan.
+	// They report external resources, see Accountant.
+	Accountants []Accountant
+
+	// PoolContents enables scanning of items held by sync.Pool. Pooled items are
+	// normally freed by the garbage collector eventually, so they are not scanned by
+	// default.
+	PoolContents bool
+
+	// StringPins enables reporting of strings which keep much more string data
+	// alive than their own, see Sizes.StringPins and StringPin. A string is
+	// reported when the region of string data it is part of is at least
+	// StringPinFactor times its own length. The default factor is 8.
+	StringPins      bool
+	StringPinFactor uintptr
+
+	// Cycles enables detection of reference cycles, see Sizes.Cycles. When
+	// MaxCyclePaths is non-zero, up to that many example cycles are recorded in
+	// Sizes.CyclePaths.
+	Cycles        bool
+	MaxCyclePaths int
+
+	// MaxPerType limits the number of values of the given types whose content is
+	// traversed. Further values of these types are counted, but their referenced
+	// memory is extrapolated from the traversed values, see TypeSize.Estimated.
+	// The extrapolated memory is accounted to the kinds of data holding it in the
+	// traversed values, see Sizes.ByKind.
+	//
+	// Of the values which aren't traversed, only the fields pointing to values of
+	// the given types are followed, including slices and arrays of such pointers.
+	// This finds all nodes of linked structures such as lists and trees. Values of
+	// other types which are only reachable through values that weren't traversed
+	// are not found.
+	MaxPerType map[reflect.Type]int
+
+	// GoroutineStacks enables attribution of goroutines to their start function
+	// in Sizes.Goroutines. This reads the stack traces of all goroutines before
+	// the scan.
+	GoroutineStacks bool
+
+	// Metadata enables recording of the Go version, platform, build information
+	// and host name of the program in Sizes.Metadata, along with the time of the
+	// scan. Snapshots of different builds of a program are often not comparable.
+	Metadata bool
+
+	// WeakReachable enables counting of memory which is only reachable through
+	// weak pointers (weak.Pointer, Go 1.24+) in Sizes.WeakReachable. Weak pointers
+	// don't keep memory alive and are never followed as part of the values holding
+	// them. Their targets are resolved while the world is running and scanned in a
+	// second pause after the main scan. This is not supported in purego mode.
+	WeakReachable bool
+
+	// AddressClasses enables classification of the scanned memory into heap,
+	// stack and global memory, see Sizes.ByAddressClass. It requires Go 1.14 or
+	// later and is not supported in purego mode.
+	AddressClasses bool
+
+	// NilPointers enables counting of nil and non-nil values of pointer-like fields
+	// and elements in Sizes.Pointers.
+	NilPointers bool
+
+	// ChanOccupiedOnly makes the scan count only the occupied slots of channel
+	// buffers. The memory of empty slots is reported in Sizes.UnusedChanCapacity.
+	ChanOccupiedOnly bool
+
+	// SliceHistograms enables recording of slice lengths and capacities per element
+	// type in Sizes.Slices.
+	SliceHistograms bool
+
+	// BackingArrays enables counting of distinct slice backing arrays and string
+	// data blocks in Sizes.BackingArrays and Sizes.StringBlocks. These counts
+	// approximate the number of allocations, which helps to correlate results
+	// with heap profiles.
+	BackingArrays bool
+
+	// MapStats enables recording of the hash table layout of maps in Sizes.Maps.
+	// It is not available in portable mode. The memory of hash tables is not
+	// included in Total, which only counts the entries.
+	MapStats bool
+
+	// ChanStats enables recording of channel buffer statistics in Sizes.Chans, see
+	// Sizes.ChanReport. In portable mode, the elements in channel buffers are not
+	// scanned and ChanStats.ElemBytes is always zero.
+	ChanStats bool
+
+	// Headers enables reporting of the memory taken by string, slice, map and
+	// channel headers in TypeSize.Headers.
+	Headers bool
+
+	// MaxPausePerSlice bounds the time the world is stopped at once. When the scan
+	// takes longer, the world is started for a moment after each slice of the
+	// given duration, letting other goroutines run. This trades consistency for
+	// latency and is meant for read-mostly data. Pointers, interfaces, strings and
+	// slice headers are each read in one piece while the world is stopped, and
+	// slice elements are scanned through a copy of the header taken before the
+	// first of them, so headers are never torn. But the fields of a struct or the
+	// elements of a slice are read at different times, and the result mixes the old
+	// and new state of values modified between slices: memory which is only
+	// reachable through a value replaced during the scan may be counted or missed.
+	// Map iteration, channel buffers and internals of special types like sync.Map
+	// are always scanned within a single slice, so a slice may exceed the duration.
+	// The limit is checked after every 1024 values.
+	MaxPausePerSlice time.Duration
+
+	// SlowScan enables capture of the traversal stack when the scan takes longer
+	// than the given duration, see Sizes.SlowScanPath. This helps to find the
+	// structure a slow scan is stuck in. Combine it with a deadline (see
+	// Scanner.ScanContext) to get the partial result of a scan that would take too long.
+	SlowScan time.Duration
+
+	// OnValue is called for every value before the scan descends into it. The path
+	// is made of struct field names (".name"), indexes ("[3]") and map keys
+	// ("[key]"), starting at the scanned value, whose path is empty. Map keys of
+	// basic kinds are formatted by value without calling their methods, pointer
+	// keys by address and other keys by type name. Pointers and interfaces share
+	// the path of the value they refer to. Returning Skip prevents the scan from
+	// accessing anything referenced by the value.
+	//
+	// The callback runs while the world is stopped. It must not block, take locks
+	// or allocate heavily, and must not call methods of the value which might,
+	// e.g. String. Other goroutines can't run until the scan resumes them, so
+	// waiting for them deadlocks the program.
+	//
+	// Values are scanned once, so a value reachable through several paths is only
+	// reported for the first one. Since a skipped value is reported again when it
+	// is reached through another path, decisions should depend on the type of
+	// the value rather than the path when subtrees must never be traversed.
+	OnValue func(path string, v reflect.Value) Action
+
+	// AddressIndex makes the result retain the address ranges of the scanned
+	// objects, slice backing arrays and strings, see Sizes.TypeAt. The index takes
+	// several words of memory per object.
+	AddressIndex bool
+
+	// AttributeBuffers records the memory referenced by values of bytes.Buffer,
+	// strings.Builder, bufio.Reader, bufio.Writer, big.Int, big.Float and big.Rat
+	// for these types, even when the values are part of other values, e.g. struct
+	// fields. Normally, their buffers are counted as referenced memory of the
+	// enclosing value. With this option, the buffer type is listed with a count of
+	// the values and their buffers as referenced memory. The memory of the values
+	// themselves remains part of the enclosing values.
+	AttributeBuffers bool
+
+	// FocusTypes restricts the result to values of the listed types. The scan
+	// still traverses all reachable values, but values of other types are left
+	// out of Total, ByType, ByKind and the ownership matrix. The memory referenced
+	// by focused values (e.g. slice backing arrays) is counted as usual, and the
+	// ownership matrix records which types reference the focused types. Other
+	// statistics, like Options.SliceHistograms, are not restricted.
+	FocusTypes []reflect.Type
+
+	// ExcludeRanges lists memory regions which are never scanned, e.g.
+	// memory-mapped files. References of any kind into the regions, including
+	// maps, channels and the objects reported by traversers, are not followed and
+	// their targets are not counted.
+	ExcludeRanges []AddressRange
+
+	// Attribution determines how ScanRoots attributes memory reachable from
+	// multiple roots. It has no effect on single-root scans.
+	Attribution AttributionPolicy
+}
+
+const defaultApproxDedupEntries = 8 * 1024 * 1024
+
+const defaultStringPinFactor = 8
+
+func (opts *Options) stringPinFactor() uintptr {
+	if opts.StringPinFactor == 0 {
+		return defaultStringPinFactor
+	}
+	return opts.StringPinFactor
+}
+
+func (opts *Options) approxDedupBytes() uintptr {
+	if opts.ApproxDedupBytes != 0 {
+		return opts.ApproxDedupBytes
+	}
+	entries := opts.ApproxDedupEntries
+	if entries == 0 {
+		entries = defaultApproxDedupEntries
+	}
+	// Round up to keep the false positive rate below the documented bound.
+	return bloomFilterBytes(entries)*2 - 1
+}
@@ -0,0 +1,77 @@
+package memsize
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/fjl/memsize/internal/runtimefunc"
+)
+
+// pauseSlicer restarts the world periodically during a scan, see
+// Options.MaxPausePerSlice.
+type pauseSlicer struct {
+	world    runtimefunc.WorldStop // of the current pause
+	max      time.Duration
+	start    time.Time // start of the current pause
+	noYield  int       // nesting depth of sections which must not be interrupted
+	pauses   int
+	maxPause time.Duration
+}
+
+// begin is called after the world has been stopped.
+func (ps *pauseSlicer) begin() {
+	ps.start = time.Now()
+	ps.pauses++
+}
+
+// end records the duration of the current pause.
+func (ps *pauseSlicer) end(now time.Time) {
+	if d := now.Sub(ps.start); d > ps.maxPause {
+		ps.maxPause = d
+	}
+}
+
+// check starts the world for a moment if the current pause is too long.
+func (ps *pauseSlicer) check(now time.Time) {
+	if ps.noYield > 0 || now.Sub(ps.start) < ps.max {
+		return
+	}
+	ps.end(now)
+	runtimefunc.StartTheWorld(ps.world)
+	runtime.Gosched()
+	ps.world = runtimefunc.StopTheWorld()
+	ps.begin()
+}
+
+// enterNoYield and leaveNoYield enclose sections of the scan which must not run
+// concurrently with other goroutines, e.g. map iteration.
+func (c *scanState) enterNoYield() {
+	if c.slicer != nil {
+		c.slicer.noYield++
+	}
+}
+
+func (c *scanState) leaveNoYield() {
+	if c.slicer != nil {
+		c.slicer.noYield--
+	}
+}
+
+// withWorldStopped calls fn while the world is stopped.
+func (c *scanState) withWorldStopped(fn func()) {
+	w := runtimefunc.StopTheWorld()
+	if c.slicer == nil {
+		defer runtimefunc.StartTheWorld(w)
+		fn()
+		return
+	}
+	// The slicer restarts the world during fn, so it holds the current token.
+	ps := c.slicer
+	ps.world = w
+	ps.begin()
+	defer func() {
+		ps.end(time.Now())
+		runtimefunc.StartTheWorld(ps.world)
+	}()
+	fn()
+}
@@ -0,0 +1,108 @@
+package memsize
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PointerStats counts the nil and non-nil values of a pointer-like field, i.e. a
+// pointer, map, channel, interface, slice or function.
+type PointerStats struct {
+	Nil, NonNil uintptr
+}
+
+// NilFraction returns the fraction of values which were nil.
+func (ps PointerStats) NilFraction() float64 {
+	if ps.Nil+ps.NonNil == 0 {
+		return 0
+	}
+	return float64(ps.Nil) / float64(ps.Nil+ps.NonNil)
+}
+
+// elemField is the name used in Sizes.Pointers for the elements of arrays and slices.
+const elemField = "[]"
+
+// pointerTracker collects PointerStats during the scan, indexed by field.
+type pointerTracker map[reflect.Type][]PointerStats
+
+func (t pointerTracker) add(typ reflect.Type, field, nfields int, v reflect.Value) {
+	stats := t[typ]
+	if stats == nil {
+		stats = make([]PointerStats, nfields)
+		t[typ] = stats
+	}
+	if v.IsNil() {
+		stats[field].Nil++
+	} else {
+		stats[field].NonNil++
+	}
+}
+
+// result converts the collected statistics to the form of Sizes.Pointers and
+// resets the tracker.
+func (t pointerTracker) result() map[reflect.Type]map[string]PointerStats {
+	m := make(map[reflect.Type]map[string]PointerStats, len(t))
+	for typ, stats := range t {
+		fields := make(map[string]PointerStats)
+		for i, ps := range stats {
+			if ps == (PointerStats{}) {
+				continue
+			}
+			name := elemField
+			if typ.Kind() == reflect.Struct {
+				name = typ.Field(i).Name
+			}
+			fields[name] = ps
+		}
+		m[typ] = fields
+		delete(t, typ)
+	}
+	return m
+}
+
+// isPointerLike reports whether values of the kind can be nil.
+func isPointerLike(k reflect.Kind) bool {
+	switch k {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Interface, reflect.Slice, reflect.Func:
+		return true
+	}
+	return false
+}
+
+// NilPointerReport returns a table of the pointer-like fields recorded by
+// Options.NilPointers, ordered by decreasing number of nil values. Fields which
+// are nil in most values of their type waste memory and may be better served by
+// a different layout, e.g. moving the rarely set fields into a separate struct.
+func (s Sizes) NilPointerReport() string {
+	type line struct {
+		name string
+		ps   PointerStats
+	}
+	var lines []line
+	maxname := 0
+	for typ, fields := range s.Pointers {
+		for field, ps := range fields {
+			l := line{typ.String() + "." + field, ps}
+			if field == elemField {
+				l.name = typ.String() + field
+			}
+			if len(l.name) > maxname {
+				maxname = len(l.name)
+			}
+			lines = append(lines, l)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].ps.Nil != lines[j].ps.Nil {
+			return lines[i].ps.Nil > lines[j].ps.Nil
+		}
+		return lines[i].name < lines[j].name
+	})
+	var sb strings.Builder
+	for _, l := range lines {
+		fmt.Fprintf(&sb, "%-*s  nil %d  non-nil %d  (%.1f%% nil)\n", maxname, l.name, l.ps.Nil, l.ps.NonNil, l.ps.NilFraction()*100)
+	}
+	return sb.String()
+}
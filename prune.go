@@ -0,0 +1,134 @@
+package memsize
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Filter returns a copy of s which only contains the types for which keep returns
+// true. The per-type information (ByType, Ownership, Cycles, Pointers, Slices,
+// BackingArrays, Maps, Chans, Unscannable) is reduced accordingly, ownership
+// entries are kept when both types are kept. Entries keyed by a pointer, slice,
+// array, map or channel type are kept when one of its element or key types is
+// kept. All other fields, including Total, are those of the full result.
+func (s Sizes) Filter(keep func(reflect.Type, *TypeSize) bool) Sizes {
+	r := s
+	r.ByType = make(map[reflect.Type]*TypeSize)
+	for typ, ts := range s.ByType {
+		cpy := *ts
+		if keep(typ, &cpy) {
+			r.ByType[typ] = &cpy
+		}
+	}
+	kept := func(typ reflect.Type) bool {
+		_, ok := r.ByType[typ]
+		return ok
+	}
+	// keptElem also looks through element types. The depth is bounded because of
+	// recursive types like 'type P *P'.
+	var keptElem func(reflect.Type, int) bool
+	keptElem = func(typ reflect.Type, depth int) bool {
+		if kept(typ) {
+			return true
+		}
+		if depth == 0 {
+			return false
+		}
+		switch typ.Kind() {
+		case reflect.Map:
+			return keptElem(typ.Key(), depth-1) || keptElem(typ.Elem(), depth-1)
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Chan:
+			return keptElem(typ.Elem(), depth-1)
+		}
+		return false
+	}
+	if s.Ownership != nil {
+		r.Ownership = make(map[reflect.Type]map[reflect.Type]uintptr)
+		for parent, children := range s.Ownership {
+			if !kept(parent) {
+				continue
+			}
+			m := make(map[reflect.Type]uintptr)
+			for child, size := range children {
+				if kept(child) {
+					m[child] = size
+				}
+			}
+			r.Ownership[parent] = m
+		}
+	}
+	if s.Cycles != nil {
+		r.Cycles = make(map[reflect.Type]uintptr)
+		for typ, n := range s.Cycles {
+			if kept(typ) {
+				r.Cycles[typ] = n
+			}
+		}
+	}
+	if s.Pointers != nil {
+		r.Pointers = make(map[reflect.Type]map[string]PointerStats)
+		for typ, fields := range s.Pointers {
+			if kept(typ) {
+				r.Pointers[typ] = fields
+			}
+		}
+	}
+	if s.Slices != nil {
+		r.Slices = make(map[reflect.Type]*SliceStats)
+		for typ, ss := range s.Slices {
+			if keptElem(typ, 4) {
+				r.Slices[typ] = ss
+			}
+		}
+	}
+	if s.BackingArrays != nil {
+		r.BackingArrays = make(map[reflect.Type]uintptr)
+		for typ, n := range s.BackingArrays {
+			if keptElem(typ, 4) {
+				r.BackingArrays[typ] = n
+			}
+		}
+	}
+	if s.Maps != nil {
+		r.Maps = make(map[reflect.Type]*MapStats)
+		for typ, ms := range s.Maps {
+			if keptElem(typ, 4) {
+				r.Maps[typ] = ms
+			}
+		}
+	}
+	if s.Chans != nil {
+		r.Chans = make(map[reflect.Type]*ChanStats)
+		for typ, cs := range s.Chans {
+			if keptElem(typ, 4) {
+				r.Chans[typ] = cs
+			}
+		}
+	}
+	if s.Unscannable != nil {
+		r.Unscannable = make(map[reflect.Type]*BlockedStats)
+		for typ, bs := range s.Unscannable {
+			if keptElem(typ, 4) {
+				r.Unscannable[typ] = bs
+			}
+		}
+	}
+	return r
+}
+
+// Top returns a copy of s reduced to the n types with the largest total size, in
+// the same way as Filter.
+func (s Sizes) Top(n int) Sizes {
+	types := make([]reflect.Type, 0, len(s.ByType))
+	for typ := range s.ByType {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return reportLess(types[i], s.ByType[types[i]], types[j], s.ByType[types[j]])
+	})
+	top := make(map[reflect.Type]bool, n)
+	for i := 0; i < n && i < len(types); i++ {
+		top[types[i]] = true
+	}
+	return s.Filter(func(typ reflect.Type, _ *TypeSize) bool { return top[typ] })
+}
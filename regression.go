@@ -0,0 +1,86 @@
+package memsize
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tolerance is a limit on memory growth. Zero fields mean no limit.
+type Tolerance struct {
+	Bytes   uintptr // maximum absolute growth in bytes
+	Percent float64 // maximum relative growth in percent
+}
+
+// exceeded reports whether growth from old to new violates the tolerance.
+// Relative growth limits don't apply when old is zero.
+func (t Tolerance) exceeded(old, new uintptr) bool {
+	if new <= old {
+		return false
+	}
+	growth := new - old
+	if t.Bytes != 0 && growth > t.Bytes {
+		return true
+	}
+	if t.Percent != 0 && old != 0 && float64(growth)/float64(old)*100 > t.Percent {
+		return true
+	}
+	return false
+}
+
+// Thresholds configures CheckBaseline.
+type Thresholds struct {
+	Total   Tolerance            // limit for the total size
+	Default Tolerance            // limit for types not listed in Types
+	Types   map[string]Tolerance // per-type limits, keyed by type name
+}
+
+// Violation is a single exceeded threshold.
+type Violation struct {
+	Type     string // type name, empty for the total
+	Old, New uintptr
+	Limit    Tolerance
+}
+
+func (v Violation) String() string {
+	name := v.Type
+	if name == "" {
+		name = "total"
+	}
+	return fmt.Sprintf("%s grew from %s to %s", name, HumanSize(v.Old), HumanSize(v.New))
+}
+
+// RegressionError is returned by CheckBaseline when thresholds are exceeded.
+type RegressionError struct {
+	Violations []Violation
+}
+
+func (err *RegressionError) Error() string {
+	msgs := make([]string, len(err.Violations))
+	for i, v := range err.Violations {
+		msgs[i] = v.String()
+	}
+	return "memory budget exceeded: " + strings.Join(msgs, "; ")
+}
+
+// CheckBaseline compares current against a baseline snapshot. It returns a
+// *RegressionError if the total or any type grew beyond the given thresholds.
+func CheckBaseline(baseline, current Snapshot, th Thresholds) error {
+	var err RegressionError
+	if th.Total.exceeded(baseline.Total, current.Total) {
+		err.Violations = append(err.Violations, Violation{Old: baseline.Total, New: current.Total, Limit: th.Total})
+	}
+	for _, name := range current.TypeNames() {
+		limit, ok := th.Types[name]
+		if !ok {
+			limit = th.Default
+		}
+		old, new := baseline.ByType[name].Total, current.ByType[name].Total
+		if limit.exceeded(old, new) {
+			err.Violations = append(err.Violations, Violation{Type: name, Old: old, New: new, Limit: limit})
+		}
+	}
+	if len(err.Violations) > 0 {
+		return &err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package memsize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckBaseline(t *testing.T) {
+	baseline := Snapshot{
+		Total: 1000,
+		ByType: map[string]TypeSize{
+			"a": {Total: 600},
+			"b": {Total: 400},
+		},
+	}
+	current := Snapshot{
+		Total: 1300,
+		ByType: map[string]TypeSize{
+			"a": {Total: 650},
+			"b": {Total: 550},
+			"c": {Total: 100},
+		},
+	}
+	tests := []struct {
+		name string
+		th   Thresholds
+		want []Violation
+	}{
+		{
+			name: "no_limits",
+		},
+		{
+			name: "total_bytes",
+			th:   Thresholds{Total: Tolerance{Bytes: 200}},
+			want: []Violation{{Old: 1000, New: 1300, Limit: Tolerance{Bytes: 200}}},
+		},
+		{
+			name: "total_percent_ok",
+			th:   Thresholds{Total: Tolerance{Percent: 30}},
+		},
+		{
+			name: "default_percent",
+			th:   Thresholds{Default: Tolerance{Percent: 10}},
+			want: []Violation{{Type: "b", Old: 400, New: 550, Limit: Tolerance{Percent: 10}}},
+		},
+		{
+			name: "default_bytes_new_type",
+			th:   Thresholds{Default: Tolerance{Bytes: 60}},
+			want: []Violation{
+				{Type: "b", Old: 400, New: 550, Limit: Tolerance{Bytes: 60}},
+				{Type: "c", Old: 0, New: 100, Limit: Tolerance{Bytes: 60}},
+			},
+		},
+		{
+			name: "type_override",
+			th: Thresholds{
+				Default: Tolerance{Percent: 10},
+				Types:   map[string]Tolerance{"b": {Percent: 50}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckBaseline(baseline, current, test.th)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			rerr, ok := err.(*RegressionError)
+			if !ok {
+				t.Fatalf("wrong error %v, want *RegressionError", err)
+			}
+			if !reflect.DeepEqual(rerr.Violations, test.want) {
+				t.Fatalf("wrong violations:\ngot  %v\nwant %v", rerr.Violations, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package memsize
+
+import (
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ReportOptions configures AppendReport.
+type ReportOptions struct {
+	// MaxTypes limits the number of types in the report. Only the largest
+	// types are included. Zero means no limit.
+	MaxTypes int
+	// ByKind renders the totals per kind (see Sizes.ByKind) instead of the
+	// types. Each line shows the size and its percentage of the total.
+	ByKind bool
+	// ByScanCost orders the types by their number of pointer words (see
+	// Sizes.TypesByScanCost) instead of their size. Each line shows the count, the
+	// pointer words and their percentage of all pointer words.
+	ByScanCost bool
+	// MaxNameWidth limits the width of the type name column. Longer names are
+	// shortened by replacing their middle with "...". Zero means no limit.
+	MaxNameWidth int
+	// Legend appends a list of the shortened type names and the full names they
+	// stand for to the report.
+	Legend bool
+}
+
+// AppendReport renders the same table as Report into buf and returns the extended
+// buffer. Options other than MaxTypes change the table. It does not allocate if
+// buf has enough capacity, which makes it suitable for rendering reports often.
+//
+// Distinct types with the same name, e.g. types declared inside different
+// functions of a package, are shown on separate lines. Report merges them into one
+// line because snapshots identify types by name.
+//
+// To avoid allocating, types are ordered by repeated selection. This takes time
+// quadratic in the number of types. Use MaxTypes to bound it for large results.
+func (s Sizes) AppendReport(buf []byte, opts ReportOptions) []byte {
+	if opts.ByKind {
+		return s.appendKindReport(buf)
+	}
+	if opts.ByScanCost {
+		return s.appendScanCostReport(buf, opts)
+	}
+	var (
+		ntypes  = len(s.ByType)
+		count   uintptr
+		maxlen  int
+		maxcnt  = 0
+		maxsz   = 0
+		scratch [32]byte
+		// Widths of the external resource columns, see s.nextResource.
+		resw   [8]int
+		maxres = resw[:0]
+	)
+	if len(s.External) > len(resw) {
+		maxres = make([]int, 0, len(s.External))
+	}
+	for res, ok := s.nextResource("", false); ok; res, ok = s.nextResource(res, true) {
+		maxres = append(maxres, 0)
+	}
+	if opts.MaxTypes > 0 && opts.MaxTypes < ntypes {
+		ntypes = opts.MaxTypes
+	}
+	var estimated bool
+	for _, ts := range s.ByType {
+		count += ts.Count
+		estimated = estimated || !ts.Exact()
+	}
+	// Compute column widths.
+	measure := func(name string, count, total uintptr, estimated bool, external map[string]uint64) {
+		if n := nameWidth(name, opts.MaxNameWidth); n > maxlen {
+			maxlen = n
+		}
+		if n := len(strconv.AppendUint(scratch[:0], uint64(count), 10)); n > maxcnt {
+			maxcnt = n
+		}
+		n := len(appendHumanSize(scratch[:0], total))
+		if estimated {
+			n += len(estimatedMark)
+		}
+		if n > maxsz {
+			maxsz = n
+		}
+		i := 0
+		for res, ok := s.nextResource("", false); ok; res, ok = s.nextResource(res, true) {
+			n := len(res) + 1 + len(strconv.AppendUint(scratch[:0], external[res], 10))
+			if n > maxres[i] {
+				maxres[i] = n
+			}
+			i++
+		}
+	}
+	measure("ALL", count, s.Total, estimated, s.External)
+	var last *TypeSize
+	var lastType reflect.Type
+	for i := 0; i < ntypes; i++ {
+		lastType, last = s.nextReportType(lastType, last)
+		measure(lastType.String(), last.Count, last.Total, !last.Exact(), last.External)
+	}
+
+	// Render lines.
+	line := func(buf []byte, name string, count, total uintptr, estimated bool, external map[string]uint64) []byte {
+		buf = appendName(buf, name, opts.MaxNameWidth)
+		buf = appendSpaces(buf, maxlen-nameWidth(name, opts.MaxNameWidth))
+		c := strconv.AppendUint(scratch[:0], uint64(count), 10)
+		buf = appendSpaces(buf, 2+maxcnt-len(c))
+		buf = append(buf, c...)
+		h := scratch[:0]
+		if estimated {
+			h = append(h, estimatedMark...)
+		}
+		h = appendHumanSize(h, total)
+		buf = appendSpaces(buf, 2+maxsz-len(h))
+		buf = append(buf, h...)
+		i := 0
+		for res, ok := s.nextResource("", false); ok; res, ok = s.nextResource(res, true) {
+			c := strconv.AppendUint(scratch[:0], external[res], 10)
+			buf = appendSpaces(buf, 2+maxres[i]-len(res)-1-len(c))
+			buf = append(buf, res...)
+			buf = append(buf, '=')
+			buf = append(buf, c...)
+			i++
+		}
+		return append(buf, '\n')
+	}
+	buf = line(buf, "ALL", count, s.Total, estimated, s.External)
+	last, lastType = nil, nil
+	for i := 0; i < ntypes; i++ {
+		lastType, last = s.nextReportType(lastType, last)
+		buf = line(buf, lastType.String(), last.Count, last.Total, !last.Exact(), last.External)
+	}
+	if opts.Legend {
+		buf = s.appendLegend(buf, opts, ntypes, s.nextReportType)
+	}
+	return buf
+}
+
+// elision replaces the middle of shortened type names.
+const elision = "..."
+
+// shortName returns the beginning and end of name which are shown in a column of
+// the given width. The name is shortened if it is wider than the column.
+func shortName(name string, width int) (head, tail string, short bool) {
+	if width <= 0 || len(name) <= width {
+		return name, "", false
+	}
+	keep := width - len(elision)
+	if keep < 2 {
+		keep = 2
+	}
+	h, t := (keep+1)/2, len(name)-keep/2
+	// Don't split characters.
+	for h > 0 && !utf8.RuneStart(name[h]) {
+		h--
+	}
+	for t < len(name) && !utf8.RuneStart(name[t]) {
+		t++
+	}
+	return name[:h], name[t:], true
+}
+
+// nameWidth returns the width of name in a column of the given maximum width.
+func nameWidth(name string, width int) int {
+	head, tail, short := shortName(name, width)
+	if !short {
+		return len(name)
+	}
+	return len(head) + len(elision) + len(tail)
+}
+
+// appendName appends name, shortened to the given width.
+func appendName(buf []byte, name string, width int) []byte {
+	head, tail, short := shortName(name, width)
+	buf = append(buf, head...)
+	if short {
+		buf = append(buf, elision...)
+		buf = append(buf, tail...)
+	}
+	return buf
+}
+
+// appendLegend appends the full names of the types which were shortened in the
+// report. The types are visited in the order of the report using next.
+func (s Sizes) appendLegend(buf []byte, opts ReportOptions, ntypes int, next func(reflect.Type, *TypeSize) (reflect.Type, *TypeSize)) []byte {
+	var (
+		last     *TypeSize
+		lastType reflect.Type
+		header   bool
+	)
+	for i := 0; i < ntypes; i++ {
+		lastType, last = next(lastType, last)
+		name := lastType.String()
+		if _, _, short := shortName(name, opts.MaxNameWidth); !short {
+			continue
+		}
+		if !header {
+			buf = append(buf, '\n')
+			header = true
+		}
+		buf = appendName(buf, name, opts.MaxNameWidth)
+		buf = append(buf, " = "...)
+		buf = append(buf, name...)
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+// nextReportType returns the largest type that sorts after prev in report order.
+// Types are ordered by decreasing total size, then by name.
+func (s Sizes) nextReportType(prevType reflect.Type, prev *TypeSize) (reflect.Type, *TypeSize) {
+	var (
+		bestType reflect.Type
+		best     *TypeSize
+	)
+	for typ, ts := range s.ByType {
+		if prev != nil && !reportLess(prevType, prev, typ, ts) {
+			continue
+		}
+		if best == nil || reportLess(typ, ts, bestType, best) {
+			bestType, best = typ, ts
+		}
+	}
+	return bestType, best
+}
+
+func reportLess(t1 reflect.Type, ts1 *TypeSize, t2 reflect.Type, ts2 *TypeSize) bool {
+	if ts1.Total != ts2.Total {
+		return ts1.Total > ts2.Total
+	}
+	if n1, n2 := t1.String(), t2.String(); n1 != n2 {
+		return n1 < n2
+	}
+	// Distinct types with the same name. Order them by identity to make
+	// the order total.
+	return reflect.ValueOf(t1).Pointer() < reflect.ValueOf(t2).Pointer()
+}
+
+// nextResource returns the name of the external resource following prev in
+// alphabetical order. When after is false, it returns the first resource.
+func (s Sizes) nextResource(prev string, after bool) (string, bool) {
+	var (
+		best  string
+		found bool
+	)
+	for res := range s.External {
+		if after && res <= prev {
+			continue
+		}
+		if !found || res < best {
+			best, found = res, true
+		}
+	}
+	return best, found
+}
+
+// appendHumanSize is the allocation-free version of HumanSize.
+func appendHumanSize(buf []byte, bytes uintptr) []byte {
+	switch {
+	case bytes < 1024:
+		buf = strconv.AppendUint(buf, uint64(bytes), 10)
+		return append(buf, " B"...)
+	case bytes < 1024*1024:
+		buf = strconv.AppendFloat(buf, float64(bytes)/1024, 'f', 3, 64)
+		return append(buf, " KB"...)
+	default:
+		buf = strconv.AppendFloat(buf, float64(bytes)/1024/1024, 'f', 3, 64)
+		return append(buf, " MB"...)
+	}
+}
+
+func appendSpaces(buf []byte, n int) []byte {
+	for ; n > 0; n-- {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
@@ -0,0 +1,167 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendReport(t *testing.T) {
+	v := &structiface{s: &struct16{}, x: make([]byte, 5)}
+	sizes := Scan(v)
+	report := string(sizes.AppendReport(nil, ReportOptions{}))
+	if want := sizes.Report(); report != want {
+		t.Fatalf("AppendReport output differs from Report:\n%s\nwant:\n%s", report, want)
+	}
+
+	top := string(sizes.AppendReport(nil, ReportOptions{MaxTypes: 1}))
+	lines := strings.Split(strings.TrimSpace(top), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "memsize.structiface") {
+		t.Fatalf("wrong report with MaxTypes 1:\n%s", top)
+	}
+
+	buf := make([]byte, 0, 4096)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf = sizes.AppendReport(buf[:0], ReportOptions{})
+	})
+	if allocs != 0 {
+		t.Fatalf("AppendReport allocated %v times", allocs)
+	}
+}
+
+func TestAppendReportExternal(t *testing.T) {
+	// Types of equal size and external resources.
+	sizes := Sizes{
+		Total: 48,
+		ByType: map[reflect.Type]*TypeSize{
+			reflect.TypeOf(structptr{}): {Total: 16, Count: 1, External: map[string]uint64{"fd": 3}},
+			reflect.TypeOf(struct16{}):  {Total: 16, Count: 2, External: map[string]uint64{"mmap": 4096}},
+			reflect.TypeOf(uint64(0)):   {Total: 16, Count: 2},
+		},
+		External: map[string]uint64{"fd": 3, "mmap": 4096},
+	}
+	want := sizes.Report()
+	for i := 0; i < 10; i++ {
+		if report := string(sizes.AppendReport(nil, ReportOptions{})); report != want {
+			t.Fatalf("AppendReport output differs from Report:\n%s\nwant:\n%s", report, want)
+		}
+	}
+	if !strings.Contains(want, "mmap=4096") {
+		t.Fatalf("report lacks resource column:\n%s", want)
+	}
+	buf := make([]byte, 0, 4096)
+	if allocs := testing.AllocsPerRun(10, func() { buf = sizes.AppendReport(buf[:0], ReportOptions{}) }); allocs != 0 {
+		t.Fatalf("AppendReport allocated %v times", allocs)
+	}
+}
+
+func TestAppendReportSameName(t *testing.T) {
+	t1 := reflect.TypeOf(func() interface{} { type T struct{ a, b int64 }; return T{} }())
+	t2 := reflect.TypeOf(func() interface{} { type T struct{ a int64 }; return T{} }())
+	if t1.String() != t2.String() {
+		t.Fatalf("type names differ: %v, %v", t1, t2)
+	}
+	sizes := Sizes{
+		Total: 24,
+		ByType: map[reflect.Type]*TypeSize{
+			t1: {Total: 16, Count: 1},
+			t2: {Total: 8, Count: 1},
+		},
+	}
+	name := t1.String()
+	if report := string(sizes.AppendReport(nil, ReportOptions{})); strings.Count(report, name) != 2 {
+		t.Errorf("AppendReport doesn't list both types:\n%s", report)
+	}
+	if report := sizes.Report(); strings.Count(report, name) != 1 {
+		t.Errorf("Report doesn't merge types:\n%s", report)
+	}
+	if ts := sizes.Snapshot().ByType[name]; ts.Count != 2 || ts.Total != 24 {
+		t.Errorf("wrong merged snapshot entry %+v", ts)
+	}
+}
+
+func TestReportEstimated(t *testing.T) {
+	type node struct{ next *node }
+	var head *node
+	for i := 0; i < 4; i++ {
+		head = &node{next: head}
+	}
+	sizes := ScanWithOptions(&head, Options{MaxPerType: map[reflect.Type]int{reflect.TypeOf(node{}): 2}})
+	ts := sizes.ByType[reflect.TypeOf(node{})]
+	if ts.Exact() || ts.SampledFraction() != 2.0/4.0 {
+		t.Fatalf("wrong confidence: exact %v, sampled fraction %v", ts.Exact(), ts.SampledFraction())
+	}
+	report := string(sizes.AppendReport(nil, ReportOptions{}))
+	if want := sizes.Report(); report != want {
+		t.Fatalf("AppendReport output differs from Report:\n%s\nwant:\n%s", report, want)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(report), "\n") {
+		estimated := strings.HasPrefix(line, "ALL") || strings.HasPrefix(line, "memsize.node")
+		if strings.Contains(line, "~") != estimated {
+			t.Errorf("wrong estimate mark: %q", line)
+		}
+	}
+}
+
+func TestReportByScanCost(t *testing.T) {
+	type blob struct{ data [256]byte }
+	type ptrs struct{ a, b, c *blob }
+	v := &struct {
+		big   *blob
+		links []ptrs
+	}{big: &blob{}, links: make([]ptrs, 4)}
+	sizes := Scan(v)
+	types := sizes.TypesByScanCost()
+	if types[0] != reflect.TypeOf(*v) {
+		t.Errorf("wrong type with highest scan cost: %v", types[0])
+	}
+	if types[len(types)-1] != reflect.TypeOf(blob{}) {
+		t.Errorf("wrong type with lowest scan cost: %v", types[len(types)-1])
+	}
+	report := string(sizes.AppendReport(nil, ReportOptions{ByScanCost: true}))
+	lines := strings.Split(strings.TrimSpace(report), "\n")
+	if len(lines) != 1+len(types) || !strings.HasPrefix(lines[0], "ALL") || !strings.HasSuffix(lines[0], "100%") {
+		t.Fatalf("wrong report:\n%s", report)
+	}
+	if !strings.HasPrefix(lines[len(lines)-1], "memsize.blob") || !strings.HasSuffix(lines[len(lines)-1], " 0 ptrs    0%") {
+		t.Errorf("wrong last line %q", lines[len(lines)-1])
+	}
+	buf := make([]byte, 0, 4096)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf = sizes.AppendReport(buf[:0], ReportOptions{ByScanCost: true})
+	})
+	if allocs != 0 {
+		t.Fatalf("AppendReport allocated %v times", allocs)
+	}
+}
+
+func TestReportMaxNameWidth(t *testing.T) {
+	type long struct {
+		first, second, third *struct16
+		fourth               []string
+	}
+	v := &long{first: &struct16{}}
+	sizes := Scan(&struct{ p *long }{v})
+	opts := ReportOptions{MaxNameWidth: 20, Legend: true}
+	report := string(sizes.AppendReport(nil, opts))
+	table, legend := report, ""
+	if i := strings.Index(report, "\n\n"); i >= 0 {
+		table, legend = report[:i+1], report[i+2:]
+	}
+	for _, line := range strings.Split(strings.TrimSpace(table), "\n") {
+		if name := strings.Fields(line)[0]; len(name) > 20 {
+			t.Errorf("name %q is wider than 20", name)
+		}
+	}
+	name := reflect.TypeOf(struct{ p *long }{}).String()
+	short := string(appendName(nil, name, 20))
+	if len(short) != 20 || !strings.HasPrefix(short, name[:9]) || !strings.HasSuffix(short, name[len(name)-8:]) {
+		t.Errorf("wrong short name %q", short)
+	}
+	if want := short + " = " + name + "\n"; legend != want {
+		t.Errorf("wrong legend %q, want %q", legend, want)
+	}
+	if !strings.Contains(table, "memsize.long") || !strings.Contains(table, "memsize.struct16") {
+		t.Errorf("short names were shortened:\n%s", table)
+	}
+}
@@ -0,0 +1,77 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+)
+
+// RetainStats summarizes the values of a group of types and the memory retained
+// through them.
+type RetainStats struct {
+	Count uintptr // number of values
+	// Retained is the memory of the values and of everything reachable through
+	// them which wasn't counted before.
+	Retained uintptr
+	// MaxDepth is the largest number of values of the group which were nested
+	// in each other.
+	MaxDepth int
+}
+
+// retainGroup identifies types which are summarized in RetainStats.
+type retainGroup uint8
+
+const (
+	groupNone retainGroup = iota
+	groupContext
+	groupTimer
+	numRetainGroups
+)
+
+// retainGroupOf determines the retain group of a struct type.
+//
+// The context implementations (cancelCtx, timerCtx, valueCtx, ...) hold their
+// parent as a plain interface field, so their chains are traversed like any other
+// value. Timers created by time.AfterFunc retain their function, which is hidden in
+// the runtime part of the timer since Go 1.23 and found by scanTimer. Its captured
+// variables can't be traversed, see scanFunc.
+func retainGroupOf(typ reflect.Type) retainGroup {
+	switch typ.PkgPath() {
+	case "context":
+		if strings.HasSuffix(typ.Name(), "Ctx") {
+			return groupContext
+		}
+	case "time":
+		if typ.Name() == "Timer" || typ.Name() == "Ticker" {
+			return groupTimer
+		}
+	}
+	return groupNone
+}
+
+func (s *Sizes) retainStats(g retainGroup) *RetainStats {
+	if g == groupContext {
+		return &s.Contexts
+	}
+	return &s.Timers
+}
+
+// enterGroup is called before scanning a value of the given group. It returns the
+// total size counted so far.
+func (c *scanState) enterGroup(g retainGroup) uintptr {
+	c.groupDepth[g]++
+	if st := c.s.retainStats(g); c.groupDepth[g] > st.MaxDepth {
+		st.MaxDepth = c.groupDepth[g]
+	}
+	return c.s.Total
+}
+
+// leaveGroup is called after a value of the given group has been counted.
+// The retained memory is added for the outermost value only.
+func (c *scanState) leaveGroup(g retainGroup, start uintptr) {
+	c.groupDepth[g]--
+	st := c.s.retainStats(g)
+	st.Count++
+	if c.groupDepth[g] == 0 {
+		st.Retained += c.s.Total - start
+	}
+}
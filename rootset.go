@@ -0,0 +1,81 @@
+package memsize
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// RootSet is a registry of named values to be scanned. It is safe for concurrent use.
+// The zero value is an empty set, ready to use.
+type RootSet struct {
+	mu     sync.Mutex
+	roots  map[string]interface{}
+	quotas map[string]uintptr
+}
+
+// Add registers v under the given name, replacing any previous value of that name.
+// The value must be a non-nil pointer.
+func (rs *RootSet) Add(name string, v interface{}) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic("root must be non-nil pointer")
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.roots == nil {
+		rs.roots = make(map[string]interface{})
+	}
+	rs.roots[name] = v
+}
+
+// Remove deletes the root of the given name and its quota.
+func (rs *RootSet) Remove(name string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.roots, name)
+	delete(rs.quotas, name)
+}
+
+// SetQuota sets the number of bytes the root of the given name may use. WatchRoots
+// emits a QuotaExceeded event when the root's total goes above its quota. A quota
+// of zero removes the quota. The quota is kept when the root is replaced by Add.
+func (rs *RootSet) SetQuota(name string, quota uintptr) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if quota == 0 {
+		delete(rs.quotas, name)
+		return
+	}
+	if rs.quotas == nil {
+		rs.quotas = make(map[string]uintptr)
+	}
+	rs.quotas[name] = quota
+}
+
+// Quota returns the quota of the given root, or zero if it has no quota.
+func (rs *RootSet) Quota(name string) uintptr {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.quotas[name]
+}
+
+// Get returns the root of the given name.
+func (rs *RootSet) Get(name string) (interface{}, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	v, ok := rs.roots[name]
+	return v, ok
+}
+
+// Names returns the names of all roots in sorted order.
+func (rs *RootSet) Names() []string {
+	rs.mu.Lock()
+	names := make([]string, 0, len(rs.roots))
+	for name := range rs.roots {
+		names = append(names, name)
+	}
+	rs.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,55 @@
+package memsize
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRootSet(t *testing.T) {
+	var (
+		rs RootSet
+		a  = struct16{}
+		b  = []byte{1, 2, 3}
+	)
+	rs.Add("b", &b)
+	rs.Add("a", &a)
+	if names := rs.Names(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("wrong names: %q", names)
+	}
+	if v, ok := rs.Get("a"); !ok || v != &a {
+		t.Fatalf("wrong value for root a: %v", v)
+	}
+	rs.Remove("a")
+	if _, ok := rs.Get("a"); ok {
+		t.Fatal("root a still present after Remove")
+	}
+
+	var buf bytes.Buffer
+	dumpRoots(&rs, &buf)
+	if !strings.HasPrefix(buf.String(), `memsize: root "b"`) {
+		t.Fatalf("wrong dump output:\n%s", buf.String())
+	}
+}
+
+func TestRootSetQuota(t *testing.T) {
+	var (
+		rs RootSet
+		a  = struct16{}
+	)
+	rs.SetQuota("a", 100)
+	rs.Add("a", &a)
+	if q := rs.Quota("a"); q != 100 {
+		t.Fatalf("quota %d, want 100", q)
+	}
+	rs.SetQuota("a", 0)
+	if q := rs.Quota("a"); q != 0 {
+		t.Fatalf("quota %d after removing it", q)
+	}
+	rs.SetQuota("a", 50)
+	rs.Remove("a")
+	if q := rs.Quota("a"); q != 0 {
+		t.Fatalf("quota %d after removing the root", q)
+	}
+}
@@ -1,11 +1,30 @@
 package memsize
 
-import "unsafe"
+import (
+	"errors"
 
-var _ = unsafe.Pointer(nil)
+	"github.com/fjl/memsize/internal/runtimefunc"
+)
 
-//go:linkname startTheWorld runtime.startTheWorld
-func startTheWorld()
+// These report the runtime internals available on this platform and Go release.
+const (
+	haveChanbuf   = runtimefunc.HaveChanbuf
+	haveInternals = runtimefunc.HaveInternals
+	haveAddrClass = runtimefunc.HaveAddrClass
+)
 
-//go:linkname chanbuf runtime.chanbuf
-func chanbuf(ch unsafe.Pointer, i uint) unsafe.Pointer
+// ErrUnsupportedGoVersion is returned by TryScan when memsize doesn't know the
+// runtime internals of the Go release the program was built with, or when the
+// program was built for Go 1.23+ without the memsize_stw tag.
+var ErrUnsupportedGoVersion = errors.New("memsize: unsupported Go version")
+
+// TryScan is like ScanWithOptions, but returns ErrUnsupportedGoVersion instead of
+// scanning when the world can't be stopped in the build of the program. Scan and
+// ScanWithOptions still work in that case, but behave like the portable version:
+// other goroutines keep running during the scan.
+func TryScan(v interface{}, opts Options) (Sizes, error) {
+	if !runtimefunc.Supported {
+		return Sizes{}, ErrUnsupportedGoVersion
+	}
+	return ScanWithOptions(v, opts), nil
+}
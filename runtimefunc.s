@@ -1 +0,0 @@
-// This file is required to make stub function declarations work.
@@ -0,0 +1,101 @@
+package memsize
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// TypesByScanCost returns the types of the result ordered by decreasing number of
+// pointer words (see TypeSize.PointerWords). The garbage collector has to visit
+// every pointer word when marking, so this ranking identifies the types causing
+// most GC work, which are not necessarily the largest types.
+func (s Sizes) TypesByScanCost() []reflect.Type {
+	types := make([]reflect.Type, 0, len(s.ByType))
+	for typ := range s.ByType {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return scanCostLess(types[i], s.ByType[types[i]], types[j], s.ByType[types[j]])
+	})
+	return types
+}
+
+func scanCostLess(t1 reflect.Type, ts1 *TypeSize, t2 reflect.Type, ts2 *TypeSize) bool {
+	if ts1.PointerWords != ts2.PointerWords {
+		return ts1.PointerWords > ts2.PointerWords
+	}
+	return reportLess(t1, ts1, t2, ts2)
+}
+
+// nextScanCostType returns the type following prev in scan cost order.
+func (s Sizes) nextScanCostType(prevType reflect.Type, prev *TypeSize) (reflect.Type, *TypeSize) {
+	var (
+		bestType reflect.Type
+		best     *TypeSize
+	)
+	for typ, ts := range s.ByType {
+		if prev != nil && !scanCostLess(prevType, prev, typ, ts) {
+			continue
+		}
+		if best == nil || scanCostLess(typ, ts, bestType, best) {
+			bestType, best = typ, ts
+		}
+	}
+	return bestType, best
+}
+
+// appendScanCostReport renders the scan cost table of AppendReport.
+func (s Sizes) appendScanCostReport(buf []byte, opts ReportOptions) []byte {
+	var (
+		scratch [32]byte
+		ntypes  = len(s.ByType)
+		count   uintptr
+		words   uintptr
+		maxlen  = len("ALL")
+		maxcnt  int
+		maxwds  int
+	)
+	if opts.MaxTypes > 0 && opts.MaxTypes < ntypes {
+		ntypes = opts.MaxTypes
+	}
+	for typ, ts := range s.ByType {
+		count += ts.Count
+		words += ts.PointerWords
+		if n := nameWidth(typ.String(), opts.MaxNameWidth); n > maxlen {
+			maxlen = n
+		}
+	}
+	maxcnt = len(strconv.AppendUint(scratch[:0], uint64(count), 10))
+	maxwds = len(strconv.AppendUint(scratch[:0], uint64(words), 10))
+	line := func(buf []byte, name string, count, w uintptr) []byte {
+		buf = appendName(buf, name, opts.MaxNameWidth)
+		buf = appendSpaces(buf, maxlen-nameWidth(name, opts.MaxNameWidth))
+		c := strconv.AppendUint(scratch[:0], uint64(count), 10)
+		buf = appendSpaces(buf, 2+maxcnt-len(c))
+		buf = append(buf, c...)
+		c = strconv.AppendUint(scratch[:0], uint64(w), 10)
+		buf = appendSpaces(buf, 2+maxwds-len(c))
+		buf = append(buf, c...)
+		buf = append(buf, " ptrs"...)
+		pct := uint64(100)
+		if words > 0 {
+			pct = uint64(w) * 100 / uint64(words)
+		}
+		p := strconv.AppendUint(scratch[:0], pct, 10)
+		buf = appendSpaces(buf, 5-len(p))
+		buf = append(buf, p...)
+		return append(buf, "%\n"...)
+	}
+	buf = line(buf, "ALL", count, words)
+	var last *TypeSize
+	var lastType reflect.Type
+	for i := 0; i < ntypes; i++ {
+		lastType, last = s.nextScanCostType(lastType, last)
+		buf = line(buf, lastType.String(), last.Count, last.PointerWords)
+	}
+	if opts.Legend {
+		buf = s.appendLegend(buf, opts, ntypes, s.nextScanCostType)
+	}
+	return buf
+}
@@ -0,0 +1,41 @@
+package memsize
+
+import "context"
+
+// Scanner performs repeated scans. Unlike Scan, it reuses the internal data
+// structures of the scanner across scans, which reduces allocation (and thus GC
+// pressure) right before the world is stopped.
+//
+// Between scans, a Scanner retains the layout information of all types it has
+// scanned and the set of visited memory. Of the exact set, up to 4 MB are kept
+// and the rest is released, so one large scan doesn't grow the retained memory
+// permanently. The Bloom filter used with Options.ApproxDedup is kept whole; its
+// size is fixed by the options.
+//
+// Scanner is not safe for concurrent use. The zero value is ready to use.
+type Scanner struct {
+	// Options configures the scans. It can be modified between scans.
+	Options Options
+
+	state *scanState
+}
+
+// NewScanner creates a scanner with the given options.
+func NewScanner(opts Options) *Scanner {
+	return &Scanner{Options: opts}
+}
+
+// Scan is like the Scan function, but uses the scanner's options.
+func (s *Scanner) Scan(v interface{}) Sizes {
+	return s.ScanContext(context.Background(), v)
+}
+
+// ScanContext is like the ScanContext function, but uses the scanner's options.
+func (s *Scanner) ScanContext(ctx context.Context, v interface{}) Sizes {
+	if s.state == nil {
+		s.state = newScanState(&s.Options)
+	} else {
+		s.state.reset(&s.Options)
+	}
+	return s.state.scanRoot(ctx, v)
+}
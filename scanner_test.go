@@ -0,0 +1,59 @@
+package memsize
+
+import (
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	var s Scanner
+	for _, approx := range []bool{false, true, false} {
+		s.Options.ApproxDedup = approx
+		s.Options.ApproxDedupBytes = 1024 * 1024
+		for _, test := range totalTests {
+			if (test.chanbuf && !haveChanbuf) || (approx && test.overlap) {
+				continue
+			}
+			if size := s.Scan(test.v); size.Total != test.want {
+				t.Errorf("%s (approx %t): total=%d, want %d", test.name, approx, size.Total, test.want)
+			}
+		}
+	}
+}
+
+type benchTree struct {
+	name  string
+	data  []byte
+	left  *benchTree
+	right *benchTree
+}
+
+func makeBenchTree(depth int) *benchTree {
+	if depth == 0 {
+		return nil
+	}
+	return &benchTree{
+		name:  "node",
+		data:  make([]byte, 32),
+		left:  makeBenchTree(depth - 1),
+		right: makeBenchTree(depth - 1),
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	tree := makeBenchTree(10)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Scan(tree)
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	var (
+		tree = makeBenchTree(10)
+		s    Scanner
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Scan(tree)
+	}
+}
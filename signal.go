@@ -0,0 +1,36 @@
+package memsize
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// EnableSignalDump installs a handler for the given signal. Whenever the process
+// receives the signal, all roots in the set are scanned and their reports are
+// written to w. This is similar to how the Go runtime dumps goroutine stacks on
+// SIGQUIT, and is useful for processes that don't provide an HTTP server.
+//
+// A typical choice for sig is syscall.SIGUSR2.
+func EnableSignalDump(sig os.Signal, roots *RootSet, w io.Writer) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+	go func() {
+		for range ch {
+			dumpRoots(roots, w)
+		}
+	}()
+}
+
+// dumpRoots scans all roots and writes a report for each of them to w.
+func dumpRoots(roots *RootSet, w io.Writer) {
+	for _, name := range roots.Names() {
+		v, ok := roots.Get(name)
+		if !ok {
+			continue // removed concurrently
+		}
+		sizes := Scan(v)
+		fmt.Fprintf(w, "memsize: root %q, total %s\n%s\n", name, HumanSize(sizes.Total), sizes.Report())
+	}
+}
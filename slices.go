@@ -0,0 +1,92 @@
+package memsize
+
+import (
+	"fmt"
+	"math/bits"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SliceStats summarizes the non-nil slices of one element type found by the scan.
+type SliceStats struct {
+	Count    uintptr
+	Len, Cap uintptr // sums over all slices
+	// Unused is the memory of the backing array elements beyond the slice length.
+	Unused uintptr
+	// Histogram counts the slices by length and capacity.
+	Histogram map[SliceShape]uintptr
+}
+
+// SliceShape is a bucket of the slice histogram. The length and capacity of each
+// slice are rounded up to the next power of two.
+type SliceShape struct {
+	Len, Cap uintptr
+}
+
+func (sh SliceShape) String() string {
+	return fmt.Sprintf("len<=%d cap<=%d", sh.Len, sh.Cap)
+}
+
+// roundPow2 rounds n up to the next power of two. Zero is kept as is.
+func roundPow2(n uintptr) uintptr {
+	if n <= 1 {
+		return n
+	}
+	return 1 << uint(bits.Len64(uint64(n-1)))
+}
+
+func (c *scanState) addSliceStats(v reflect.Value) {
+	if v.IsNil() {
+		return
+	}
+	etyp := v.Type().Elem()
+	ss := c.s.Slices[etyp]
+	if ss == nil {
+		ss = &SliceStats{Histogram: make(map[SliceShape]uintptr)}
+		c.s.Slices[etyp] = ss
+	}
+	l, cp := uintptr(v.Len()), uintptr(v.Cap())
+	ss.Count++
+	ss.Len += l
+	ss.Cap += cp
+	ss.Unused += (cp - l) * etyp.Size()
+	ss.Histogram[SliceShape{roundPow2(l), roundPow2(cp)}]++
+}
+
+// SliceReport returns the histograms recorded by Options.SliceHistograms, ordered
+// by decreasing unused capacity. Element types with a lot of unused capacity may
+// benefit from preallocation with the right size or clipping the slices after
+// they are built.
+func (s Sizes) SliceReport() string {
+	types := make([]reflect.Type, 0, len(s.Slices))
+	for typ := range s.Slices {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		si, sj := s.Slices[types[i]], s.Slices[types[j]]
+		if si.Unused != sj.Unused {
+			return si.Unused > sj.Unused
+		}
+		return types[i].String() < types[j].String()
+	})
+	var sb strings.Builder
+	for _, typ := range types {
+		ss := s.Slices[typ]
+		fmt.Fprintf(&sb, "[]%v  count %d  len %d  cap %d  unused %s\n", typ, ss.Count, ss.Len, ss.Cap, HumanSize(ss.Unused))
+		shapes := make([]SliceShape, 0, len(ss.Histogram))
+		for sh := range ss.Histogram {
+			shapes = append(shapes, sh)
+		}
+		sort.Slice(shapes, func(i, j int) bool {
+			if shapes[i].Cap != shapes[j].Cap {
+				return shapes[i].Cap < shapes[j].Cap
+			}
+			return shapes[i].Len < shapes[j].Len
+		})
+		for _, sh := range shapes {
+			fmt.Fprintf(&sb, "    %-28v %d\n", sh, ss.Histogram[sh])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,32 @@
+package memsize
+
+import (
+	"reflect"
+	"time"
+)
+
+// slowScan captures the traversal stack when a scan takes longer than
+// Options.SlowScan.
+type slowScan struct {
+	threshold time.Duration
+	start     time.Time
+	stack     []reflect.Type
+	captured  []reflect.Type
+	done      bool
+}
+
+func (ss *slowScan) push(typ reflect.Type) {
+	ss.stack = append(ss.stack, typ)
+}
+
+func (ss *slowScan) pop() {
+	ss.stack = ss.stack[:len(ss.stack)-1]
+}
+
+// check captures the stack once the threshold is exceeded.
+func (ss *slowScan) check(now time.Time) {
+	if !ss.done && now.Sub(ss.start) > ss.threshold {
+		ss.captured = append([]reflect.Type(nil), ss.stack...)
+		ss.done = true
+	}
+}
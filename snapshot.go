@@ -0,0 +1,192 @@
+package memsize
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Snapshot is a serializable form of Sizes. Types are identified by their name
+// as returned by reflect.Type.String. Distinct types which share the same name are
+// merged into a single entry.
+//
+// Snapshots can be encoded using encoding/json and encoding/gob.
+type Snapshot struct {
+	Total  uintptr             `json:"total"`
+	ByType map[string]TypeSize `json:"byType"`
+	// Ownership is the serialized form of Sizes.Ownership.
+	Ownership map[string]map[string]uintptr `json:"ownership,omitempty"`
+	// External holds the totals of external resources.
+	External map[string]uint64 `json:"external,omitempty"`
+	// ByKind holds the totals per kind, see Sizes.ByKind.
+	ByKind map[string]uintptr `json:"byKind,omitempty"`
+	// Cycles is the serialized form of Sizes.Cycles.
+	Cycles map[string]uintptr `json:"cycles,omitempty"`
+	// Partial is set when the scan was interrupted.
+	Partial bool `json:"partial,omitempty"`
+	// Goroutines holds the goroutine statistics of the scan.
+	Goroutines GoroutineStats `json:"goroutines"`
+	// Contexts and Timers are the retain summaries of the scan.
+	Contexts RetainStats `json:"contexts"`
+	Timers   RetainStats `json:"timers"`
+	// Metadata describes the program which produced the snapshot.
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// WeakReachable is the serialized form of Sizes.WeakReachable.
+	WeakReachable *Snapshot `json:"weakReachable,omitempty"`
+	// Unscannable is the serialized form of Sizes.Unscannable.
+	Unscannable map[string]BlockedStats `json:"unscannable,omitempty"`
+	// SlowScanPath holds the type names of Sizes.SlowScanPath.
+	SlowScanPath []string `json:"slowScanPath,omitempty"`
+}
+
+// Snapshot converts s to its serializable form.
+func (s Sizes) Snapshot() Snapshot {
+	snap := Snapshot{Total: s.Total, ByType: make(map[string]TypeSize, len(s.ByType)), Partial: s.Partial}
+	snap.Goroutines, snap.Contexts, snap.Timers = s.Goroutines, s.Contexts, s.Timers
+	snap.Metadata = s.Metadata
+	for resource, amount := range s.External {
+		if snap.External == nil {
+			snap.External = make(map[string]uint64, len(s.External))
+		}
+		snap.External[resource] = amount
+	}
+	for _, typ := range s.SlowScanPath {
+		snap.SlowScanPath = append(snap.SlowScanPath, typeName(typ))
+	}
+	if s.WeakReachable != nil {
+		weak := s.WeakReachable.Snapshot()
+		snap.WeakReachable = &weak
+	}
+	for kind, size := range s.ByKind() {
+		if snap.ByKind == nil {
+			snap.ByKind = make(map[string]uintptr)
+		}
+		snap.ByKind[kind.String()] = size
+	}
+	for typ, n := range s.Cycles {
+		if snap.Cycles == nil {
+			snap.Cycles = make(map[string]uintptr, len(s.Cycles))
+		}
+		snap.Cycles[typeName(typ)] += n
+	}
+	for typ, bs := range s.Unscannable {
+		if snap.Unscannable == nil {
+			snap.Unscannable = make(map[string]BlockedStats, len(s.Unscannable))
+		}
+		e := snap.Unscannable[typeName(typ)]
+		e.Count += bs.Count
+		e.Bytes += bs.Bytes
+		snap.Unscannable[typeName(typ)] = e
+	}
+	for typ, ts := range s.ByType {
+		name := typeName(typ)
+		e := snap.ByType[name]
+		e.add(*ts)
+		snap.ByType[name] = e
+	}
+	if s.Ownership != nil {
+		snap.Ownership = make(map[string]map[string]uintptr, len(s.Ownership))
+		for parent, children := range s.Ownership {
+			pname := typeName(parent)
+			if snap.Ownership[pname] == nil {
+				snap.Ownership[pname] = make(map[string]uintptr, len(children))
+			}
+			for child, size := range children {
+				snap.Ownership[pname][typeName(child)] += size
+			}
+		}
+	}
+	return snap
+}
+
+// TypeNames returns the names of all types in the snapshot, ordered by decreasing total size.
+func (s Snapshot) TypeNames() []string {
+	names := make([]string, 0, len(s.ByType))
+	for name := range s.ByType {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := s.ByType[names[i]].Total, s.ByType[names[j]].Total
+		if ti != tj {
+			return ti > tj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Report returns a human-readable report. Sizes which include extrapolated memory
+// (see TypeSize.Exact) are marked with "~".
+func (s Snapshot) Report() string {
+	type typLine struct {
+		name      string
+		count     uintptr
+		total     uintptr
+		estimated bool
+		external  map[string]uint64
+	}
+	tab := []typLine{{"ALL", 0, s.Total, false, s.External}}
+	for _, typ := range s.ByType {
+		tab[0].count += typ.Count
+		tab[0].estimated = tab[0].estimated || !typ.Exact()
+	}
+	maxname := len(tab[0].name)
+	for name, s := range s.ByType {
+		line := typLine{name, s.Count, s.Total, !s.Exact(), s.External}
+		tab = append(tab, line)
+		if len(line.name) > maxname {
+			maxname = len(line.name)
+		}
+	}
+	types := tab[1:]
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].total != types[j].total {
+			return types[i].total > types[j].total
+		}
+		return types[i].name < types[j].name
+	})
+	resources := make([]string, 0, len(s.External))
+	for resource := range s.External {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 0, 0, ' ', tabwriter.AlignRight)
+	for _, line := range tab {
+		namespace := strings.Repeat(" ", maxname-len(line.name))
+		size := HumanSize(line.total)
+		if line.estimated {
+			size = estimatedMark + size
+		}
+		fmt.Fprintf(w, "%s%s\t  %v\t  %s\t", line.name, namespace, line.count, size)
+		for _, resource := range resources {
+			fmt.Fprintf(w, "  %s=%d\t", resource, line.external[resource])
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// String returns a one-line summary of the snapshot.
+func (s Snapshot) String() string {
+	str := fmt.Sprintf("total=%s types=%d", compactSize(s.Total), len(s.ByType))
+	if names := s.TypeNames(); len(names) > 0 {
+		str += fmt.Sprintf(" top=%s(%s)", names[0], compactSize(s.ByType[names[0]].Total))
+	}
+	return str
+}
+
+// compactSize is like HumanSize, but without a space between number and unit.
+func compactSize(bytes uintptr) string {
+	return strings.Replace(HumanSize(bytes), " ", "", 1)
+}
+
+// typeName is the key used for typ in snapshots.
+func typeName(typ reflect.Type) string {
+	return typ.String()
+}
@@ -0,0 +1,173 @@
+package memsize
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	v := &structptrslice{&structslice{s: []uint32{1, 2, 3}}}
+	snap := Scan(v).Snapshot()
+
+	want := Snapshot{
+		Total: sizeofWord + sizeofSlice + 3*4,
+		ByType: map[string]TypeSize{
+			"memsize.structptrslice": {Total: sizeofWord, Count: 1, Shallow: sizeofWord, PointerWords: 1, Edges: 1},
+			"memsize.structslice":    {Total: sizeofSlice + 3*4, Count: 1, Shallow: sizeofSlice, Referenced: 3 * 4, PointerWords: 1, Edges: 1},
+		},
+		ByKind:     map[string]uintptr{"struct": sizeofWord + sizeofSlice, "slice": 3 * 4},
+		Goroutines: snap.Goroutines,
+	}
+	if !reflect.DeepEqual(snap, want) {
+		t.Fatalf("wrong snapshot:\ngot  %+v %v\nwant %+v %v", snap.ByType, snap.ByKind, want.ByType, want.ByKind)
+	}
+	if names := snap.TypeNames(); !reflect.DeepEqual(names, []string{"memsize.structslice", "memsize.structptrslice"}) {
+		t.Fatalf("wrong type names: %q", names)
+	}
+
+	wantStr := fmt.Sprintf("total=%dB types=2 top=memsize.structslice(%dB)", want.Total, sizeofSlice+3*4)
+	if str := snap.String(); str != wantStr {
+		t.Fatalf("wrong summary %q, want %q", str, wantStr)
+	}
+	if str, want := (Snapshot{}).String(), "total=0B types=0"; str != want {
+		t.Fatalf("wrong summary of empty snapshot %q, want %q", str, want)
+	}
+
+	enc, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Snapshot
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec, snap) {
+		t.Fatalf("snapshot changed in JSON round trip:\ngot  %+v\nwant %+v", dec.ByType, snap.ByType)
+	}
+}
+
+func TestReportOrder(t *testing.T) {
+	snap := Snapshot{
+		Total: 24,
+		ByType: map[string]TypeSize{
+			"b": {Total: 8, Count: 1},
+			"a": {Total: 8, Count: 1},
+			"c": {Total: 8, Count: 1},
+		},
+	}
+	want := "" +
+		"ALL  3  24 B\n" +
+		"a    1   8 B\n" +
+		"b    1   8 B\n" +
+		"c    1   8 B\n"
+	for i := 0; i < 10; i++ {
+		if r := snap.Report(); r != want {
+			t.Fatalf("wrong report:\n%s\nwant:\n%s", r, want)
+		}
+	}
+}
+
+func TestDeltaReport(t *testing.T) {
+	prev := Snapshot{
+		Total: 300,
+		ByType: map[string]TypeSize{
+			"a": {Total: 100, Count: 1},
+			"b": {Total: 200, Count: 2},
+		},
+	}
+	cur := Snapshot{
+		Total: 500,
+		ByType: map[string]TypeSize{
+			"a": {Total: 50, Count: 1},
+			"c": {Total: 450, Count: 3},
+		},
+	}
+	want := "" +
+		"ALL  4  500 B  +1  +200 B\n" +
+		"c    3  450 B  +3  +450 B\n" +
+		"a    1   50 B  +0   -50 B\n" +
+		"b    0    0 B  -2  -200 B\n"
+	if r := cur.DeltaReport(prev, DeltaOptions{}); r != want {
+		t.Fatalf("wrong report:\n%s\nwant:\n%s", r, want)
+	}
+
+	r := cur.DeltaReport(prev, DeltaOptions{Color: true, Threshold: Tolerance{Percent: 100}})
+	lines := strings.Split(r, "\n")
+	if !strings.HasPrefix(lines[0], "ALL") {
+		t.Errorf("total growth below threshold is colored: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], ansiRed) || !strings.HasPrefix(lines[2], ansiGreen) {
+		t.Errorf("wrong colors:\n%q", r)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	v := &structptrslice{&structslice{s: []uint32{1, 2, 3}}}
+	if s := Scan(v); s.Metadata != nil {
+		t.Fatalf("metadata recorded without option: %v", s.Metadata)
+	}
+	before := time.Now()
+	s := ScanWithOptions(v, Options{Metadata: true})
+	m := s.Metadata
+	if m == nil {
+		t.Fatal("no metadata")
+	}
+	if m.GoVersion != runtime.Version() || m.GOOS != runtime.GOOS || m.GOARCH != runtime.GOARCH {
+		t.Errorf("wrong runtime metadata: %v", m)
+	}
+	if m.Time.Before(before.Truncate(time.Second)) || m.Time.After(time.Now()) {
+		t.Errorf("wrong time %v", m.Time)
+	}
+	if host, _ := os.Hostname(); m.Hostname != host {
+		t.Errorf("wrong hostname %q, want %q", m.Hostname, host)
+	}
+	if !m.SameBuild(m) {
+		t.Error("metadata is not the same build as itself")
+	}
+	var rs RootSet
+	rs.Add("v", v)
+	if res := ScanRoots(&rs, Options{Metadata: true}); res.Shared.Metadata == nil || res.ByRoot["v"].Metadata == nil {
+		t.Error("no metadata in ScanRoots result")
+	}
+
+	snap := s.Snapshot()
+	enc, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Snapshot
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec.Metadata, m) {
+		t.Fatalf("metadata changed in JSON round trip:\ngot  %+v\nwant %+v", dec.Metadata, m)
+	}
+}
+
+func TestMetadataDelta(t *testing.T) {
+	build := func(rev string) *Metadata {
+		return &Metadata{
+			GoVersion: "go1.22.1",
+			GOOS:      "linux",
+			GOARCH:    "amd64",
+			Build:     &BuildInfo{Path: "example.com/cmd/server", Version: "(devel)", Settings: map[string]string{"vcs.revision": rev}},
+		}
+	}
+	prev := Snapshot{Total: 100, Metadata: build("4f1c2d9e0b")}
+	cur := Snapshot{Total: 100, Metadata: build("4f1c2d9e0b")}
+	cur.Metadata.Hostname = "db1" // the host doesn't matter
+	if r := cur.DeltaReport(prev, DeltaOptions{}); strings.HasPrefix(r, "builds differ") {
+		t.Errorf("same build reported as different:\n%s", r)
+	}
+	cur.Metadata = build("77a0f31")
+	want := "builds differ: go1.22.1 linux/amd64 build=example.com/cmd/server@(devel) rev=4f1c2d9 -> go1.22.1 linux/amd64 build=example.com/cmd/server@(devel) rev=77a0f31\n"
+	if r := cur.DeltaReport(prev, DeltaOptions{}); !strings.HasPrefix(r, want) {
+		t.Errorf("wrong report:\n%s\nwant prefix:\n%s", r, want)
+	}
+}
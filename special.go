@@ -0,0 +1,103 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+)
+
+// specialKind identifies types which have built-in scan handling because
+// their contents are not visible to a plain reflect traversal.
+type specialKind uint8
+
+const (
+	specialNone          specialKind = iota
+	specialAtomicPointer             // sync/atomic.Pointer[T]
+	specialSyncMap                   // sync.Map (Go 1.24+)
+	specialSyncMapEntry              // sync.entry (before Go 1.20)
+	specialSyncPool                  // sync.Pool
+	specialWeakPointer               // weak.Pointer[T] (Go 1.24+)
+	specialTraverser                 // registered with RegisterTraverser
+	specialBuffer                    // bytes.Buffer etc., see Options.AttributeBuffers
+	specialTimer                     // time.Timer and time.Ticker (Go 1.23+)
+)
+
+// specialKindOf determines the special kind of a struct type.
+func specialKindOf(typ reflect.Type) specialKind {
+	if lookupTraverser(typ) != nil {
+		return specialTraverser
+	}
+	if isBufferType(typ) {
+		return specialBuffer
+	}
+	switch typ.PkgPath() {
+	case "sync/atomic":
+		if isAtomicPointer(typ) {
+			return specialAtomicPointer
+		}
+	case "sync":
+		if typ.Name() == "Map" && haveSyncMapTrie && isSyncMapTrie(typ) {
+			return specialSyncMap
+		}
+		if typ.Name() == "entry" && haveInternals && isSyncMapEntryPtr(typ) {
+			return specialSyncMapEntry
+		}
+		if typ.Name() == "Pool" && haveSyncPool {
+			return specialSyncPool
+		}
+	case "weak":
+		if haveInternals && isWeakPointer(typ) {
+			return specialWeakPointer
+		}
+	case "time":
+		if haveTimerArg && isTimerType(typ) {
+			return specialTimer
+		}
+	}
+	return specialNone
+}
+
+// isAtomicPointer reports whether typ is atomic.Pointer[T], which
+// stores the pointer as unsafe.Pointer.
+func isAtomicPointer(typ reflect.Type) bool {
+	return typ.PkgPath() == "sync/atomic" && strings.HasPrefix(typ.Name(), "Pointer[") && typ.NumField() > 0
+}
+
+// scanSpecial scans a value of a special type.
+func (c *scanState) scanSpecial(addr address, v reflect.Value, kind specialKind) uintptr {
+	switch kind {
+	case specialAtomicPointer:
+		return c.scanAtomicPointer(addr, v)
+	case specialSyncMap:
+		return c.scanSyncMap(addr, v)
+	case specialSyncMapEntry:
+		return c.scanSyncMapEntryPtr(v)
+	case specialSyncPool:
+		return c.scanSyncPool(addr, v)
+	case specialWeakPointer:
+		return c.scanWeakPointer(v)
+	case specialTraverser:
+		return c.scanTraverser(addr, v)
+	case specialTimer:
+		return c.scanTimer(addr, v)
+	default:
+		panic("unhandled special kind")
+	}
+}
+
+// scanAtomicPointer scans the target of an atomic.Pointer[T]. The struct is
+// defined as
+//
+//	type Pointer[T any] struct {
+//		_ [0]*T
+//		_ noCopy
+//		v unsafe.Pointer
+//	}
+//
+// The element type is taken from the first field.
+func (c *scanState) scanAtomicPointer(addr address, v reflect.Value) uintptr {
+	etyp := v.Type().Field(0).Type.Elem().Elem()
+	if p := atomicPointerTarget(v); p != nil {
+		c.scan(address(p), reflect.NewAt(etyp, p).Elem(), true)
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+//go:build go1.19
+// +build go1.19
+
+package memsize
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+func TestAtomic(t *testing.T) {
+	type holder struct {
+		val atomic.Value
+		ptr atomic.Pointer[struct16]
+	}
+	tests := []struct {
+		name string
+		v    func() *holder
+		want uintptr
+	}{
+		{
+			name: "empty",
+			v:    func() *holder { return new(holder) },
+			want: sizeofInterface + sizeofWord,
+		},
+		{
+			name: "value",
+			v: func() *holder {
+				h := new(holder)
+				h.val.Store(&struct16{})
+				return h
+			},
+			want: sizeofInterface + sizeofWord + 16,
+		},
+		{
+			name: "pointer",
+			v: func() *holder {
+				h := new(holder)
+				h.ptr.Store(&struct16{})
+				return h
+			},
+			want: sizeofInterface + sizeofWord + 16,
+		},
+		{
+			name: "pointer_shared",
+			v: func() *holder {
+				h := new(holder)
+				s := &struct16{}
+				h.val.Store(s)
+				h.ptr.Store(s)
+				return h
+			},
+			want: sizeofInterface + sizeofWord + 16,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sizes := Scan(test.v())
+			if sizes.Total != test.want {
+				t.Errorf("total=%d, want %d", sizes.Total, test.want)
+				t.Logf("\n%s", sizes.Report())
+			}
+		})
+	}
+}
+
+func TestAtomicPointerExcluded(t *testing.T) {
+	target := &struct16{}
+	start := uintptr(unsafe.Pointer(target))
+	v := new(atomic.Pointer[struct16])
+	v.Store(target)
+	sizes := ScanWithOptions(v, Options{ExcludeRanges: []AddressRange{{start, start + 1}}})
+	if ts := sizes.ByType[reflect.TypeOf(struct16{})]; ts != nil {
+		t.Errorf("excluded target counted: %+v", ts)
+	}
+	if sizes.Total != sizeofWord {
+		t.Errorf("total %d, want %d", sizes.Total, sizeofWord)
+	}
+}
@@ -0,0 +1,222 @@
+package memsize
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotStore persists snapshots in a directory, one JSON file per snapshot.
+// Old snapshots are removed according to the retention settings whenever a
+// snapshot is saved.
+type SnapshotStore struct {
+	dir    string
+	maxNum int
+	maxAge time.Duration
+	mu     sync.Mutex
+}
+
+// StoreOptions configures the retention of a SnapshotStore. Zero fields mean no
+// limit.
+type StoreOptions struct {
+	MaxSnapshots int           // number of snapshots kept
+	MaxAge       time.Duration // age of the oldest snapshot kept
+}
+
+// SeriesPoint is an element of the history of a type, see SnapshotStore.Series.
+type SeriesPoint struct {
+	Time time.Time
+	TypeSize
+}
+
+const (
+	storePrefix     = "memsize-"
+	storeSuffix     = ".json"
+	storeTimeFormat = "20060102T150405.000000000Z"
+)
+
+// OpenSnapshotStore opens the store in dir, creating the directory if necessary.
+func OpenSnapshotStore(dir string, opts StoreOptions) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &SnapshotStore{dir: dir, maxNum: opts.MaxSnapshots, maxAge: opts.MaxAge}, nil
+}
+
+// Record scans root and saves the result.
+func (st *SnapshotStore) Record(root interface{}, opts Options) error {
+	return st.Save(ScanWithOptions(root, opts).Snapshot(), time.Now())
+}
+
+// RecordEvery calls Record every interval until stop is called. Stop returns the
+// error of the last failed Record, if any.
+func (st *SnapshotStore) RecordEvery(root interface{}, interval time.Duration, opts Options) (stop func() error) {
+	ticker := time.NewTicker(interval)
+	return st.recordOn(root, ticker.C, ticker.Stop, opts)
+}
+
+// RecordAfterGC is like RecordEvery, but records after garbage collection cycles
+// (see GCTicker), at most once per minInterval.
+func (st *SnapshotStore) RecordAfterGC(root interface{}, minInterval time.Duration, opts Options) (stop func() error) {
+	ticker := NewGCTicker(minInterval)
+	return st.recordOn(root, ticker.C, ticker.Stop, opts)
+}
+
+// recordOn calls Record initially and for every tick until stop is called.
+func (st *SnapshotStore) recordOn(root interface{}, tick <-chan time.Time, stopTicker func(), opts Options) (stop func() error) {
+	var (
+		quit     = make(chan struct{})
+		done     = make(chan struct{})
+		stopOnce sync.Once
+		lastErr  error
+	)
+	go func() {
+		defer close(done)
+		defer stopTicker()
+		for {
+			if err := st.Record(root, opts); err != nil {
+				lastErr = err
+			}
+			select {
+			case <-tick:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() error {
+		stopOnce.Do(func() { close(quit) })
+		<-done
+		return lastErr
+	}
+}
+
+// Save stores snap as the snapshot taken at time t and applies the retention
+// settings.
+func (st *SnapshotStore) Save(snap Snapshot, t time.Time) error {
+	enc, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	// Write to a temporary file first, so readers never see partial snapshots.
+	file := st.file(t)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, enc, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return st.rotate(t)
+}
+
+// rotate removes the snapshots which are beyond the retention limits.
+func (st *SnapshotStore) rotate(now time.Time) error {
+	times, err := st.list()
+	if err != nil {
+		return err
+	}
+	for i, t := range times {
+		tooMany := st.maxNum > 0 && len(times)-i > st.maxNum
+		tooOld := st.maxAge > 0 && now.Sub(t) > st.maxAge
+		if !tooMany && !tooOld {
+			break
+		}
+		if err := os.Remove(st.file(t)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Times returns the times of all stored snapshots in ascending order.
+func (st *SnapshotStore) Times() ([]time.Time, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.list()
+}
+
+func (st *SnapshotStore) list() ([]time.Time, error) {
+	files, err := ioutil.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+	var times []time.Time
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, storePrefix) || !strings.HasSuffix(name, storeSuffix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, storePrefix), storeSuffix)
+		t, err := time.Parse(storeTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		times = append(times, t)
+	}
+	// ReadDir sorts by name, which is also time order.
+	return times, nil
+}
+
+func (st *SnapshotStore) file(t time.Time) string {
+	return filepath.Join(st.dir, storePrefix+t.UTC().Format(storeTimeFormat)+storeSuffix)
+}
+
+// Load reads the snapshot taken at time t.
+func (st *SnapshotStore) Load(t time.Time) (Snapshot, error) {
+	var snap Snapshot
+	data, err := ioutil.ReadFile(st.file(t))
+	if err != nil {
+		return snap, err
+	}
+	err = json.Unmarshal(data, &snap)
+	return snap, err
+}
+
+// Latest returns the most recent snapshot and its time.
+func (st *SnapshotStore) Latest() (Snapshot, time.Time, error) {
+	times, err := st.Times()
+	if err != nil {
+		return Snapshot{}, time.Time{}, err
+	}
+	if len(times) == 0 {
+		return Snapshot{}, time.Time{}, errors.New("memsize: snapshot store is empty")
+	}
+	t := times[len(times)-1]
+	snap, err := st.Load(t)
+	return snap, t, err
+}
+
+// Series returns the size of the named type in every stored snapshot, in ascending
+// order of time. Snapshots not containing the type yield a zero TypeSize. The empty
+// name selects the total of each snapshot.
+func (st *SnapshotStore) Series(typeName string) ([]SeriesPoint, error) {
+	times, err := st.Times()
+	if err != nil {
+		return nil, err
+	}
+	series := make([]SeriesPoint, 0, len(times))
+	for _, t := range times {
+		snap, err := st.Load(t)
+		if os.IsNotExist(err) {
+			continue // removed by rotation
+		} else if err != nil {
+			return nil, err
+		}
+		p := SeriesPoint{Time: t, TypeSize: snap.ByType[typeName]}
+		if typeName == "" {
+			p.TypeSize = TypeSize{Total: snap.Total}
+		}
+		series = append(series, p)
+	}
+	return series, nil
+}
@@ -0,0 +1,59 @@
+package memsize
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSnapshotStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memsize-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	st, err := OpenSnapshotStore(dir, StoreOptions{MaxSnapshots: 3, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		snap := Snapshot{Total: uintptr(100 * i), ByType: map[string]TypeSize{"T": {Count: uintptr(i), Total: uintptr(10 * i)}}}
+		if err := st.Save(snap, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	times, err := st.Times()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 3 || !times[0].Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("wrong times after rotation: %v", times)
+	}
+	series, err := st.Series("T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range series {
+		if p.Total != uintptr(10*(i+2)) {
+			t.Errorf("point %d: total %d, want %d", i, p.Total, 10*(i+2))
+		}
+	}
+	snap, at, err := st.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Total != 400 || !at.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("wrong latest snapshot: total %d at %v", snap.Total, at)
+	}
+
+	// Saving a much later snapshot removes all others by age.
+	if err := st.Save(Snapshot{}, start.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if times, _ := st.Times(); len(times) != 1 {
+		t.Fatalf("wrong times after age rotation: %v", times)
+	}
+}
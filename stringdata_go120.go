@@ -0,0 +1,11 @@
+//go:build go1.20
+// +build go1.20
+
+package memsize
+
+import "unsafe"
+
+// stringData returns the address of the data of s.
+func stringData(s string) uintptr {
+	return uintptr(unsafe.Pointer(unsafe.StringData(s)))
+}
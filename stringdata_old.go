@@ -0,0 +1,14 @@
+//go:build !go1.20
+// +build !go1.20
+
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// stringData returns the address of the data of s.
+func stringData(s string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
@@ -0,0 +1,115 @@
+package memsize
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/fjl/memsize/internal/runtimefunc"
+)
+
+// scanString accounts the data of a string. String data is tracked in the seen set,
+// so strings sharing data (e.g. substrings of a larger string) are counted once.
+func (c *scanState) scanString(v reflect.Value) uintptr {
+	str := v.String()
+	n := uintptr(len(str))
+	if n == 0 {
+		return 0
+	}
+	data := stringData(str)
+	if c.excluded(data) {
+		return 0
+	}
+	c.obj.edges++
+	if c.countArrays && c.seen.countRange(data, 1) == 0 {
+		c.s.StringBlocks++
+	}
+	if c.attributeData() {
+		return c.scanData(data, func() uintptr { return c.scanStringData(data, n) })
+	}
+	return c.scanStringData(data, n)
+}
+
+// scanStringData accounts n bytes of string data at data.
+func (c *scanState) scanStringData(data, n uintptr) uintptr {
+	marked := c.seen.countRange(data, n)
+	if marked < n {
+		c.recordAddr(data, n, reflect.TypeOf(""))
+	}
+	c.seen.markRange(data, n)
+	c.obj.shared += marked
+	if c.stringPins != nil {
+		c.stringPins.add(data, n, c.owner)
+	}
+	c.addMemory(reflect.String, address(data), n-marked)
+	return n - marked
+}
+
+// StringPin is a string which shares its data with a much larger region of
+// string data. Keeping such a string alive keeps the whole region alive.
+//
+// The region is the heap object holding the string data, so data which isn't
+// referenced by any scanned string is included. Where the heap layout is unknown
+// to memsize, e.g. when building with the purego tag, the region is determined
+// from the string data reached during the scan, and such data is missed.
+type StringPin struct {
+	Owner   reflect.Type // type of the object holding the string
+	Len     uintptr      // length of the string
+	Backing uintptr      // size of the region of string data it is part of
+}
+
+// maxTinySize is the size of the blocks into which the runtime packs small
+// allocations without pointers. Such blocks aren't regions of a single string.
+const maxTinySize = 16
+
+// stringPinTracker records string data ranges during the scan.
+type stringPinTracker struct {
+	factor uintptr
+	ranges []stringRange
+}
+
+type stringRange struct {
+	start, len uintptr
+	owner      reflect.Type
+	// Extent of the heap object holding the data, or of the data itself when the
+	// object is unknown.
+	objStart, objEnd uintptr
+}
+
+// add records a string. It must be called while the world is stopped, because
+// it looks up the heap object of the data.
+func (t *stringPinTracker) add(data, n uintptr, owner reflect.Type) {
+	r := stringRange{start: data, len: n, owner: owner, objStart: data, objEnd: data + n}
+	if haveAddrClass && runtimefunc.HaveObjectSize {
+		if base := runtimefunc.FindObjectBase(data); base != 0 {
+			if size := runtimefunc.ObjectSize(base); size > maxTinySize {
+				r.objStart, r.objEnd = base, base+size
+			}
+		}
+	}
+	t.ranges = append(t.ranges, r)
+}
+
+// pins computes the pinning strings by merging overlapping ranges into regions.
+func (t *stringPinTracker) pins() []StringPin {
+	sort.Slice(t.ranges, func(i, j int) bool { return t.ranges[i].objStart < t.ranges[j].objStart })
+	var pins []StringPin
+	for i := 0; i < len(t.ranges); {
+		// Find extent of the region starting at range i.
+		start, end := t.ranges[i].objStart, t.ranges[i].objEnd
+		j := i + 1
+		for ; j < len(t.ranges) && t.ranges[j].objStart < end; j++ {
+			if e := t.ranges[j].objEnd; e > end {
+				end = e
+			}
+		}
+		backing := end - start
+		for _, r := range t.ranges[i:j] {
+			if backing >= r.len*t.factor {
+				pins = append(pins, StringPin{Owner: r.owner, Len: r.len, Backing: backing})
+			}
+		}
+		i = j
+	}
+	sort.SliceStable(pins, func(i, j int) bool { return pins[i].Backing > pins[j].Backing })
+	return pins
+}
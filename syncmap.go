@@ -0,0 +1,154 @@
+package memsize
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// This file contains the special handling of sync.Map.
+//
+// Before Go 1.20, sync.Map entries hold their value in an unsafe.Pointer to an
+// interface. In Go 1.20-1.23, entries use atomic.Pointer, which is handled by
+// scanAtomicPointer. Since Go 1.24, sync.Map is a wrapper around
+// internal/sync.HashTrieMap[any, any], which stores entries in a trie of nodes:
+//
+//	type indirect[K, V] struct {
+//		node[K, V]
+//		dead     atomic.Bool
+//		mu       Mutex
+//		parent   *indirect[K, V]
+//		children [16]atomic.Pointer[node[K, V]]
+//	}
+//
+//	type entry[K, V] struct {
+//		node[K, V]
+//		overflow atomic.Pointer[entry[K, V]]
+//		key      K
+//		value    V
+//	}
+//
+// The children of indirect nodes are declared as *node but point to either indirect
+// or entry nodes, depending on node.isEntry. The entry type can't be obtained through
+// reflection, so an equivalent struct type is used.
+
+var syncMapEntryType = reflect.StructOf([]reflect.StructField{
+	{Name: "IsEntry", Type: reflect.TypeOf(false)},
+	{Name: "Overflow", Type: reflect.TypeOf(unsafe.Pointer(nil))},
+	{Name: "Key", Type: reflect.TypeOf((*interface{})(nil)).Elem()},
+	{Name: "Value", Type: reflect.TypeOf((*interface{})(nil)).Elem()},
+})
+
+var syncMapEntryOverflow = syncMapEntryType.Field(1).Offset
+
+// haveSyncMapTrie reports whether the layout of sync.Map is as expected.
+var haveSyncMapTrie = haveInternals && checkSyncMapLayout()
+
+// isSyncMapTrie reports whether typ is sync.Map implemented by HashTrieMap.
+func isSyncMapTrie(typ reflect.Type) bool {
+	f, ok := typ.FieldByName("m")
+	return ok && f.Type.Kind() == reflect.Struct && strings.HasPrefix(f.Type.Name(), "HashTrieMap[")
+}
+
+// isSyncMapEntryPtr reports whether typ is the pre-Go 1.20 sync.Map entry type.
+func isSyncMapEntryPtr(typ reflect.Type) bool {
+	f, ok := typ.FieldByName("p")
+	return ok && typ.NumField() == 1 && f.Type.Kind() == reflect.UnsafePointer
+}
+
+func checkSyncMapLayout() bool {
+	var m sync.Map
+	typ := reflect.TypeOf(&m).Elem()
+	if !isSyncMapTrie(typ) {
+		return false
+	}
+	m.Store("key", "value")
+	found := false
+	ok := walkSyncMapTrie(reflect.ValueOf(&m).Elem(), func(p unsafe.Pointer, typ reflect.Type) {
+		if typ == syncMapEntryType {
+			e := reflect.NewAt(typ, p).Elem()
+			found = e.Field(2).Interface() == "key" && e.Field(3).Interface() == "value"
+		}
+	})
+	return ok && found
+}
+
+// walkSyncMapTrie calls fn for all nodes in the trie of a sync.Map, passing the
+// node type. It returns false if the map doesn't have a trie.
+func walkSyncMapTrie(m reflect.Value, fn func(p unsafe.Pointer, typ reflect.Type)) bool {
+	trie := m.FieldByName("m")
+	root := trie.FieldByName("root")
+	if !root.IsValid() || !isAtomicPointer(root.Type()) {
+		return false
+	}
+	indType := root.Type().Field(0).Type.Elem().Elem()
+	if _, ok := indType.FieldByName("children"); !ok {
+		return false
+	}
+	if p := atomicPointerTarget(root); p != nil {
+		walkSyncMapNode(p, indType, fn)
+	}
+	return true
+}
+
+func walkSyncMapNode(p unsafe.Pointer, indType reflect.Type, fn func(p unsafe.Pointer, typ reflect.Type)) {
+	fn(p, indType)
+	children := reflect.NewAt(indType, p).Elem().FieldByName("children")
+	for i := 0; i < children.Len(); i++ {
+		cp := atomicPointerTarget(children.Index(i))
+		if cp == nil {
+			continue
+		}
+		if *(*bool)(cp) {
+			// Entry node, walk the overflow chain.
+			for e := cp; e != nil; e = *(*unsafe.Pointer)(unsafe.Pointer(uintptr(e) + syncMapEntryOverflow)) {
+				fn(e, syncMapEntryType)
+			}
+		} else {
+			walkSyncMapNode(cp, indType, fn)
+		}
+	}
+}
+
+// atomicPointerTarget returns the value of an atomic.Pointer.
+func atomicPointerTarget(v reflect.Value) unsafe.Pointer {
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.UnsafePointer {
+			return unsafe.Pointer(f.Pointer())
+		}
+	}
+	return nil
+}
+
+// scanSyncMap scans the trie of a sync.Map. Like the buckets of a map, the memory of
+// the trie nodes, keys and values is returned as extra memory of the map.
+func (c *scanState) scanSyncMap(addr address, v reflect.Value) uintptr {
+	// The other fields of HashTrieMap don't reference memory.
+	extra := uintptr(0)
+	walkSyncMapTrie(v, func(p unsafe.Pointer, typ reflect.Type) {
+		if typ == syncMapEntryType {
+			extra += c.scanBoxed(address(p), reflect.NewAt(typ, p).Elem(), reflect.Struct)
+			return
+		}
+		// Indirect nodes are accounted without scanning their content, which
+		// would follow the children as *node.
+		size := typ.Size()
+		marked := c.seen.countRange(uintptr(p), size)
+		c.seen.markRange(uintptr(p), size)
+		c.addMemory(reflect.Struct, address(p), size-marked)
+		extra += size - marked
+	})
+	return extra
+}
+
+// scanSyncMapEntryPtr scans a pre-Go 1.20 sync.Map entry, whose field p points
+// to an interface value.
+func (c *scanState) scanSyncMapEntryPtr(v reflect.Value) uintptr {
+	f := v.Field(0)
+	if !f.IsNil() {
+		p := unsafe.Pointer(f.Pointer())
+		c.scan(address(p), reflect.NewAt(reflect.TypeOf((*interface{})(nil)).Elem(), p).Elem(), true)
+	}
+	return 0
+}
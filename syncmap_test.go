@@ -0,0 +1,33 @@
+package memsize
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSyncMap(t *testing.T) {
+	if isSyncMapTrie(reflect.TypeOf(sync.Map{})) && !haveSyncMapTrie {
+		t.Skip("sync.Map layout not supported")
+	}
+	var (
+		m      = new(sync.Map)
+		shared = &struct16{}
+		n      = 100
+	)
+	for i := 0; i < n; i++ {
+		m.Store(i, &struct16{})
+	}
+	m.Store("shared1", shared)
+	m.Store("shared2", shared)
+
+	sizes := Scan(m)
+	if c := sizes.ByType[reflect.TypeOf(struct16{})].Count; c != uintptr(n+1) {
+		t.Errorf("wrong struct16 count %d, want %d", c, n+1)
+	}
+	// Keys and values are interfaces, so the map must at least hold their memory.
+	min := uintptr(n+2) * 2 * sizeofInterface
+	if ts := sizes.ByType[reflect.TypeOf(sync.Map{})]; ts.Total < min {
+		t.Errorf("sync.Map total %d too small, want at least %d", ts.Total, min)
+	}
+}
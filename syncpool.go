@@ -0,0 +1,116 @@
+package memsize
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// This file contains the special handling of sync.Pool.
+//
+// Since Go 1.13, a pool holds per-P arrays of poolLocal in its local and victim fields:
+//
+//	type poolLocalInternal struct {
+//		private any
+//		shared  poolChain // {head *poolChainElt; tail atomic.Pointer[poolChainElt]}
+//	}
+//
+//	type poolLocal struct {
+//		poolLocalInternal
+//		pad [128 - unsafe.Sizeof(poolLocalInternal{})%128]byte
+//	}
+//
+//	type poolChainElt struct {
+//		poolDequeue // {headTail atomic.Uint64; vals []eface}
+//		next, prev  atomic.Pointer[poolChainElt]
+//	}
+//
+// These types are not reachable through reflection because the arrays are referenced
+// by unsafe.Pointer. Equivalent struct types are used instead.
+
+var (
+	syncPoolLocalType = func() reflect.Type {
+		fields := []reflect.StructField{
+			{Name: "Private", Type: reflect.TypeOf((*interface{})(nil)).Elem()},
+			{Name: "Head", Type: reflect.TypeOf(unsafe.Pointer(nil))},
+			{Name: "Tail", Type: reflect.TypeOf(unsafe.Pointer(nil))},
+		}
+		size := reflect.StructOf(fields).Size()
+		pad := reflect.ArrayOf(int(128-size%128), reflect.TypeOf(byte(0)))
+		return reflect.StructOf(append(fields, reflect.StructField{Name: "Pad", Type: pad}))
+	}()
+	syncPoolEltType = reflect.StructOf([]reflect.StructField{
+		{Name: "HeadTail", Type: reflect.TypeOf(uint64(0))},
+		{Name: "Vals", Type: reflect.TypeOf([]interface{}{})},
+		{Name: "Next", Type: reflect.TypeOf(unsafe.Pointer(nil))},
+		{Name: "Prev", Type: reflect.TypeOf(unsafe.Pointer(nil))},
+	})
+)
+
+// haveSyncPool reports whether the layout of sync.Pool is as expected.
+var haveSyncPool = haveInternals && checkSyncPoolLayout()
+
+func checkSyncPoolLayout() bool {
+	var (
+		p     sync.Pool
+		typ   = reflect.TypeOf(&p).Elem()
+		item  = new(uint64)
+		found = false
+	)
+	for _, name := range []string{"local", "localSize", "victim", "victimSize"} {
+		if _, ok := typ.FieldByName(name); !ok {
+			return false
+		}
+	}
+	p.Put(item)
+	walkSyncPool(reflect.ValueOf(&p).Elem(), func(p unsafe.Pointer, typ reflect.Type) {
+		var items []interface{}
+		switch typ {
+		case syncPoolLocalType:
+			items = []interface{}{reflect.NewAt(typ, p).Elem().Field(0).Interface()}
+		case syncPoolEltType:
+			items = reflect.NewAt(typ, p).Elem().Field(1).Interface().([]interface{})
+		}
+		for _, it := range items {
+			if it == item {
+				found = true
+			}
+		}
+	})
+	return found
+}
+
+// walkSyncPool calls fn for all poolLocal and poolChainElt objects of a pool.
+// The poolLocal arrays are passed as individual elements.
+func walkSyncPool(v reflect.Value, fn func(p unsafe.Pointer, typ reflect.Type)) {
+	for _, names := range [][2]string{{"local", "localSize"}, {"victim", "victimSize"}} {
+		f := v.FieldByName(names[0])
+		if f.IsNil() {
+			continue
+		}
+		base, n := unsafe.Pointer(f.Pointer()), v.FieldByName(names[1]).Uint()
+		for i := uint64(0); i < n; i++ {
+			local := unsafe.Pointer(uintptr(base) + uintptr(i)*syncPoolLocalType.Size())
+			fn(local, syncPoolLocalType)
+			// Walk the chain from head to tail using prev.
+			elt := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(local) + syncPoolLocalType.Field(1).Offset))
+			for elt != nil {
+				fn(elt, syncPoolEltType)
+				elt = *(*unsafe.Pointer)(unsafe.Pointer(uintptr(elt) + syncPoolEltType.Field(3).Offset))
+			}
+		}
+	}
+}
+
+// scanSyncPool scans the items held by a sync.Pool. The memory of the per-P arrays,
+// chain elements and items is returned as extra memory of the pool.
+func (c *scanState) scanSyncPool(addr address, v reflect.Value) uintptr {
+	if !c.poolContents {
+		return c.scanStruct(addr, v)
+	}
+	extra := uintptr(0)
+	walkSyncPool(v, func(p unsafe.Pointer, typ reflect.Type) {
+		extra += c.scanBoxed(address(p), reflect.NewAt(typ, p).Elem(), reflect.Struct)
+	})
+	return extra
+}
@@ -0,0 +1,31 @@
+package memsize
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSyncPool(t *testing.T) {
+	if !haveSyncPool {
+		t.Skip("sync.Pool layout not supported")
+	}
+	p := new(sync.Pool)
+	for i := 0; i < 50; i++ {
+		p.Put(&[64]byte{})
+	}
+	typ := reflect.TypeOf([64]byte{})
+
+	if sizes := Scan(p); sizes.ByType[typ] != nil {
+		t.Fatalf("pool items counted without PoolContents")
+	}
+	sizes := ScanWithOptions(p, Options{PoolContents: true})
+	ts := sizes.ByType[typ]
+	if ts == nil {
+		t.Fatalf("pool items not counted:\n%s", sizes.Report())
+	}
+	// The pool may drop items, but most of them should be there.
+	if ts.Count < 25 || ts.Count > 50 {
+		t.Errorf("wrong item count %d", ts.Count)
+	}
+}
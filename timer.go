@@ -0,0 +1,103 @@
+package memsize
+
+import (
+	"reflect"
+	"time"
+	"unsafe"
+
+	"github.com/fjl/memsize/internal/runtimefunc"
+)
+
+// This file contains the special handling of time.Timer and time.Ticker.
+//
+// Since Go 1.23, timers are allocated by the runtime as
+//
+//	type timeTimer struct {
+//		c    unsafe.Pointer // <-chan time.Time
+//		init bool
+//		timer // {mu mutex; ...; f func(any, uintptr, int64); arg any; ...}
+//	}
+//
+// and time.Timer only declares the first two fields. For timers created by
+// time.AfterFunc, arg holds the function to call, which is not reachable through
+// reflection. The offset of arg is found by looking for a known function in the
+// memory of a timer.
+
+var (
+	funcType     = reflect.TypeOf(func() {})
+	funcTypeWord = efaceTypeWord(func() {})
+)
+
+// timerArgOffset is the offset of the arg field in runtime.timeTimer, or zero if it
+// could not be found.
+var timerArgOffset = findTimerArgOffset()
+
+// haveTimerArg reports whether the function of AfterFunc timers can be found.
+var haveTimerArg = timerArgOffset != 0
+
+// isTimerType reports whether typ is time.Timer or time.Ticker of Go 1.23+, which
+// have the same layout.
+func isTimerType(typ reflect.Type) bool {
+	if typ.PkgPath() != "time" || (typ.Name() != "Timer" && typ.Name() != "Ticker") {
+		return false
+	}
+	_, ok := typ.FieldByName("initTimer")
+	return ok && typ.NumField() == 2
+}
+
+// efaceTypeWord returns the type word of an interface value.
+func efaceTypeWord(x interface{}) unsafe.Pointer {
+	return (*[2]unsafe.Pointer)(unsafe.Pointer(&x))[0]
+}
+
+func findTimerArgOffset() uintptr {
+	if !haveAddrClass || !runtimefunc.HaveObjectSize || !isTimerType(reflect.TypeOf(time.Timer{})) {
+		return 0
+	}
+	f := func() {}
+	t := time.AfterFunc(time.Hour, f)
+	defer t.Stop()
+	p := unsafe.Pointer(t)
+	fp := *(*unsafe.Pointer)(unsafe.Pointer(&f))
+	size := runtimefunc.ObjectSize(uintptr(p))
+	if runtimefunc.FindObjectBase(uintptr(p)) != uintptr(p) {
+		return 0
+	}
+	w := unsafe.Sizeof(uintptr(0))
+	for off := unsafe.Sizeof(*t); off+2*w <= size; off += w {
+		arg := (*[2]unsafe.Pointer)(unsafe.Pointer(uintptr(p) + off))
+		if arg[0] == funcTypeWord && arg[1] == fp {
+			return off
+		}
+	}
+	return 0
+}
+
+// timerFunc returns the function of the AfterFunc timer v. The timer is only read
+// when v is the start of a heap object large enough to be a runtime timer, i.e. not
+// a copy of a time.Timer, and the arg field holds a func().
+func timerFunc(v reflect.Value) (reflect.Value, bool) {
+	if !v.CanAddr() {
+		return reflect.Value{}, false
+	}
+	p := unsafe.Pointer(v.UnsafeAddr())
+	w := unsafe.Sizeof(uintptr(0))
+	if runtimefunc.FindObjectBase(uintptr(p)) != uintptr(p) || runtimefunc.ObjectSize(uintptr(p)) < timerArgOffset+2*w {
+		return reflect.Value{}, false
+	}
+	arg := (*[2]unsafe.Pointer)(unsafe.Pointer(uintptr(p) + timerArgOffset))
+	if arg[0] != funcTypeWord {
+		return reflect.Value{}, false
+	}
+	return reflect.NewAt(funcType, unsafe.Pointer(&arg[1])).Elem(), true
+}
+
+// scanTimer scans a time.Timer or time.Ticker along with the function of AfterFunc
+// timers. The closure of the function is recorded in Sizes.Unscannable.
+func (c *scanState) scanTimer(addr address, v reflect.Value) uintptr {
+	extra := c.scanStruct(addr, v)
+	if f, ok := timerFunc(v); ok {
+		c.scanFunc(f)
+	}
+	return extra
+}
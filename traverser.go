@@ -0,0 +1,69 @@
+package memsize
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// traverserMap holds the registered traversers. It is replaced on every
+// registration, so scans can read it without locking while the world is stopped.
+type traverserMap map[reflect.Type]func(v reflect.Value, visit func(child reflect.Value, addr uintptr))
+
+var (
+	traversers  atomic.Value // traverserMap
+	traverserMu sync.Mutex
+)
+
+// RegisterTraverser teaches memsize how to walk the internals of a container type
+// whose contents are not visible to reflection, e.g. nodes stored in memory
+// allocated outside of Go or referenced through uintptr.
+//
+// Whenever the scan reaches a value of the struct type typ, fn is called instead
+// of scanning the fields of the value. The value is addressable when it was reached
+// through a pointer, so its unexported fields can be accessed using v.UnsafeAddr.
+// fn reports the objects held by the container by calling visit with each
+// object and its address. The objects are counted and scanned like objects
+// referenced by a pointer. When the address is zero, the object can't be
+// deduplicated and is counted every time it is visited.
+//
+// Register traversers before scanning, e.g. in an init function. Scanners cache
+// type information, so registrations made after a Scanner was first used may not
+// apply to it. Registering nil removes the traverser of typ.
+func RegisterTraverser(typ reflect.Type, fn func(v reflect.Value, visit func(child reflect.Value, addr uintptr))) {
+	if typ.Kind() != reflect.Struct {
+		panic("memsize: traverser type must be a struct")
+	}
+	traverserMu.Lock()
+	defer traverserMu.Unlock()
+	old, _ := traversers.Load().(traverserMap)
+	m := make(traverserMap, len(old)+1)
+	for t, f := range old {
+		m[t] = f
+	}
+	if fn == nil {
+		delete(m, typ)
+	} else {
+		m[typ] = fn
+	}
+	traversers.Store(m)
+}
+
+func lookupTraverser(typ reflect.Type) func(v reflect.Value, visit func(child reflect.Value, addr uintptr)) {
+	m, _ := traversers.Load().(traverserMap)
+	return m[typ]
+}
+
+// scanTraverser scans a value of a type with a registered traverser.
+func (c *scanState) scanTraverser(addr address, v reflect.Value) uintptr {
+	fn := lookupTraverser(v.Type())
+	if fn == nil {
+		return c.scanStruct(addr, v) // removed after the type was cached
+	}
+	fn(v, func(child reflect.Value, caddr uintptr) {
+		if !c.stopped {
+			c.scan(address(caddr), child, true)
+		}
+	})
+	return 0
+}
@@ -0,0 +1,205 @@
+package memsize
+
+import (
+	"container/heap"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// TreeNode is a node of the ownership tree, see Snapshot.Tree.
+type TreeNode struct {
+	Name string `json:"name"`
+	// Self is the memory of the type itself which is attributed to the node.
+	// Total includes the memory of all children.
+	Self     uintptr     `json:"self"`
+	Total    uintptr     `json:"total"`
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// Tree converts the ownership matrix into a tree. The roots of the tree are the
+// types which aren't referenced by any other type. Each node's children are types
+// it references.
+//
+// Every type appears exactly once in the tree, and its node holds all memory of the
+// type. When a type is referenced by several other types, it is placed below the
+// one referencing most of its memory. Types which are only reachable through
+// cycles become roots, starting with the largest of them.
+//
+// Without ownership information (see Options.Ownership), every type is a root.
+func (s Snapshot) Tree() []*TreeNode {
+	// Compute the memory of each type referenced by other types.
+	external := make(map[string]uintptr)
+	for parent, children := range s.Ownership {
+		for child, size := range children {
+			if child != parent {
+				external[child] += size
+			}
+		}
+	}
+	var (
+		roots    []*TreeNode
+		nodes    = make(map[string]*TreeNode, len(s.ByType))
+		frontier treeEdges
+	)
+	// add creates the node of a type and records its references as candidate edges.
+	add := func(name string, parent *TreeNode) {
+		n := &TreeNode{Name: name, Self: s.ByType[name].Total}
+		nodes[name] = n
+		if parent == nil {
+			roots = append(roots, n)
+		} else {
+			parent.Children = append(parent.Children, n)
+		}
+		for child, size := range s.Ownership[name] {
+			if _, ok := s.ByType[child]; ok && size > 0 && nodes[child] == nil {
+				heap.Push(&frontier, treeEdge{n, child, size})
+			}
+		}
+	}
+	// grow attaches types along the largest references until all types reachable
+	// from the tree are placed.
+	grow := func() {
+		for frontier.Len() > 0 {
+			e := heap.Pop(&frontier).(treeEdge)
+			if nodes[e.child] == nil {
+				add(e.child, e.parent)
+			}
+		}
+	}
+	names := s.TypeNames()
+	for _, name := range names {
+		if external[name] == 0 {
+			add(name, nil)
+		}
+	}
+	grow()
+	for _, name := range names {
+		if nodes[name] == nil {
+			add(name, nil) // on cycles only
+			grow()
+		}
+	}
+	for _, n := range roots {
+		n.sumTotals()
+	}
+	sortTree(roots)
+	return roots
+}
+
+// sumTotals computes the totals of n and its descendants and sorts the children.
+func (n *TreeNode) sumTotals() {
+	n.Total = n.Self
+	for _, c := range n.Children {
+		c.sumTotals()
+		n.Total += c.Total
+	}
+	sortTree(n.Children)
+}
+
+// treeEdge is a reference from a node of the tree to a type which may be attached
+// below it.
+type treeEdge struct {
+	parent *TreeNode
+	child  string
+	size   uintptr
+}
+
+// treeEdges is a heap of edges, largest first.
+type treeEdges []treeEdge
+
+func (h treeEdges) Len() int      { return len(h) }
+func (h treeEdges) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h treeEdges) Less(i, j int) bool {
+	if h[i].size != h[j].size {
+		return h[i].size > h[j].size
+	}
+	if h[i].child != h[j].child {
+		return h[i].child < h[j].child
+	}
+	return h[i].parent.Name < h[j].parent.Name
+}
+func (h *treeEdges) Push(x interface{}) { *h = append(*h, x.(treeEdge)) }
+func (h *treeEdges) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+func sortTree(nodes []*TreeNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Total != nodes[j].Total {
+			return nodes[i].Total > nodes[j].Total
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+}
+
+// WriteSpeedscope writes the ownership tree (see Snapshot.Tree) in the file format
+// of the speedscope profile viewer (https://www.speedscope.app).
+func (s Sizes) WriteSpeedscope(w io.Writer) error {
+	return s.Snapshot().WriteSpeedscope(w)
+}
+
+// WriteSpeedscope writes the ownership tree in speedscope format, see
+// Sizes.WriteSpeedscope.
+func (s Snapshot) WriteSpeedscope(w io.Writer) error {
+	type frame struct {
+		Name string `json:"name"`
+	}
+	type profile struct {
+		Type       string  `json:"type"`
+		Name       string  `json:"name"`
+		Unit       string  `json:"unit"`
+		StartValue uintptr `json:"startValue"`
+		EndValue   uintptr `json:"endValue"`
+		Samples    [][]int `json:"samples"`
+		Weights    []uint  `json:"weights"`
+	}
+	var (
+		frames  = []frame{}
+		index   = make(map[string]int)
+		prof    = profile{Type: "sampled", Name: "memsize", Unit: "bytes", Samples: [][]int{}, Weights: []uint{}}
+		walk    func(n *TreeNode, stack []int)
+		frameOf = func(name string) int {
+			i, ok := index[name]
+			if !ok {
+				i = len(frames)
+				index[name] = i
+				frames = append(frames, frame{name})
+			}
+			return i
+		}
+	)
+	walk = func(n *TreeNode, stack []int) {
+		stack = append(stack, frameOf(n.Name))
+		if n.Self > 0 {
+			prof.Samples = append(prof.Samples, append([]int(nil), stack...))
+			prof.Weights = append(prof.Weights, uint(n.Self))
+			prof.EndValue += n.Self
+		}
+		for _, c := range n.Children {
+			walk(c, stack)
+		}
+	}
+	for _, root := range s.Tree() {
+		walk(root, nil)
+	}
+	file := struct {
+		Schema   string `json:"$schema"`
+		Name     string `json:"name"`
+		Exporter string `json:"exporter"`
+		Shared   struct {
+			Frames []frame `json:"frames"`
+		} `json:"shared"`
+		Profiles []profile `json:"profiles"`
+	}{
+		Schema:   "https://www.speedscope.app/file-format-schema.json",
+		Name:     "memsize",
+		Exporter: "memsize",
+		Profiles: []profile{prof},
+	}
+	file.Shared.Frames = frames
+	return json.NewEncoder(w).Encode(file)
+}
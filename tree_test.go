@@ -0,0 +1,115 @@
+package memsize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	v := &structmultiptr{s1: &structptr{cld: &structptr{}}, u1: &structuint32ptr{x: new(uint32)}}
+	snap := ScanWithOptions(v, Options{Ownership: true}).Snapshot()
+	roots := snap.Tree()
+	if len(roots) != 1 {
+		t.Fatalf("wrong number of roots: %d", len(roots))
+	}
+	root := roots[0]
+	if root.Name != "memsize.structmultiptr" || root.Total != snap.Total {
+		t.Fatalf("wrong root %s with total %d, want total %d", root.Name, root.Total, snap.Total)
+	}
+	// The linked structptr values are represented by a single node.
+	if len(root.Children) != 2 || root.Children[0].Name != "memsize.structptr" {
+		t.Fatalf("wrong children of root: %+v", root.Children)
+	}
+	if c := root.Children[0]; c.Self != 2*2*sizeofWord || len(c.Children) != 0 {
+		t.Fatalf("wrong structptr node: %+v", c)
+	}
+
+	var buf bytes.Buffer
+	if err := snap.WriteSpeedscope(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Shared struct {
+			Frames []struct{ Name string }
+		}
+		Profiles []struct {
+			EndValue uintptr
+			Samples  [][]int
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Shared.Frames) != 4 || len(file.Profiles) != 1 {
+		t.Fatalf("wrong speedscope file: %s", buf.Bytes())
+	}
+	if p := file.Profiles[0]; p.EndValue != snap.Total || len(p.Samples) != 4 {
+		t.Fatalf("wrong speedscope profile: %s", buf.Bytes())
+	}
+}
+
+func TestTreeDense(t *testing.T) {
+	// All types reference each other, which has a huge number of paths.
+	const n = 40
+	snap := Snapshot{ByType: make(map[string]TypeSize), Ownership: make(map[string]map[string]uintptr)}
+	name := func(i int) string { return fmt.Sprintf("t%02d", i) }
+	for i := 0; i < n; i++ {
+		snap.ByType[name(i)] = TypeSize{Total: 100}
+		snap.Total += 100
+		snap.Ownership[name(i)] = make(map[string]uintptr)
+		for j := 0; j < n; j++ {
+			if i != j {
+				snap.Ownership[name(i)][name(j)] = uintptr(1 + (i*j)%7)
+			}
+		}
+	}
+	snap.ByType["root"] = TypeSize{Total: 100}
+	snap.Total += 100
+	snap.Ownership["root"] = map[string]uintptr{name(0): 50}
+
+	roots := snap.Tree()
+	if len(roots) != 1 || roots[0].Name != "root" || roots[0].Total != snap.Total {
+		t.Fatalf("wrong roots: %+v", roots)
+	}
+	seen := make(map[string]bool)
+	var walk func(*TreeNode)
+	walk = func(n *TreeNode) {
+		if seen[n.Name] {
+			t.Errorf("type %s appears twice", n.Name)
+		}
+		seen[n.Name] = true
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(roots[0])
+	if len(seen) != n+1 {
+		t.Errorf("tree has %d types, want %d", len(seen), n+1)
+	}
+}
+
+func TestTreeParent(t *testing.T) {
+	// Types referenced by several others are placed below the largest reference.
+	snap := Snapshot{
+		Total: 60,
+		ByType: map[string]TypeSize{
+			"a": {Total: 10}, "b": {Total: 10}, "c": {Total: 40},
+		},
+		Ownership: map[string]map[string]uintptr{
+			"a": {"c": 10},
+			"b": {"c": 30},
+		},
+	}
+	roots := snap.Tree()
+	if len(roots) != 2 || roots[0].Name != "b" || roots[0].Total != 50 || roots[1].Name != "a" || roots[1].Total != 10 {
+		t.Fatalf("wrong roots: %+v %+v", roots[0], roots[1])
+	}
+	// Cycles without a root start at their largest type.
+	snap.Ownership["c"] = map[string]uintptr{"a": 10, "b": 10}
+	roots = snap.Tree()
+	if len(roots) != 1 || roots[0].Name != "c" || roots[0].Total != 60 {
+		t.Fatalf("wrong roots of cycle: %+v", roots)
+	}
+}
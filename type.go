@@ -40,6 +40,10 @@ type typCache map[reflect.Type]typInfo
 type typInfo struct {
 	isPointer bool
 	needScan  bool
+	ptrWords  uintptr // number of pointer words in the type's memory layout
+	hdrWords  uintptr // number of words in string, slice, map and channel headers
+	special   specialKind
+	group     retainGroup
 }
 
 // isPointer returns true for pointer-ish values. The notion of
@@ -49,6 +53,18 @@ func (tc *typCache) isPointer(typ reflect.Type) bool {
 	return tc.info(typ).isPointer
 }
 
+// pointerWords returns the number of words in a value of the type that
+// hold pointers. This is an approximation of what the garbage collector sees.
+func (tc *typCache) pointerWords(typ reflect.Type) uintptr {
+	return tc.info(typ).ptrWords
+}
+
+// headerWords returns the number of words in a value of the type that belong
+// to string, slice, map and channel headers.
+func (tc *typCache) headerWords(typ reflect.Type) uintptr {
+	return tc.info(typ).hdrWords
+}
+
 // needScan reports whether a value of the type needs to be scanned
 // recursively because it may contain pointers.
 func (tc *typCache) needScan(typ reflect.Type) bool {
@@ -61,9 +77,16 @@ func (tc *typCache) info(typ reflect.Type) typInfo {
 	case found:
 		return info
 	case isPointer(typ):
-		info = typInfo{true, true}
+		info = typInfo{true, true, pointerKindWords(typ.Kind()), headerKindWords(typ.Kind()), specialNone, groupNone}
 	default:
-		info = typInfo{false, tc.checkNeedScan(typ)}
+		info = typInfo{false, tc.checkNeedScan(typ), tc.countPointerWords(typ), tc.countHeaderWords(typ), specialNone, groupNone}
+		if typ.Kind() == reflect.Struct {
+			info.special = specialKindOf(typ)
+			info.group = retainGroupOf(typ)
+			if info.special == specialTraverser {
+				info.needScan = true // contents are unknown
+			}
+		}
 	}
 	(*tc)[typ] = info
 	return info
@@ -85,6 +108,59 @@ func (tc *typCache) checkNeedScan(typ reflect.Type) bool {
 	return false
 }
 
+func (tc *typCache) countPointerWords(typ reflect.Type) uintptr {
+	switch typ.Kind() {
+	case reflect.Struct:
+		n := uintptr(0)
+		for i := 0; i < typ.NumField(); i++ {
+			n += tc.pointerWords(typ.Field(i).Type)
+		}
+		return n
+	case reflect.Array:
+		return uintptr(typ.Len()) * tc.pointerWords(typ.Elem())
+	case reflect.UnsafePointer:
+		return 1
+	}
+	return 0
+}
+
+func (tc *typCache) countHeaderWords(typ reflect.Type) uintptr {
+	switch typ.Kind() {
+	case reflect.Struct:
+		n := uintptr(0)
+		for i := 0; i < typ.NumField(); i++ {
+			n += tc.headerWords(typ.Field(i).Type)
+		}
+		return n
+	case reflect.Array:
+		return uintptr(typ.Len()) * tc.headerWords(typ.Elem())
+	}
+	return 0
+}
+
+// headerKindWords returns the number of header words in a value of a pointer-ish
+// kind. Pointers, interfaces and functions don't have headers.
+func headerKindWords(k reflect.Kind) uintptr {
+	switch k {
+	case reflect.String:
+		return 2
+	case reflect.Slice:
+		return 3
+	case reflect.Map, reflect.Chan:
+		return 1
+	}
+	return 0
+}
+
+// pointerKindWords returns the number of pointer words in a value of a
+// pointer-ish kind.
+func pointerKindWords(k reflect.Kind) uintptr {
+	if k == reflect.Interface {
+		return 2 // type and data word
+	}
+	return 1
+}
+
 func isPointer(typ reflect.Type) bool {
 	k := typ.Kind()
 	switch {
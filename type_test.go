@@ -15,7 +15,7 @@ var typCacheTests = []struct {
 	},
 	{
 		val:  make(chan struct{}, 1),
-		want: typInfo{isPointer: true, needScan: true},
+		want: typInfo{isPointer: true, needScan: true, ptrWords: 1, hdrWords: 1},
 	},
 	{
 		val:  struct{ A int }{},
@@ -23,11 +23,11 @@ var typCacheTests = []struct {
 	},
 	{
 		val:  struct{ S string }{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 1, hdrWords: 2},
 	},
 	{
 		val:  structloop{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 1},
 	},
 	{
 		val:  [3]int{},
@@ -39,18 +39,22 @@ var typCacheTests = []struct {
 	},
 	{
 		val:  [3]struct{ S string }{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 3, hdrWords: 6},
 	},
 	{
 		val:  [3]structloop{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 3},
 	},
 	{
 		val: struct {
 			a [32]uint8
 			s [2][]uint8
 		}{},
-		want: typInfo{isPointer: false, needScan: true},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 2, hdrWords: 6},
+	},
+	{
+		val:  struct{ I interface{} }{},
+		want: typInfo{isPointer: false, needScan: true, ptrWords: 2},
 	},
 }
 
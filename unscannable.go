@@ -0,0 +1,112 @@
+package memsize
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/fjl/memsize/internal/runtimefunc"
+)
+
+// BlockedStats counts the values of a type which reference memory the scan can't
+// traverse, see Sizes.Unscannable.
+type BlockedStats struct {
+	Count uintptr // number of values
+	// Bytes is the memory of the heap objects referenced by the values. It is zero
+	// when the object sizes are unknown, e.g. when building with the purego tag.
+	// For closures, it includes the objects referenced by their captured variables
+	// when the pointer bitmaps of heap objects are known (Go 1.22+). Pointers are
+	// only found in objects of up to 512 bytes.
+	Bytes uintptr
+}
+
+// closureDepth is the number of levels of heap objects counted below a closure.
+// Variables captured by reference are moved to the heap by the compiler, so the
+// memory they reference is two levels below the closure.
+const closureDepth = 2
+
+// scanFunc records the closure referenced by a function value. Reflection can't
+// access the variables captured by closures and method values, so they are not
+// traversed. When the pointer bitmaps of heap objects are available, the memory
+// referenced by the captured variables is added to the closure's bytes. Function
+// values referring to top-level functions don't reference any heap memory and are
+// ignored.
+func (c *scanState) scanFunc(v reflect.Value) uintptr {
+	if v.IsNil() {
+		return 0
+	}
+	var size uintptr
+	if v.CanAddr() && haveAddrClass {
+		// The function value points to the closure object. Unlike v.Pointer, which
+		// returns the code pointer, this also works for method values.
+		p := *(*unsafe.Pointer)(unsafe.Pointer(v.UnsafeAddr()))
+		base := runtimefunc.FindObjectBase(uintptr(p))
+		if base == 0 {
+			return 0
+		}
+		if runtimefunc.HaveObjectSize {
+			size = runtimefunc.ObjectSize(base)
+			if c.seen.countRange(base, size) == size {
+				return 0 // already counted
+			}
+			if c.isBlocked(base) {
+				size = 0 // already recorded
+			} else {
+				c.block(base)
+				off := uintptr(p) - base
+				size += c.capturedSize(unsafe.Pointer(uintptr(p)-off), closureDepth)
+			}
+		}
+	}
+	owner := c.owner
+	if owner == nil {
+		owner = v.Type()
+	}
+	if c.s.Unscannable == nil {
+		c.s.Unscannable = make(map[reflect.Type]*BlockedStats)
+	}
+	bs := c.s.Unscannable[owner]
+	if bs == nil {
+		bs = new(BlockedStats)
+		c.s.Unscannable[owner] = bs
+	}
+	bs.Count++
+	bs.Bytes += size
+	return 0
+}
+
+// capturedSize returns the memory of the heap objects referenced by the object at
+// p which weren't counted or recorded before, following depth levels of pointers.
+// The pointers are found using the pointer bitmap of the object.
+func (c *scanState) capturedSize(p unsafe.Pointer, depth int) uintptr {
+	var size uintptr
+	runtimefunc.PointerWords(p, func(off uintptr) {
+		q := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(p) + off))
+		base := runtimefunc.FindObjectBase(uintptr(q))
+		if base == 0 || c.isBlocked(base) {
+			return
+		}
+		n := runtimefunc.ObjectSize(base)
+		if c.seen.countRange(base, n) == n {
+			return
+		}
+		c.block(base)
+		size += n
+		if depth > 1 {
+			off := uintptr(q) - base
+			size += c.capturedSize(unsafe.Pointer(uintptr(q)-off), depth-1)
+		}
+	})
+	return size
+}
+
+func (c *scanState) isBlocked(base uintptr) bool {
+	_, ok := c.blocked[base]
+	return ok
+}
+
+func (c *scanState) block(base uintptr) {
+	if c.blocked == nil {
+		c.blocked = make(map[uintptr]struct{})
+	}
+	c.blocked[base] = struct{}{}
+}
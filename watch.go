@@ -0,0 +1,227 @@
+package memsize
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Interval is the time between scans. The default is one minute.
+	Interval time.Duration
+
+	// AfterGC makes scans run after garbage collection cycles (see GCTicker)
+	// instead of periodically. Interval is the minimum time between scans.
+	AfterGC bool
+
+	// MaxTotal is the total size above which SizeExceeded events are emitted.
+	// MaxTypeSize is the same limit for individual types.
+	MaxTotal    uintptr
+	MaxTypeSize uintptr
+
+	// MaxGrowthRate is the growth rate in bytes per second above which
+	// GrowthExceeded events are emitted. It applies to the total and to all types.
+	MaxGrowthRate float64
+
+	// OnEvent is called for every event, before it is sent on the event channel.
+	// Unlike the channel, the callback never misses events. It is called on the
+	// goroutine of the watch and delays the next scan until it returns.
+	OnEvent func(Event)
+
+	// Scan configures the scans.
+	Scan Options
+}
+
+const defaultWatchInterval = time.Minute
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	SizeExceeded   EventKind = iota // size went above the configured limit
+	GrowthExceeded                  // growth rate went above the configured limit
+	QuotaExceeded                   // root total went above its quota, see RootSet.SetQuota
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case SizeExceeded:
+		return "SizeExceeded"
+	case GrowthExceeded:
+		return "GrowthExceeded"
+	case QuotaExceeded:
+		return "QuotaExceeded"
+	default:
+		return "EventKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Event is emitted by Watch when a threshold is crossed. Events are only emitted
+// when the watched size crosses the threshold, not for every scan during which it
+// stays above the threshold.
+type Event struct {
+	Kind EventKind
+	Root string       // name of the root for WatchRoots
+	Type reflect.Type // nil for the total
+	Size uintptr
+	Rate float64 // growth in bytes per second since the previous scan
+	Time time.Time
+}
+
+// Watch scans root periodically and emits an event whenever a configured threshold
+// is crossed. Calling stop ends the watch and closes the event channel.
+//
+// Events are dropped if the channel is not drained before the next scan completes.
+func Watch(root interface{}, opts WatchOptions) (events <-chan Event, stop func()) {
+	w := newWatcher(&opts)
+	return watch(&opts, func(now time.Time) []Event {
+		return w.check(ScanWithOptions(root, opts.Scan), now)
+	})
+}
+
+// WatchRoots is like Watch, but scans all roots of rs together (see ScanRoots).
+// The thresholds of opts apply to each root individually. Additionally, a
+// QuotaExceeded event is emitted whenever the total of a root goes above its quota
+// (see RootSet.SetQuota). The Root field of events holds the name of the root.
+func WatchRoots(rs *RootSet, opts WatchOptions) (events <-chan Event, stop func()) {
+	watchers := make(map[string]*watcher)
+	return watch(&opts, func(now time.Time) []Event {
+		var events []Event
+		res := ScanRoots(rs, opts.Scan)
+		for name, sizes := range res.ByRoot {
+			w := watchers[name]
+			if w == nil {
+				w = newWatcher(&opts)
+				watchers[name] = w
+			}
+			evs := w.check(sizes, now)
+			evs = w.checkQuota(evs, sizes.Total, rs.Quota(name), now)
+			for i := range evs {
+				evs[i].Root = name
+			}
+			events = append(events, evs...)
+		}
+		for name := range watchers {
+			if _, ok := res.ByRoot[name]; !ok {
+				delete(watchers, name) // root was removed
+			}
+		}
+		return events
+	})
+}
+
+// watch runs scan periodically and delivers the events it returns.
+func watch(opts *WatchOptions, scan func(now time.Time) []Event) (events <-chan Event, stop func()) {
+	if opts.Interval == 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	var (
+		ch       = make(chan Event, 16)
+		quit     = make(chan struct{})
+		done     = make(chan struct{})
+		stopOnce sync.Once
+	)
+	go func() {
+		defer close(done)
+		defer close(ch)
+		var tick <-chan time.Time
+		if opts.AfterGC {
+			ticker := NewGCTicker(opts.Interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		} else {
+			ticker := time.NewTicker(opts.Interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			for _, ev := range scan(time.Now()) {
+				if opts.OnEvent != nil {
+					opts.OnEvent(ev)
+				}
+				select {
+				case ch <- ev:
+				default:
+				}
+			}
+			select {
+			case <-tick:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	stop = func() {
+		stopOnce.Do(func() { close(quit) })
+		<-done
+	}
+	return ch, stop
+}
+
+// watcher tracks threshold state across scans.
+type watcher struct {
+	opts     *WatchOptions
+	prev     map[reflect.Type]uintptr
+	prevTime time.Time
+	above    map[watchKey]bool
+}
+
+type watchKey struct {
+	kind EventKind
+	typ  reflect.Type
+}
+
+func newWatcher(opts *WatchOptions) *watcher {
+	return &watcher{opts: opts, above: make(map[watchKey]bool)}
+}
+
+// check processes a scan result and returns the events it causes.
+func (w *watcher) check(s Sizes, now time.Time) []Event {
+	var (
+		events []Event
+		cur    = make(map[reflect.Type]uintptr, len(s.ByType)+1)
+		dt     = now.Sub(w.prevTime).Seconds()
+	)
+	cur[nil] = s.Total
+	for typ, ts := range s.ByType {
+		cur[typ] = ts.Total
+	}
+	for typ, size := range cur {
+		limit := w.opts.MaxTypeSize
+		if typ == nil {
+			limit = w.opts.MaxTotal
+		}
+		if limit != 0 {
+			events = w.cross(events, watchKey{SizeExceeded, typ}, size > limit, Event{Size: size, Time: now})
+		}
+		if w.opts.MaxGrowthRate != 0 && w.prev != nil && dt > 0 {
+			rate := (float64(size) - float64(w.prev[typ])) / dt
+			events = w.cross(events, watchKey{GrowthExceeded, typ}, rate > w.opts.MaxGrowthRate, Event{Size: size, Rate: rate, Time: now})
+		}
+	}
+	// Types which are gone are below all thresholds.
+	for k := range w.above {
+		if _, ok := cur[k.typ]; !ok {
+			delete(w.above, k)
+		}
+	}
+	w.prev, w.prevTime = cur, now
+	return events
+}
+
+// checkQuota appends a QuotaExceeded event if the total crossed the quota.
+func (w *watcher) checkQuota(events []Event, total, quota uintptr, now time.Time) []Event {
+	return w.cross(events, watchKey{QuotaExceeded, nil}, quota != 0 && total > quota, Event{Size: total, Time: now})
+}
+
+// cross updates the threshold state of k and appends ev if the threshold was crossed.
+func (w *watcher) cross(events []Event, k watchKey, above bool, ev Event) []Event {
+	if above && !w.above[k] {
+		ev.Kind, ev.Type = k.kind, k.typ
+		events = append(events, ev)
+	}
+	w.above[k] = above
+	return events
+}
@@ -0,0 +1,133 @@
+package memsize
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	var (
+		w      = newWatcher(&WatchOptions{MaxTypeSize: 100, MaxGrowthRate: 10})
+		tSlice = reflect.TypeOf([]byte{})
+		start  = time.Now()
+	)
+	sizes := func(n uintptr) Sizes {
+		return Sizes{Total: n, ByType: map[reflect.Type]*TypeSize{tSlice: {Total: n}}}
+	}
+
+	// Below the limit.
+	if evs := w.check(sizes(50), start); len(evs) != 0 {
+		t.Fatalf("unexpected events: %v", evs)
+	}
+	// Crossing the size limit, growing by 100 bytes in one second.
+	evs := w.check(sizes(150), start.Add(time.Second))
+	want := []Event{
+		{Kind: SizeExceeded, Type: tSlice, Size: 150, Time: start.Add(time.Second)},
+		{Kind: GrowthExceeded, Type: nil, Size: 150, Rate: 100, Time: start.Add(time.Second)},
+		{Kind: GrowthExceeded, Type: tSlice, Size: 150, Rate: 100, Time: start.Add(time.Second)},
+	}
+	if !sameEvents(evs, want) {
+		t.Fatalf("wrong events:\ngot  %v\nwant %v", evs, want)
+	}
+	// Staying above the limit doesn't emit again.
+	if evs := w.check(sizes(150), start.Add(2*time.Second)); len(evs) != 0 {
+		t.Fatalf("unexpected events: %v", evs)
+	}
+	// Going below and above again emits again.
+	w.check(sizes(50), start.Add(3*time.Second))
+	evs = w.check(sizes(101), start.Add(13*time.Second))
+	want = []Event{{Kind: SizeExceeded, Type: tSlice, Size: 101, Time: start.Add(13 * time.Second)}}
+	if !sameEvents(evs, want) {
+		t.Fatalf("wrong events:\ngot  %v\nwant %v", evs, want)
+	}
+	// A type which vanishes and comes back above the limit emits again.
+	w.check(Sizes{Total: 101, ByType: map[reflect.Type]*TypeSize{}}, start.Add(23*time.Second))
+	evs = w.check(sizes(101), start.Add(43*time.Second))
+	want = []Event{{Kind: SizeExceeded, Type: tSlice, Size: 101, Time: start.Add(43 * time.Second)}}
+	if !sameEvents(evs, want) {
+		t.Fatalf("wrong events after type vanished:\ngot  %v\nwant %v", evs, want)
+	}
+}
+
+func sameEvents(a, b []Event) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ev := range b {
+		found := false
+		for _, ev2 := range a {
+			if reflect.DeepEqual(ev, ev2) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWatch(t *testing.T) {
+	v := make([]byte, 200)
+	events, stop := Watch(&v, WatchOptions{Interval: time.Millisecond, MaxTotal: 100})
+	select {
+	case ev := <-events:
+		if ev.Kind != SizeExceeded || ev.Type != nil {
+			t.Errorf("wrong event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	stop()
+	stop() // second call is a no-op
+	for range events {
+	}
+}
+
+func TestWatcherQuota(t *testing.T) {
+	w := newWatcher(&WatchOptions{})
+	now := time.Now()
+	evs := w.checkQuota(nil, 150, 100, now)
+	if want := []Event{{Kind: QuotaExceeded, Size: 150, Time: now}}; !sameEvents(evs, want) {
+		t.Fatalf("wrong events:\ngot  %v\nwant %v", evs, want)
+	}
+	if evs := w.checkQuota(nil, 200, 100, now); len(evs) != 0 {
+		t.Fatalf("unexpected events: %v", evs)
+	}
+	if evs := w.checkQuota(nil, 200, 0, now); len(evs) != 0 {
+		t.Fatalf("unexpected events without quota: %v", evs)
+	}
+}
+
+func TestWatchRoots(t *testing.T) {
+	var (
+		rs    RootSet
+		small = make([]byte, 10)
+		large = make([]byte, 200)
+		fired = make(chan Event, 16)
+	)
+	rs.Add("small", &small)
+	rs.Add("large", &large)
+	rs.SetQuota("small", 100)
+	rs.SetQuota("large", 100)
+	opts := WatchOptions{
+		Interval: time.Millisecond,
+		OnEvent:  func(ev Event) { fired <- ev },
+	}
+	events, stop := WatchRoots(&rs, opts)
+	select {
+	case ev := <-events:
+		if ev.Kind != QuotaExceeded || ev.Root != "large" || ev.Size != Scan(&large).Total {
+			t.Errorf("wrong event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	stop()
+	for range events {
+	}
+	if len(fired) != 1 {
+		t.Errorf("callback called %d times, want 1", len(fired))
+	}
+}
@@ -0,0 +1,125 @@
+package memsize
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// isWeakPointer reports whether typ is weak.Pointer[T] (Go 1.24+), which is
+// defined as
+//
+//	type Pointer[T any] struct {
+//		_ [0]*T
+//		u unsafe.Pointer
+//	}
+//
+// The field u points to a handle which holds the target address, or zero when the
+// target has been freed.
+func isWeakPointer(typ reflect.Type) bool {
+	if typ.PkgPath() != "weak" || !strings.HasPrefix(typ.Name(), "Pointer[") || typ.NumField() != 2 {
+		return false
+	}
+	f0, f1 := typ.Field(0).Type, typ.Field(1).Type
+	return f0.Kind() == reflect.Array && f0.Len() == 0 && f0.Elem().Kind() == reflect.Ptr &&
+		f1.Kind() == reflect.UnsafePointer
+}
+
+// weakRef is a weak pointer found during the scan.
+type weakRef struct {
+	handle unsafe.Pointer
+	typ    reflect.Type // weak.Pointer[T]
+}
+
+// weakScan holds the weak pointers found while scanning a root, along with the
+// memory seen by the scan.
+type weakScan struct {
+	seen seenSet
+	refs []weakRef
+}
+
+// weakTarget is a live target of a weak pointer.
+type weakTarget struct {
+	p    unsafe.Pointer
+	etyp reflect.Type
+}
+
+// scanWeakPointer handles a weak.Pointer. Weak pointers don't keep their target
+// alive, so the target is not scanned as part of the value. When weakly reachable
+// memory is counted, the pointer is queued for scanWeak.
+func (c *scanState) scanWeakPointer(v reflect.Value) uintptr {
+	if !c.weakReachable {
+		return 0
+	}
+	if handle := unsafe.Pointer(v.Field(1).Pointer()); handle != nil {
+		c.weak = append(c.weak, weakRef{handle, v.Type()})
+	}
+	return 0
+}
+
+// takeWeak returns the weak pointers found by the scan of the current root.
+func (c *scanState) takeWeak() weakScan {
+	w := weakScan{seen: c.seen, refs: c.weak}
+	c.weak = nil
+	return w
+}
+
+// scanWeak counts the memory which is only reachable through the weak pointers
+// found by the scans of the roots, storing it in results[i].WeakReachable. It must
+// be called while the world is running.
+//
+// The target of a weak pointer may be an unreachable object which hasn't been
+// freed yet. Only the runtime can tell, so pointers are resolved by calling their
+// Value method. The live targets are then scanned in another pause, skipping the
+// memory seen by the scan of their root.
+func (c *scanState) scanWeak(weak []weakScan, results []Sizes) {
+	if !c.weakReachable {
+		return
+	}
+	targets := make([][]weakTarget, len(weak))
+	live := 0
+	for i, w := range weak {
+		targets[i] = resolveWeak(w.refs)
+		live += len(targets[i])
+	}
+	if live == 0 {
+		for i := range results {
+			results[i].WeakReachable = c.newSizes()
+		}
+		return
+	}
+	c.withWorldStopped(func() {
+		for i, w := range weak {
+			c.root, c.seen, c.s = i, w.seen, c.newSizes()
+			for _, t := range targets[i] {
+				if c.stopped {
+					break
+				}
+				c.scan(address(t.p), reflect.NewAt(t.etyp, t.p).Elem(), true)
+			}
+			results[i].WeakReachable = c.s
+			results[i].Partial = c.stopped
+		}
+	})
+	if c.slicer != nil {
+		for i := range results {
+			results[i].Pauses, results[i].MaxPause = c.slicer.pauses, c.slicer.maxPause
+		}
+	}
+	runtime.KeepAlive(targets)
+}
+
+// resolveWeak returns the live targets of weak pointers.
+func resolveWeak(refs []weakRef) []weakTarget {
+	var targets []weakTarget
+	for _, ref := range refs {
+		wp := reflect.New(ref.typ).Elem()
+		u := wp.Field(1)
+		reflect.NewAt(u.Type(), unsafe.Pointer(u.UnsafeAddr())).Elem().SetPointer(ref.handle)
+		if p := wp.MethodByName("Value").Call(nil)[0]; !p.IsNil() {
+			targets = append(targets, weakTarget{unsafe.Pointer(p.Pointer()), p.Type().Elem()})
+		}
+	}
+	return targets
+}
@@ -0,0 +1,69 @@
+//go:build go1.24 && !purego && !js && !wasip1
+// +build go1.24,!purego,!js,!wasip1
+
+package memsize
+
+import (
+	"runtime"
+	"testing"
+	"weak"
+)
+
+func TestWeakPointer(t *testing.T) {
+	type holder struct {
+		w weak.Pointer[struct16]
+		s *struct16
+	}
+	var (
+		target = &struct16{}
+		v      = &holder{w: weak.Make(target)}
+	)
+	want := sizeofWord + sizeofWord
+	if sizes := Scan(v); sizes.Total != want || sizes.WeakReachable != nil {
+		t.Fatalf("total=%d, want %d", sizes.Total, want)
+	}
+	sizes := ScanWithOptions(v, Options{WeakReachable: true})
+	if sizes.Total != want {
+		t.Fatalf("total=%d, want %d", sizes.Total, want)
+	}
+	if sizes.WeakReachable == nil || sizes.WeakReachable.Total != 16 {
+		t.Fatalf("wrong weakly reachable sizes: %+v", sizes.WeakReachable)
+	}
+
+	// Targets which are also strongly reachable are not counted again.
+	v.s = target
+	sizes = ScanWithOptions(v, Options{WeakReachable: true})
+	if sizes.Total != want+16 || sizes.WeakReachable.Total != 0 {
+		t.Fatalf("total=%d, weak=%d", sizes.Total, sizes.WeakReachable.Total)
+	}
+	runtime.KeepAlive(target)
+}
+
+func TestWeakPointerFreed(t *testing.T) {
+	v := &struct{ w weak.Pointer[[64]byte] }{weak.Make(new([64]byte))}
+	runtime.GC()
+	runtime.GC()
+	if v.w.Value() != nil {
+		t.Skip("weak pointer target not collected")
+	}
+	sizes := ScanWithOptions(v, Options{WeakReachable: true})
+	if sizes.WeakReachable == nil || sizes.WeakReachable.Total != 0 {
+		t.Fatalf("wrong weakly reachable sizes: %+v", sizes.WeakReachable)
+	}
+}
+
+func TestWeakPointerScanRoots(t *testing.T) {
+	target := &struct16{}
+	var rs RootSet
+	rs.Add("a", &struct{ w weak.Pointer[struct16] }{weak.Make(target)})
+	rs.Add("b", &struct{ p *struct16 }{target})
+	res := ScanRoots(&rs, Options{WeakReachable: true})
+	// The target is strongly reachable from b, which is scanned after a.
+	if w := res.ByRoot["a"].WeakReachable; w == nil || w.Total != 0 {
+		t.Fatalf("wrong weakly reachable sizes of a: %+v", w)
+	}
+	if w := res.ByRoot["b"].WeakReachable; w == nil || w.Total != 0 {
+		t.Fatalf("wrong weakly reachable sizes of b: %+v", w)
+	}
+	runtime.KeepAlive(target)
+}